@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/spf13/viper"
+)
+
+const (
+	conflictFlag = "conflict"
+)
+
+// conflictSummary counts what the conflict policy did with the files which already existed
+type conflictSummary struct {
+	overwritten  int
+	skipped      int
+	skippedNewer int
+	renamed      int
+}
+
+func (s *conflictSummary) onConflict(event *config.ConflictEvent) {
+	logger := logging.NewJSONLogger(viper.GetInt(verboseFlag))
+
+	switch event.Type {
+	case config.ConflictEventTypeOverwrite:
+		s.overwritten++
+
+		logger.Info("Overwrote existing file", map[string]interface{}{
+			"path": event.Path,
+		})
+	case config.ConflictEventTypeSkip:
+		s.skipped++
+
+		logger.Info("Skipped existing file", map[string]interface{}{
+			"path": event.Path,
+		})
+	case config.ConflictEventTypeSkipNewer:
+		s.skippedNewer++
+
+		logger.Info("Skipped file since the existing one is newer", map[string]interface{}{
+			"path": event.Path,
+		})
+	case config.ConflictEventTypeRename:
+		s.renamed++
+
+		logger.Info("Renamed restored file since it already exists", map[string]interface{}{
+			"path": event.Path,
+			"to":   event.To,
+		})
+	}
+}
+
+func (s *conflictSummary) print() {
+	logging.NewJSONLogger(viper.GetInt(verboseFlag)).Info("Conflicts", map[string]interface{}{
+		"overwritten":  s.overwritten,
+		"skipped":      s.skipped,
+		"skippedNewer": s.skippedNewer,
+		"renamed":      s.renamed,
+	})
+}
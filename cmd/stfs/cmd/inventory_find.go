@@ -27,7 +27,7 @@ var inventoryFindCmd = &cobra.Command{
 			return err
 		}
 
-		if _, err := inventory.Find(
+		hdrs, err := inventory.Find(
 			config.MetadataConfig{
 				Metadata: metadataPersister,
 			},
@@ -35,10 +35,13 @@ var inventoryFindCmd = &cobra.Command{
 			viper.GetString(expressionFlag),
 
 			logging.NewCSVLogger().PrintHeader,
-		); err != nil {
+		)
+		if err != nil {
 			return err
 		}
 
+		printSavings(hdrs)
+
 		return nil
 	},
 }
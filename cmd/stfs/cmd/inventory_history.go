@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/inventory"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var inventoryHistoryCmd = &cobra.Command{
+	Use:     "history",
+	Aliases: []string{"his", "hist"},
+	Short:   "List all versions of a file or directory on tape or tar file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		if _, err := inventory.History(
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+			},
+
+			viper.GetString(nameFlag),
+
+			logging.NewCSVLogger().PrintHeaderVersion,
+		); err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	inventoryHistoryCmd.PersistentFlags().StringP(nameFlag, "n", "", "File or directory to list the versions of")
+
+	viper.AutomaticEnv()
+
+	inventoryCmd.AddCommand(inventoryHistoryCmd)
+}
@@ -27,7 +27,7 @@ var inventoryListCmd = &cobra.Command{
 			return err
 		}
 
-		if _, err := inventory.List(
+		hdrs, err := inventory.List(
 			config.MetadataConfig{
 				Metadata: metadataPersister,
 			},
@@ -36,10 +36,13 @@ var inventoryListCmd = &cobra.Command{
 			viper.GetInt(limitFlag),
 
 			logging.NewCSVLogger().PrintHeader,
-		); err != nil {
+		)
+		if err != nil {
 			return err
 		}
 
+		printSavings(hdrs)
+
 		return nil
 	},
 }
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"archive/tar"
+
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/inventory"
+	"github.com/spf13/viper"
+)
+
+func printSavings(hdrs []*tar.Header) {
+	if files, bytes := inventory.Savings(hdrs); files > 0 {
+		logging.NewJSONLogger(viper.GetInt(verboseFlag)).Info("Deduplication savings", map[string]interface{}{
+			"files": files,
+			"bytes": bytes,
+		})
+	}
+}
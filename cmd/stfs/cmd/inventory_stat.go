@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"archive/tar"
+
 	"github.com/pojntfx/stfs/internal/logging"
 	"github.com/pojntfx/stfs/pkg/config"
 	"github.com/pojntfx/stfs/pkg/inventory"
@@ -27,7 +29,7 @@ var inventoryStatCmd = &cobra.Command{
 			return err
 		}
 
-		if _, err := inventory.Stat(
+		hdr, err := inventory.Stat(
 			config.MetadataConfig{
 				Metadata: metadataPersister,
 			},
@@ -36,10 +38,13 @@ var inventoryStatCmd = &cobra.Command{
 			viper.GetBool(linkFlag),
 
 			logging.NewCSVLogger().PrintHeader,
-		); err != nil {
+		)
+		if err != nil {
 			return err
 		}
 
+		printSavings([]*tar.Header{hdr})
+
 		return nil
 	},
 }
@@ -5,12 +5,15 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/pojntfx/stfs/internal/check"
 	"github.com/pojntfx/stfs/internal/keyext"
 	"github.com/pojntfx/stfs/internal/logging"
 	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/filter"
 	"github.com/pojntfx/stfs/pkg/keys"
 	"github.com/pojntfx/stfs/pkg/mtio"
 	"github.com/pojntfx/stfs/pkg/operations"
@@ -28,6 +31,13 @@ const (
 	recipientFlag        = "recipient"
 	identityFlag         = "identity"
 	passwordFlag         = "password"
+	stdinFlag            = "stdin"
+	modeFlag             = "mode"
+	mtimeFlag            = "mtime"
+	workersFlag          = "workers"
+	memoryBudgetFlag     = "memory-budget"
+	dryRunFlag           = "dry-run"
+	resumeFlag           = "resume"
 )
 
 var operationArchiveCmd = &cobra.Command{
@@ -47,6 +57,14 @@ var operationArchiveCmd = &cobra.Command{
 			return err
 		}
 
+		if viper.GetBool(stdinFlag) && viper.GetString(nameFlag) == "" {
+			return config.ErrNameRequired
+		}
+
+		if viper.GetBool(stdinFlag) && viper.GetBool(resumeFlag) {
+			return config.ErrResumeStdinUnsupported
+		}
+
 		return check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(identityFlag))
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -70,12 +88,24 @@ var operationArchiveCmd = &cobra.Command{
 			return err
 		}
 
+		// Continue the interrupted run with its session, which skips the files which have been archived already
+		session := operations.NewSession()
+		workdir := ""
+		overwrite := viper.GetBool(overwriteFlag)
+		if viper.GetBool(resumeFlag) {
+			if session, workdir, err = resumeArchiveState(getArchiveStatePath(viper.GetString(metadataFlag))); err != nil {
+				return err
+			}
+
+			overwrite = false
+		}
+
 		mt := mtio.MagneticTapeIO{}
 		tm := tape.NewTapeManager(
 			viper.GetString(driveFlag),
 			mt,
 			viper.GetInt(recordSizeFlag),
-			viper.GetBool(overwriteFlag),
+			overwrite,
 		)
 
 		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
@@ -83,6 +113,9 @@ var operationArchiveCmd = &cobra.Command{
 			return err
 		}
 
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
 		ops := operations.NewOperations(
 			config.BackendConfig{
 				GetWriter:   tm.GetWriter,
@@ -91,17 +124,25 @@ var operationArchiveCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+				Workers:      viper.GetInt(workersFlag),
+				MemoryBudget: viper.GetInt64(memoryBudgetFlag) * 1024 * 1024,
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -110,64 +151,117 @@ var operationArchiveCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			progressLogger.PrintProgressEvent,
+
+			viper.GetBool(dryRunFlag),
 		)
 
-		files := make(chan config.FileConfig)
-		errs := make(chan error)
-		go func() {
-			if err := filepath.Walk(viper.GetString(fromFlag), func(path string, info fs.FileInfo, err error) error {
-				path = filepath.ToSlash(path)
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
 
-				if err != nil {
-					return err
-				}
+		if viper.GetBool(stdinFlag) {
+			mode, err := getStdinMode()
+			if err != nil {
+				return err
+			}
 
-				link := ""
-				if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-					if link, err = os.Readlink(path); err != nil {
-						return err
+			modTime, err := getStdinModTime()
+			if err != nil {
+				return err
+			}
+
+			read := false
+			if _, err := ops.ArchiveContext(
+				ctx,
+				func() (config.FileConfig, error) {
+					if read {
+						return config.FileConfig{}, io.EOF
 					}
-				}
-
-				files <- config.FileConfig{
-					GetFile: func() (io.ReadSeekCloser, error) {
-						return os.Open(path)
-					},
-					Info: info,
-					Path: filepath.ToSlash(path),
-					Link: filepath.ToSlash(link),
-				}
-
-				return nil
-			}); err != nil {
-				errs <- err
-
-				return
+					read = true
+
+					return operations.NewStreamFileConfig(
+						viper.GetString(nameFlag),
+						mode,
+						modTime,
+						func() (io.ReadCloser, error) {
+							return os.Stdin, nil
+						},
+					), nil
+				},
+				viper.GetString(compressionLevelFlag),
+				overwrite,
+				false,
+			); err != nil {
+				return err
 			}
 
-			errs <- io.EOF
-		}()
-
-		if _, err := ops.Archive(
-			func() (config.FileConfig, error) {
-				select {
-				case file := <-files:
-					return file, err
-				case err := <-errs:
-					return config.FileConfig{}, err
-				}
-			},
+			return nil
+		}
+
+		rules, err := getRules()
+		if err != nil {
+			return err
+		}
+
+		if progressLogger.Enabled() {
+			files, bytes, err := getWalkTotals(ctx, workdir, viper.GetString(fromFlag), rules)
+			if err != nil {
+				return err
+			}
+
+			progressLogger.SetTotal(files, bytes)
+		}
+
+		statePath := getArchiveStatePath(viper.GetString(metadataFlag))
+		if !viper.GetBool(dryRunFlag) {
+			if err := saveArchiveState(statePath, session, workdir); err != nil {
+				return err
+			}
+		}
+
+		if _, err := ops.ArchiveSessionContext(
+			ctx,
+			session,
+			operations.NewWalkSource(workdir, viper.GetString(fromFlag), rules),
 			viper.GetString(compressionLevelFlag),
-			viper.GetBool(overwriteFlag),
-			false,
+			overwrite,
 		); err != nil {
 			return err
 		}
 
-		return nil
+		if viper.GetBool(dryRunFlag) {
+			return nil
+		}
+
+		return os.Remove(statePath)
 	},
 }
 
+// getStdinMode returns the permissions to archive stdin with
+func getStdinMode() (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(viper.GetString(modeFlag), 8, 32)
+	if err != nil || fs.FileMode(mode)&^fs.ModePerm != 0 {
+		return 0, config.ErrModeInvalid
+	}
+
+	return fs.FileMode(mode), nil
+}
+
+// getStdinModTime returns the modification time to archive stdin with
+func getStdinModTime() (time.Time, error) {
+	mtime := viper.GetString(mtimeFlag)
+	if mtime == "" {
+		return time.Now(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, mtime)
+	if err != nil {
+		return time.Time{}, config.ErrModTimeInvalid
+	}
+
+	return t, nil
+}
+
 func init() {
 	operationArchiveCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
 	operationArchiveCmd.PersistentFlags().StringP(fromFlag, "f", ".", "File or directory to archive")
@@ -176,6 +270,18 @@ func init() {
 	operationArchiveCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
 	operationArchiveCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
 	operationArchiveCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationArchiveCmd.PersistentFlags().Bool(stdinFlag, false, "Archive stdin instead of a file or directory (requires --name)")
+	operationArchiveCmd.PersistentFlags().StringP(nameFlag, "n", "", "Name to archive stdin as")
+	operationArchiveCmd.PersistentFlags().String(modeFlag, "0644", "Octal permissions to archive stdin with")
+	operationArchiveCmd.PersistentFlags().String(mtimeFlag, "", "RFC3339 timestamp to archive stdin with as the modification time (current time by default)")
+	operationArchiveCmd.PersistentFlags().IntP(workersFlag, "w", runtime.NumCPU(), "Amount of files to compress, encrypt and sign concurrently")
+	operationArchiveCmd.PersistentFlags().Int64(memoryBudgetFlag, 256, "Maximum amount of MiB of compressed and encrypted files to keep in memory until they are written; larger files are spooled to temporary files")
+
+	operationArchiveCmd.PersistentFlags().StringArrayP(excludeFlag, "x", []string{}, "Gitignore-style pattern of files to exclude (can be specified multiple times)")
+	operationArchiveCmd.PersistentFlags().StringArray(includeFlag, []string{}, "Gitignore-style pattern of files to include even if they are excluded by another pattern; has no effect on its own (can be specified multiple times)")
+	operationArchiveCmd.PersistentFlags().String(excludeFromFlag, "", fmt.Sprintf("Path to a file with gitignore-style patterns of files to exclude (per-directory %v files are always used)", filter.IgnoreFileName))
+	operationArchiveCmd.PersistentFlags().Bool(resumeFlag, false, "Continue the last archive run which has been interrupted with its source and patterns; files which are already on the tape or tar file are skipped")
+	operationArchiveCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
 
 	viper.AutomaticEnv()
 
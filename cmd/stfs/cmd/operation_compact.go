@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/pojntfx/stfs/internal/check"
+	"github.com/pojntfx/stfs/internal/keyext"
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/keys"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	toMetadataFlag    = "to-metadata"
+	toCompressionFlag = "to-compression"
+	toEncryptionFlag  = "to-encryption"
+	toSignatureFlag   = "to-signature"
+	toRecipientFlag   = "to-recipient"
+	toIdentityFlag    = "to-identity"
+	toPasswordFlag    = "to-password"
+)
+
+var operationCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Copy all files which haven't been deleted or replaced to a new tape or tar file to reclaim space",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		if viper.GetString(toFlag) == "" || viper.GetString(toMetadataFlag) == "" {
+			return config.ErrCompactDestinationInvalid
+		}
+
+		if filepath.Clean(viper.GetString(toFlag)) == filepath.Clean(viper.GetString(driveFlag)) ||
+			filepath.Clean(viper.GetString(toMetadataFlag)) == filepath.Clean(viper.GetString(metadataFlag)) {
+			return config.ErrCompactDestinationInvalid
+		}
+
+		if err := check.CheckCompressionFormat(getCompactFormat(toCompressionFlag, compressionFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckEncryptionFormat(getCompactFormat(toEncryptionFlag, encryptionFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckSignatureFormat(getCompactFormat(toSignatureFlag, signatureFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckCompressionLevel(viper.GetString(compressionLevelFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(recipientFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(getCompactFormat(toEncryptionFlag, encryptionFlag), viper.GetString(toRecipientFlag)); err != nil {
+			return err
+		}
+
+		return check.CheckKeyAccessible(getCompactFormat(toSignatureFlag, signatureFlag), viper.GetString(toIdentityFlag))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := keyext.ReadKey(viper.GetString(signatureFlag), viper.GetString(recipientFlag))
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.ParseSignerRecipient(viper.GetString(signatureFlag), pubkey)
+		if err != nil {
+			return err
+		}
+
+		privkey, err := keyext.ReadKey(viper.GetString(encryptionFlag), viper.GetString(identityFlag))
+		if err != nil {
+			return err
+		}
+
+		identity, err := keys.ParseIdentity(viper.GetString(encryptionFlag), privkey, viper.GetString(passwordFlag))
+		if err != nil {
+			return err
+		}
+
+		toCompression := getCompactFormat(toCompressionFlag, compressionFlag)
+		toEncryption := getCompactFormat(toEncryptionFlag, encryptionFlag)
+		toSignature := getCompactFormat(toSignatureFlag, signatureFlag)
+
+		toPubkey, err := keyext.ReadKey(toEncryption, viper.GetString(toRecipientFlag))
+		if err != nil {
+			return err
+		}
+
+		toRecipient, err := keys.ParseRecipient(toEncryption, toPubkey)
+		if err != nil {
+			return err
+		}
+
+		toPrivkey, err := keyext.ReadKey(toSignature, viper.GetString(toIdentityFlag))
+		if err != nil {
+			return err
+		}
+
+		toIdentity, err := keys.ParseSignerIdentity(toSignature, toPrivkey, viper.GetString(toPasswordFlag))
+		if err != nil {
+			return err
+		}
+
+		mt := mtio.MagneticTapeIO{}
+		tm := tape.NewTapeManager(
+			viper.GetString(driveFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			false,
+		)
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		ops := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume: changeVolume(tm),
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+			},
+
+			config.PipeConfig{
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+			},
+			config.CryptoConfig{
+				Recipient: recipient,
+				Identity:  identity,
+				Password:  viper.GetString(passwordFlag),
+			},
+
+			nil,
+			nil,
+
+			false,
+		)
+
+		toTm := tape.NewTapeManager(
+			viper.GetString(toFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			true,
+		)
+
+		toMetadataPersister := persisters.NewMetadataPersister(viper.GetString(toMetadataFlag))
+		if err := toMetadataPersister.Open(); err != nil {
+			return err
+		}
+
+		toOps := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   toTm.GetWriter,
+				CloseWriter: toTm.Close,
+
+				GetReader:   toTm.GetReader,
+				CloseReader: toTm.Close,
+
+				ChangeVolume:   changeVolume(toTm),
+				TruncateVolume: toTm.TruncateVolume,
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: toMetadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(toMetadataFlag))),
+			},
+
+			config.PipeConfig{
+				Compression:  toCompression,
+				Encryption:   toEncryption,
+				Signature:    toSignature,
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+			},
+			config.CryptoConfig{
+				Recipient: toRecipient,
+				Identity:  toIdentity,
+				Password:  viper.GetString(toPasswordFlag),
+			},
+
+			logging.NewCSVLogger().PrintHeaderEvent,
+			nil,
+
+			viper.GetBool(dryRunFlag),
+		)
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		reclaimed, err := ops.CompactContext(ctx, toOps, viper.GetString(compressionLevelFlag))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("reclaimed: %v bytes\n", reclaimed)
+
+		return nil
+	},
+}
+
+// getCompactFormat returns the format to use for the compacted tape or tar file, which is the one of the source by default
+func getCompactFormat(toFlag string, fromFlag string) string {
+	if format := viper.GetString(toFlag); format != "" {
+		return format
+	}
+
+	return viper.GetString(fromFlag)
+}
+
+func init() {
+	operationCompactCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
+	operationCompactCmd.PersistentFlags().StringP(compressionLevelFlag, "l", config.CompressionLevelBalancedKey, fmt.Sprintf("Compression level to use (default %v, available are %v)", config.CompressionLevelBalancedKey, config.KnownCompressionLevels))
+	operationCompactCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
+	operationCompactCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationCompactCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to the public key to verify with")
+
+	operationCompactCmd.PersistentFlags().StringP(toFlag, "t", "", "Tape or tar file to copy to, which is overwritten")
+	operationCompactCmd.PersistentFlags().String(toMetadataFlag, "", "Metadata database of the tape or tar file to copy to")
+	operationCompactCmd.PersistentFlags().String(toCompressionFlag, "", fmt.Sprintf("Compression format to use for the copy (default is the source's, available are %v)", config.KnownCompressionFormats))
+	operationCompactCmd.PersistentFlags().String(toEncryptionFlag, "", fmt.Sprintf("Encryption format to use for the copy (default is the source's, available are %v)", config.KnownEncryptionFormats))
+	operationCompactCmd.PersistentFlags().String(toSignatureFlag, "", fmt.Sprintf("Signature format to use for the copy (default is the source's, available are %v)", config.KnownSignatureFormats))
+	operationCompactCmd.PersistentFlags().String(toRecipientFlag, "", "Path to public key of recipient to encrypt the copy for")
+	operationCompactCmd.PersistentFlags().String(toIdentityFlag, "", "Path to private key to sign the copy with")
+	operationCompactCmd.PersistentFlags().String(toPasswordFlag, "", "Password for the private key to sign the copy with")
+	operationCompactCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
+
+	viper.AutomaticEnv()
+
+	operationCmd.AddCommand(operationCompactCmd)
+}
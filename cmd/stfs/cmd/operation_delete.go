@@ -75,17 +75,23 @@ var operationDeleteCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -94,6 +100,9 @@ var operationDeleteCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			nil,
+
+			viper.GetBool(dryRunFlag),
 		)
 
 		return ops.Delete(viper.GetString(nameFlag))
@@ -106,6 +115,7 @@ func init() {
 	operationDeleteCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
 	operationDeleteCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
 	operationDeleteCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationDeleteCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
 
 	viper.AutomaticEnv()
 
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/check"
+	"github.com/pojntfx/stfs/internal/keyext"
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/keys"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	outputFlag = "output"
+	formatFlag = "format"
+)
+
+var operationExportCmd = &cobra.Command{
+	Use:   "export <path>",
+	Short: "Export a file or directory from tape or tar file as a plain tar, tar.zst or zip file",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		if _, err := getAt(); err != nil {
+			return err
+		}
+
+		if err := check.CheckExportFormat(getExportFormat()); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
+			return err
+		}
+
+		return check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(recipientFlag))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := keyext.ReadKey(viper.GetString(signatureFlag), viper.GetString(recipientFlag))
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.ParseSignerRecipient(viper.GetString(signatureFlag), pubkey)
+		if err != nil {
+			return err
+		}
+
+		privkey, err := keyext.ReadKey(viper.GetString(encryptionFlag), viper.GetString(identityFlag))
+		if err != nil {
+			return err
+		}
+
+		identity, err := keys.ParseIdentity(viper.GetString(encryptionFlag), privkey, viper.GetString(passwordFlag))
+		if err != nil {
+			return err
+		}
+
+		mt := mtio.MagneticTapeIO{}
+		tm := tape.NewTapeManager(
+			viper.GetString(driveFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			false,
+		)
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		at, err := getAt()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		if err := metadataPersister.SetSnapshot(ctx, at); err != nil {
+			return err
+		}
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
+		// The headers can't be printed if the archive is written to stdout
+		var out io.Writer = os.Stdout
+		onHeader := logging.NewCSVLogger().PrintHeaderEvent
+		if output := viper.GetString(outputFlag); output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			out = f
+		} else {
+			onHeader = nil
+		}
+
+		ops := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume: changeVolume(tm),
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+			},
+
+			config.PipeConfig{
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+			},
+			config.CryptoConfig{
+				Recipient: recipient,
+				Identity:  identity,
+				Password:  viper.GetString(passwordFlag),
+			},
+
+			onHeader,
+			progressLogger.PrintProgressEvent,
+
+			false,
+		)
+
+		return ops.ExportContext(
+			ctx,
+
+			out,
+
+			args[0],
+			getExportFormat(),
+		)
+	},
+}
+
+// getExportFormat returns the format of the flag or, if it hasn't been given, the one of the extension of the output file
+func getExportFormat() string {
+	if format := viper.GetString(formatFlag); format != "" {
+		return format
+	}
+
+	output := viper.GetString(outputFlag)
+	switch {
+	case strings.HasSuffix(output, ".tar.zst"), strings.HasSuffix(output, ".tzst"):
+		return config.ExportFormatTarZstd
+	case strings.HasSuffix(output, ".zip"):
+		return config.ExportFormatZip
+	default:
+		return config.ExportFormatTar
+	}
+}
+
+func init() {
+	operationExportCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
+	operationExportCmd.PersistentFlags().StringP(outputFlag, "o", "-", "File to write the archive to (- for stdout)")
+	operationExportCmd.PersistentFlags().String(formatFlag, "", fmt.Sprintf("Format of the archive (by extension of the output file or %v by default, available are %v)", config.ExportFormatTar, config.KnownExportFormats))
+	operationExportCmd.PersistentFlags().String(atFlag, "", "Export the file or directory as it was at this session or RFC3339 timestamp (latest by default)")
+	operationExportCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
+	operationExportCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationExportCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to the public key to verify with")
+
+	viper.AutomaticEnv()
+
+	operationCmd.AddCommand(operationExportCmd)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/pojntfx/stfs/pkg/filter"
+	"github.com/spf13/viper"
+)
+
+const (
+	excludeFlag     = "exclude"
+	includeFlag     = "include"
+	excludeFromFlag = "exclude-from"
+)
+
+func getRules() (*filter.Rules, error) {
+	patterns := []string{}
+	if excludeFrom := viper.GetString(excludeFromFlag); excludeFrom != "" {
+		f, err := os.Open(excludeFrom)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if patterns, err = filter.ParsePatterns(f); err != nil {
+			return nil, err
+		}
+	}
+
+	return filter.NewRules(
+		patterns,
+		viper.GetStringSlice(excludeFlag),
+		viper.GetStringSlice(includeFlag),
+	)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/pojntfx/stfs/internal/check"
+	"github.com/pojntfx/stfs/internal/keyext"
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/keys"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var operationImportCmd = &cobra.Command{
+	Use:   "import [archive]",
+	Short: "Import a tar archive, which can be compressed with gzip, zstd or xz, to tape or tar file (reads stdin if no archive or - is given)",
+	Args:  cobra.MaximumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		if err := check.CheckCompressionLevel(viper.GetString(compressionLevelFlag)); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(recipientFlag)); err != nil {
+			return err
+		}
+
+		return check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(identityFlag))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := keyext.ReadKey(viper.GetString(encryptionFlag), viper.GetString(recipientFlag))
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.ParseRecipient(viper.GetString(encryptionFlag), pubkey)
+		if err != nil {
+			return err
+		}
+
+		privkey, err := keyext.ReadKey(viper.GetString(signatureFlag), viper.GetString(identityFlag))
+		if err != nil {
+			return err
+		}
+
+		identity, err := keys.ParseSignerIdentity(viper.GetString(signatureFlag), privkey, viper.GetString(passwordFlag))
+		if err != nil {
+			return err
+		}
+
+		var archive io.Reader = os.Stdin
+		if len(args) > 0 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			archive = f
+		}
+
+		mt := mtio.MagneticTapeIO{}
+		tm := tape.NewTapeManager(
+			viper.GetString(driveFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			viper.GetBool(overwriteFlag),
+		)
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
+		ops := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
+			},
+
+			config.PipeConfig{
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+				Workers:      viper.GetInt(workersFlag),
+				MemoryBudget: viper.GetInt64(memoryBudgetFlag) * 1024 * 1024,
+			},
+			config.CryptoConfig{
+				Recipient: recipient,
+				Identity:  identity,
+				Password:  viper.GetString(passwordFlag),
+			},
+
+			logging.NewCSVLogger().PrintHeaderEvent,
+			progressLogger.PrintProgressEvent,
+
+			viper.GetBool(dryRunFlag),
+		)
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		_, err = ops.ImportContext(
+			ctx,
+			archive,
+			viper.GetString(compressionLevelFlag),
+			viper.GetBool(overwriteFlag),
+		)
+
+		return err
+	},
+}
+
+func init() {
+	operationImportCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
+	operationImportCmd.PersistentFlags().BoolP(overwriteFlag, "o", false, "Start writing from the start instead of from the end of the tape or tar file")
+	operationImportCmd.PersistentFlags().StringP(compressionLevelFlag, "l", config.CompressionLevelBalancedKey, fmt.Sprintf("Compression level to use (default %v, available are %v)", config.CompressionLevelBalancedKey, config.KnownCompressionLevels))
+	operationImportCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
+	operationImportCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
+	operationImportCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationImportCmd.PersistentFlags().IntP(workersFlag, "w", runtime.NumCPU(), "Amount of files to compress, encrypt and sign concurrently")
+	operationImportCmd.PersistentFlags().Int64(memoryBudgetFlag, 256, "Maximum amount of MiB of compressed and encrypted files to keep in memory until they are written; larger files are spooled to temporary files")
+	operationImportCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
+
+	viper.AutomaticEnv()
+
+	operationCmd.AddCommand(operationImportCmd)
+}
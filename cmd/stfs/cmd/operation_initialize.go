@@ -78,17 +78,23 @@ var operationInitializeCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -97,6 +103,9 @@ var operationInitializeCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			nil,
+
+			viper.GetBool(dryRunFlag),
 		)
 
 		return ops.Initialize("/", os.ModePerm, viper.GetString(compressionLevelFlag))
@@ -109,6 +118,7 @@ func init() {
 	operationInitializeCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
 	operationInitializeCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
 	operationInitializeCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationInitializeCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
 
 	viper.AutomaticEnv()
 
@@ -71,17 +71,23 @@ var operationMoveCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -90,6 +96,9 @@ var operationMoveCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			nil,
+
+			viper.GetBool(dryRunFlag),
 		)
 
 		return ops.Move(viper.GetString(fromFlag), viper.GetString(toFlag))
@@ -103,6 +112,7 @@ func init() {
 	operationMoveCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
 	operationMoveCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
 	operationMoveCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationMoveCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
 
 	viper.AutomaticEnv()
 
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pojntfx/stfs/pkg/filter"
+	"github.com/pojntfx/stfs/pkg/operations"
+)
+
+// getWalkTotals counts the files and bytes which archiving or updating from will read, so that the progress can include an ETA
+func getWalkTotals(ctx context.Context, workdir string, from string, rules *filter.Rules) (int64, int64, error) {
+	getSrc := operations.NewWalkSource(workdir, from, rules)
+
+	files, bytes := int64(0), int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		file, err := getSrc()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		// Sockets are skipped when archiving
+		if file.Info.Mode()&os.ModeSocket != 0 {
+			continue
+		}
+
+		files++
+		if file.Info.Mode().IsRegular() {
+			bytes += file.Info.Size()
+		}
+	}
+
+	return files, bytes, nil
+}
@@ -1,9 +1,10 @@
 package cmd
 
 import (
-	"io"
-	"io/fs"
-	"os"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pojntfx/stfs/internal/check"
 	"github.com/pojntfx/stfs/internal/keyext"
@@ -13,13 +14,21 @@ import (
 	"github.com/pojntfx/stfs/pkg/mtio"
 	"github.com/pojntfx/stfs/pkg/operations"
 	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/sinks"
 	"github.com/pojntfx/stfs/pkg/tape"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 const (
-	flattenFlag = "flatten"
+	flattenFlag    = "flatten"
+	privilegedFlag = "privileged"
+	atFlag         = "at"
+	planFlag       = "plan"
+
+	numericOwnerFlag = "numeric-owner"
+	uidMapFlag       = "uid-map"
+	gidMapFlag       = "gid-map"
 )
 
 var operationRestoreCmd = &cobra.Command{
@@ -31,6 +40,18 @@ var operationRestoreCmd = &cobra.Command{
 			return err
 		}
 
+		if _, err := getAt(); err != nil {
+			return err
+		}
+
+		if _, err := getFilesystemSink(); err != nil {
+			return err
+		}
+
+		if err := check.CheckConflictPolicy(viper.GetString(conflictFlag)); err != nil {
+			return err
+		}
+
 		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
 			return err
 		}
@@ -71,6 +92,21 @@ var operationRestoreCmd = &cobra.Command{
 			return err
 		}
 
+		at, err := getAt()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		if err := metadataPersister.SetSnapshot(ctx, at); err != nil {
+			return err
+		}
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
 		ops := operations.NewOperations(
 			config.BackendConfig{
 				GetWriter:   tm.GetWriter,
@@ -79,6 +115,8 @@ var operationRestoreCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume: changeVolume(tm),
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
@@ -86,10 +124,12 @@ var operationRestoreCmd = &cobra.Command{
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -98,37 +138,145 @@ var operationRestoreCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			progressLogger.PrintProgressEvent,
+
+			false,
 		)
 
-		return ops.Restore(
-			func(path string, mode fs.FileMode) (io.WriteCloser, error) {
-				dstFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, mode)
-				if err != nil {
-					return nil, err
-				}
+		if viper.GetBool(planFlag) {
+			plan, err := ops.PlanRestore(ctx, viper.GetStringSlice(fromFlag), viper.GetString(toFlag), viper.GetBool(flattenFlag))
+			if err != nil {
+				return err
+			}
 
-				if err := dstFile.Truncate(0); err != nil {
-					return nil, err
-				}
+			logging.NewCSVLogger().PrintRestorePlan(plan)
 
-				return dstFile, nil
-			},
-			func(path string, mode fs.FileMode) error {
-				return os.MkdirAll(path, mode)
-			},
+			// The summary is logged so that stdout only contains the CSV rows of the plan
+			logging.NewJSONLogger(viper.GetInt(verboseFlag)).Info("Restore plan", map[string]interface{}{
+				"passes":                    len(plan.Passes),
+				"seeks":                     plan.Movement.Seeks,
+				"volumeChanges":             plan.Movement.VolumeChanges,
+				"seekRecords":               plan.Movement.SeekRecords,
+				"readRecords":               plan.Movement.ReadRecords,
+				"seeksInIndexOrder":         plan.UnplannedMovement.Seeks,
+				"volumeChangesInIndexOrder": plan.UnplannedMovement.VolumeChanges,
+				"seekRecordsInIndexOrder":   plan.UnplannedMovement.SeekRecords,
+				"readRecordsInIndexOrder":   plan.UnplannedMovement.ReadRecords,
+			})
+
+			return nil
+		}
+
+		sink, err := getFilesystemSink()
+		if err != nil {
+			return err
+		}
+
+		conflicts := &conflictSummary{}
+		defer conflicts.print()
 
-			viper.GetString(fromFlag),
+		if err := ops.RestoreManyContext(
+			ctx,
+
+			sink,
+
+			viper.GetStringSlice(fromFlag),
 			viper.GetString(toFlag),
 			viper.GetBool(flattenFlag),
-		)
+			viper.GetString(conflictFlag),
+
+			conflicts.onConflict,
+		); err != nil {
+			// Also set the metadata of the directories which have been restored until then
+			_ = sink.Close()
+
+			return err
+		}
+
+		return sink.Close()
 	},
 }
 
+// getFilesystemSink returns the sink which restores to the file system with the ownership of the flags
+func getFilesystemSink() (*sinks.FilesystemSink, error) {
+	uidMap, err := parseIDMap(viper.GetStringSlice(uidMapFlag))
+	if err != nil {
+		return nil, err
+	}
+
+	gidMap, err := parseIDMap(viper.GetStringSlice(gidMapFlag))
+	if err != nil {
+		return nil, err
+	}
+
+	return sinks.NewFilesystemSink(
+		viper.GetBool(numericOwnerFlag),
+		uidMap,
+		gidMap,
+		viper.GetBool(privilegedFlag),
+	), nil
+}
+
+// parseIDMap parses mappings of archived UIDs or GIDs to other ones, i.e. 1000:1001
+func parseIDMap(mappings []string) (map[int]int, error) {
+	ids := map[int]int{}
+	for _, mapping := range mappings {
+		parts := strings.Split(mapping, ":")
+		if len(parts) != 2 {
+			return nil, config.ErrIDMapInvalid
+		}
+
+		from, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, config.ErrIDMapInvalid
+		}
+
+		to, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, config.ErrIDMapInvalid
+		}
+
+		ids[from] = to
+	}
+
+	return ids, nil
+}
+
+// getAt returns the session of the point in time to use, which can also be given as a RFC3339 timestamp; 0 is the latest state
+func getAt() (int64, error) {
+	at := viper.GetString(atFlag)
+	if at == "" {
+		return 0, nil
+	}
+
+	if session, err := strconv.ParseInt(at, 10, 64); err == nil {
+		if session <= 0 {
+			return -1, config.ErrAtInvalid
+		}
+
+		return session, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return -1, config.ErrAtInvalid
+	}
+
+	return t.UnixNano(), nil
+}
+
 func init() {
 	operationRestoreCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
-	operationRestoreCmd.PersistentFlags().StringP(fromFlag, "f", "", "File or directory to restore")
+	operationRestoreCmd.PersistentFlags().StringArrayP(fromFlag, "f", []string{}, "File or directory to restore (can be specified multiple times to restore them in one pass)")
 	operationRestoreCmd.PersistentFlags().StringP(toFlag, "t", "", "File or directory restore to (archived name by default)")
 	operationRestoreCmd.PersistentFlags().BoolP(flattenFlag, "a", false, "Ignore the folder hierarchy on the tape or tar file")
+	operationRestoreCmd.PersistentFlags().String(atFlag, "", "Restore the file or directory as it was at this session or RFC3339 timestamp (latest by default)")
+	operationRestoreCmd.PersistentFlags().Bool(planFlag, false, "Only print the order in which the files would be restored and the estimated tape movement instead of restoring them")
+	operationRestoreCmd.PersistentFlags().String(conflictFlag, config.ConflictPolicyOverwrite, fmt.Sprintf("What to do with files which already exist (available are %v)", config.KnownConflictPolicies))
+	operationRestoreCmd.PersistentFlags().Bool(privilegedFlag, false, "Also restore extended attributes which require privileges, such as SELinux labels, POSIX ACLs and file capabilities")
+	operationRestoreCmd.PersistentFlags().Bool(numericOwnerFlag, false, "Restore the archived UIDs and GIDs instead of looking up the archived user and group names")
+	operationRestoreCmd.PersistentFlags().StringArray(uidMapFlag, []string{}, "Restore files of an archived UID with another UID, i.e. 1000:1001 (can be specified multiple times)")
+	operationRestoreCmd.PersistentFlags().StringArray(gidMapFlag, []string{}, "Restore files of an archived GID with another GID, i.e. 1000:1001 (can be specified multiple times)")
 	operationRestoreCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
 	operationRestoreCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
 	operationRestoreCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to the public key to verify with")
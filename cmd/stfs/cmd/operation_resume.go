@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/spf13/viper"
+)
+
+// archiveState is what is needed to resume an archive run; it is stored next to the metadata database until the run has finished
+type archiveState struct {
+	Session string
+	Workdir string
+
+	From             string
+	Exclude          []string
+	Include          []string
+	ExcludeFrom      string
+	CompressionLevel string
+}
+
+func getArchiveStatePath(metadata string) string {
+	return metadata + ".resume"
+}
+
+// resumeArchiveState restores the flags of the interrupted archive run and returns its session and working directory
+func resumeArchiveState(path string) (string, string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", config.ErrResumeStateMissing
+		}
+
+		return "", "", err
+	}
+
+	state := &archiveState{}
+	if err := json.Unmarshal(content, state); err != nil {
+		return "", "", err
+	}
+
+	// The names of the files are relative to the working directory of the interrupted run, so resolve its paths from there too
+	excludeFrom := state.ExcludeFrom
+	if excludeFrom != "" && !filepath.IsAbs(excludeFrom) {
+		excludeFrom = filepath.Join(state.Workdir, excludeFrom)
+	}
+
+	viper.Set(fromFlag, state.From)
+	viper.Set(excludeFlag, state.Exclude)
+	viper.Set(includeFlag, state.Include)
+	viper.Set(excludeFromFlag, excludeFrom)
+	viper.Set(compressionLevelFlag, state.CompressionLevel)
+
+	return state.Session, state.Workdir, nil
+}
+
+// saveArchiveState stores the flags of the archive run; if workdir is empty, the working directory is stored
+func saveArchiveState(path string, session string, workdir string) error {
+	if workdir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		workdir = wd
+	}
+
+	content, err := json.Marshal(&archiveState{
+		Session: session,
+		Workdir: workdir,
+
+		From:             viper.GetString(fromFlag),
+		Exclude:          viper.GetStringSlice(excludeFlag),
+		Include:          viper.GetStringSlice(includeFlag),
+		ExcludeFrom:      viper.GetString(excludeFromFlag),
+		CompressionLevel: viper.GetString(compressionLevelFlag),
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"github.com/pojntfx/stfs/internal/check"
+	"github.com/pojntfx/stfs/internal/keyext"
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/keys"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var operationUndeleteCmd = &cobra.Command{
+	Use:     "undelete",
+	Aliases: []string{"undel", "unrm"},
+	Short:   "Bring back a deleted file or directory on tape or tar file",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(recipientFlag)); err != nil {
+			return err
+		}
+
+		return check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(identityFlag))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := keyext.ReadKey(viper.GetString(encryptionFlag), viper.GetString(recipientFlag))
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.ParseRecipient(viper.GetString(encryptionFlag), pubkey)
+		if err != nil {
+			return err
+		}
+
+		privkey, err := keyext.ReadKey(viper.GetString(signatureFlag), viper.GetString(identityFlag))
+		if err != nil {
+			return err
+		}
+
+		identity, err := keys.ParseSignerIdentity(viper.GetString(signatureFlag), privkey, viper.GetString(passwordFlag))
+		if err != nil {
+			return err
+		}
+
+		mt := mtio.MagneticTapeIO{}
+		tm := tape.NewTapeManager(
+			viper.GetString(driveFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			false,
+		)
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		ops := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
+			},
+
+			config.PipeConfig{
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+			},
+			config.CryptoConfig{
+				Recipient: recipient,
+				Identity:  identity,
+				Password:  viper.GetString(passwordFlag),
+			},
+
+			logging.NewCSVLogger().PrintHeaderEvent,
+			nil,
+
+			viper.GetBool(dryRunFlag),
+		)
+
+		return ops.Undelete(viper.GetString(nameFlag))
+	},
+}
+
+func init() {
+	operationUndeleteCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
+	operationUndeleteCmd.PersistentFlags().StringP(nameFlag, "n", "", "Name of the file or directory to bring back")
+	operationUndeleteCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
+	operationUndeleteCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
+	operationUndeleteCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationUndeleteCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
+
+	viper.AutomaticEnv()
+
+	operationCmd.AddCommand(operationUndeleteCmd)
+}
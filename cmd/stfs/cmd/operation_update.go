@@ -2,15 +2,12 @@ package cmd
 
 import (
 	"fmt"
-	"io"
-	"io/fs"
-	"os"
-	"path/filepath"
 
 	"github.com/pojntfx/stfs/internal/check"
 	"github.com/pojntfx/stfs/internal/keyext"
 	"github.com/pojntfx/stfs/internal/logging"
 	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/filter"
 	"github.com/pojntfx/stfs/pkg/keys"
 	"github.com/pojntfx/stfs/pkg/mtio"
 	"github.com/pojntfx/stfs/pkg/operations"
@@ -20,6 +17,12 @@ import (
 	"github.com/spf13/viper"
 )
 
+const (
+	incrementalFlag = "incremental"
+	hashFlag        = "hash"
+	baselineFlag    = "baseline"
+)
+
 var operationUpdateCmd = &cobra.Command{
 	Use:     "update",
 	Aliases: []string{"upd", "u", "put"},
@@ -73,6 +76,9 @@ var operationUpdateCmd = &cobra.Command{
 			return err
 		}
 
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
 		ops := operations.NewOperations(
 			config.BackendConfig{
 				GetWriter:   tm.GetWriter,
@@ -81,17 +87,23 @@ var operationUpdateCmd = &cobra.Command{
 				GetReader:   tm.GetReader,
 				CloseReader: tm.Close,
 
+				ChangeVolume:   changeVolume(tm),
+				TruncateVolume: tm.TruncateVolume,
+
 				MagneticTapeIO: mt,
 			},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -100,53 +112,49 @@ var operationUpdateCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeaderEvent,
+			progressLogger.PrintProgressEvent,
+
+			viper.GetBool(dryRunFlag),
 		)
 
-		files := make(chan config.FileConfig)
-		errs := make(chan error)
-		go func() {
-			if err := filepath.Walk(viper.GetString(fromFlag), func(path string, info fs.FileInfo, err error) error {
-				path = filepath.ToSlash(path)
-
-				if err != nil {
-					return err
-				}
-
-				link := ""
-				if info.Mode()&os.ModeSymlink == os.ModeSymlink {
-					if link, err = os.Readlink(path); err != nil {
-						return err
-					}
-				}
-
-				files <- config.FileConfig{
-					GetFile: func() (io.ReadSeekCloser, error) {
-						return os.Open(path)
-					},
-					Info: info,
-					Path: filepath.ToSlash(path),
-					Link: filepath.ToSlash(link),
-				}
-
-				return nil
-			}); err != nil {
-				errs <- err
-
-				return
+		rules, err := getRules()
+		if err != nil {
+			return err
+		}
+
+		getSrc := operations.NewWalkSource("", viper.GetString(fromFlag), rules)
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		if progressLogger.Enabled() {
+			files, bytes, err := getWalkTotals(ctx, "", viper.GetString(fromFlag), rules)
+			if err != nil {
+				return err
 			}
 
-			errs <- io.EOF
-		}()
-
-		if _, err := ops.Update(
-			func() (config.FileConfig, error) {
-				select {
-				case file := <-files:
-					return file, err
-				case err := <-errs:
-					return config.FileConfig{}, err
-				}
-			},
+			progressLogger.SetTotal(files, bytes)
+		}
+
+		if viper.GetBool(incrementalFlag) || viper.GetString(baselineFlag) != "" {
+			if _, err := ops.UpdateIncrementalContext(
+				ctx,
+				viper.GetString(fromFlag),
+				getSrc,
+				operations.NewWalkFilter("", viper.GetString(fromFlag), rules),
+				viper.GetString(compressionLevelFlag),
+				viper.GetBool(hashFlag),
+				viper.GetString(baselineFlag),
+			); err != nil {
+				return err
+			}
+
+			return nil
+		}
+
+		if _, err := ops.UpdateContext(
+			ctx,
+			getSrc,
 			viper.GetString(compressionLevelFlag),
 			viper.GetBool(overwriteFlag),
 			false,
@@ -166,6 +174,14 @@ func init() {
 	operationUpdateCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to public key of recipient to encrypt for")
 	operationUpdateCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key to sign with")
 	operationUpdateCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	operationUpdateCmd.PersistentFlags().Bool(incrementalFlag, false, "Only archive new or changed files and delete files which have disappeared")
+	operationUpdateCmd.PersistentFlags().Bool(hashFlag, false, "Also compare the content hashes of files with unchanged metadata (requires --incremental or --baseline)")
+	operationUpdateCmd.PersistentFlags().String(baselineFlag, "", "Session to compare against instead of the index (differential mode, implies --incremental)")
+
+	operationUpdateCmd.PersistentFlags().StringArrayP(excludeFlag, "x", []string{}, "Gitignore-style pattern of files to exclude (can be specified multiple times)")
+	operationUpdateCmd.PersistentFlags().StringArray(includeFlag, []string{}, "Gitignore-style pattern of files to include even if they are excluded by another pattern; has no effect on its own (can be specified multiple times)")
+	operationUpdateCmd.PersistentFlags().String(excludeFromFlag, "", fmt.Sprintf("Path to a file with gitignore-style patterns of files to exclude (per-directory %v files are always used)", filter.IgnoreFileName))
+	operationUpdateCmd.PersistentFlags().Bool(dryRunFlag, false, "Only print the headers which would be written and indexed instead of touching the tape or tar file and the index")
 
 	viper.AutomaticEnv()
 
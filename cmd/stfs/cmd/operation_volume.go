@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/viper"
+)
+
+func changeVolume(tm *tape.TapeManager) func(volume int) error {
+	current := 0
+
+	return func(volume int) error {
+		if volume == current {
+			return nil
+		}
+
+		if err := tm.ChangeVolume(volume); err != nil {
+			return err
+		}
+		current = volume
+
+		return waitForVolume(volume)
+	}
+}
+
+func waitForVolume(volume int) error {
+	// Tar files use one file per volume, so there is nothing to wait for
+	if stat, err := os.Stat(viper.GetString(driveFlag)); err != nil || stat.Mode().IsRegular() {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Insert volume %v into %v and press enter to continue\n", volume, viper.GetString(driveFlag))
+
+	_, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	return err
+}
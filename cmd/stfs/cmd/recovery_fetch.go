@@ -1,14 +1,13 @@
 package cmd
 
 import (
-	"io"
-	"io/fs"
-	"os"
+	"fmt"
 
 	"github.com/pojntfx/stfs/internal/check"
 	"github.com/pojntfx/stfs/internal/keyext"
 	"github.com/pojntfx/stfs/internal/logging"
 	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/hardware"
 	"github.com/pojntfx/stfs/pkg/keys"
 	"github.com/pojntfx/stfs/pkg/mtio"
 	"github.com/pojntfx/stfs/pkg/recovery"
@@ -22,6 +21,7 @@ const (
 	blockFlag   = "block"
 	toFlag      = "to"
 	previewFlag = "preview"
+	volumeFlag  = "volume"
 )
 
 var recoveryFetchCmd = &cobra.Command{
@@ -32,6 +32,14 @@ var recoveryFetchCmd = &cobra.Command{
 			return err
 		}
 
+		if _, err := getFilesystemSink(); err != nil {
+			return err
+		}
+
+		if err := check.CheckConflictPolicy(viper.GetString(conflictFlag)); err != nil {
+			return err
+		}
+
 		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
 			return err
 		}
@@ -60,24 +68,74 @@ var recoveryFetchCmd = &cobra.Command{
 		}
 
 		reader, readerIsRegular, err := tape.OpenTapeReadOnly(
-			viper.GetString(driveFlag),
+			tape.GetVolumePath(viper.GetString(driveFlag), viper.GetInt(volumeFlag)),
 		)
 		if err != nil {
 			return nil
 		}
-		defer reader.Close()
+		defer func() {
+			_ = reader.Close()
+		}()
+
+		mt := mtio.MagneticTapeIO{}
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
+		sink, err := getFilesystemSink()
+		if err != nil {
+			return err
+		}
+
+		conflicts := &conflictSummary{}
+
+		if err := recovery.FetchContext(
+			ctx,
 
-		return recovery.Fetch(
 			config.DriveReaderConfig{
 				Drive:          reader,
 				DriveIsRegular: readerIsRegular,
+				Volume:         viper.GetInt(volumeFlag),
+			},
+			mt,
+			func(volume int) (config.DriveReaderConfig, error) {
+				if !readerIsRegular {
+					if err := hardware.Eject(mt, reader.Fd()); err != nil {
+						return config.DriveReaderConfig{}, err
+					}
+				}
+
+				if err := reader.Close(); err != nil {
+					return config.DriveReaderConfig{}, err
+				}
+
+				if err := waitForVolume(volume); err != nil {
+					return config.DriveReaderConfig{}, err
+				}
+
+				reader, readerIsRegular, err = tape.OpenTapeReadOnly(
+					tape.GetVolumePath(viper.GetString(driveFlag), volume),
+				)
+				if err != nil {
+					return config.DriveReaderConfig{}, err
+				}
+
+				return config.DriveReaderConfig{
+					Drive:          reader,
+					DriveIsRegular: readerIsRegular,
+					Volume:         volume,
+				}, nil
 			},
-			mtio.MagneticTapeIO{},
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -85,29 +143,27 @@ var recoveryFetchCmd = &cobra.Command{
 				Password:  viper.GetString(passwordFlag),
 			},
 
-			func(path string, mode fs.FileMode) (io.WriteCloser, error) {
-				dstFile, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, mode)
-				if err != nil {
-					return nil, err
-				}
-
-				if err := dstFile.Truncate(0); err != nil {
-					return nil, err
-				}
-
-				return dstFile, nil
-			},
-			func(path string, mode fs.FileMode) error {
-				return os.MkdirAll(path, mode)
-			},
+			sink,
 
 			viper.GetInt(recordFlag),
 			viper.GetInt(blockFlag),
 			viper.GetString(toFlag),
 			viper.GetBool(previewFlag),
+			viper.GetString(conflictFlag),
 
 			logging.NewCSVLogger().PrintHeader,
-		)
+			printCorrectionEvent,
+			conflicts.onConflict,
+			progressLogger.PrintProgressEvent,
+		); err != nil {
+			return err
+		}
+
+		if !viper.GetBool(previewFlag) {
+			conflicts.print()
+		}
+
+		return sink.Close()
 	},
 }
 
@@ -115,8 +171,14 @@ func init() {
 	recoveryFetchCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
 	recoveryFetchCmd.PersistentFlags().IntP(recordFlag, "k", 0, "Record to seek too")
 	recoveryFetchCmd.PersistentFlags().IntP(blockFlag, "b", 0, "Block in record to seek too")
+	recoveryFetchCmd.PersistentFlags().Int(volumeFlag, 0, "Volume to seek in")
 	recoveryFetchCmd.PersistentFlags().StringP(toFlag, "t", "", "File to restore to (archived name by default)")
 	recoveryFetchCmd.PersistentFlags().BoolP(previewFlag, "w", false, "Only read the header")
+	recoveryFetchCmd.PersistentFlags().String(conflictFlag, config.ConflictPolicyOverwrite, fmt.Sprintf("What to do if the file already exists (available are %v)", config.KnownConflictPolicies))
+	recoveryFetchCmd.PersistentFlags().Bool(privilegedFlag, false, "Also restore extended attributes which require privileges, such as SELinux labels, POSIX ACLs and file capabilities")
+	recoveryFetchCmd.PersistentFlags().Bool(numericOwnerFlag, false, "Restore the archived UID and GID instead of looking up the archived user and group name")
+	recoveryFetchCmd.PersistentFlags().StringArray(uidMapFlag, []string{}, "Restore the file with another UID than the archived one, i.e. 1000:1001 (can be specified multiple times)")
+	recoveryFetchCmd.PersistentFlags().StringArray(gidMapFlag, []string{}, "Restore the file with another GID than the archived one, i.e. 1000:1001 (can be specified multiple times)")
 	recoveryFetchCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
 	recoveryFetchCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
 	recoveryFetchCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to the public key to verify with")
@@ -54,7 +54,7 @@ var recoveryIndexCmd = &cobra.Command{
 		}
 
 		reader, readerIsRegular, err := tape.OpenTapeReadOnly(
-			viper.GetString(driveFlag),
+			tape.GetVolumePath(viper.GetString(driveFlag), viper.GetInt(volumeFlag)),
 		)
 		if err != nil {
 			return nil
@@ -66,20 +66,31 @@ var recoveryIndexCmd = &cobra.Command{
 			return err
 		}
 
-		return recovery.Index(
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
+		return recovery.IndexContext(
+			ctx,
+
 			config.DriveReaderConfig{
 				Drive:          reader,
 				DriveIsRegular: readerIsRegular,
+				Volume:         viper.GetInt(volumeFlag),
 			},
 			mtio.MagneticTapeIO{},
 			config.MetadataConfig{
 				Metadata: metadataPersister,
 			},
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -101,6 +112,8 @@ var recoveryIndexCmd = &cobra.Command{
 			},
 
 			logging.NewCSVLogger().PrintHeader,
+			printCorrectionEvent,
+			progressLogger.PrintProgressEvent,
 		)
 	},
 }
@@ -109,6 +122,7 @@ func init() {
 	recoveryIndexCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
 	recoveryIndexCmd.PersistentFlags().IntP(recordFlag, "k", 0, "Record to seek too before counting")
 	recoveryIndexCmd.PersistentFlags().IntP(blockFlag, "b", 0, "Block in record to seek too before counting")
+	recoveryIndexCmd.PersistentFlags().Int(volumeFlag, 0, "Volume to index")
 	recoveryIndexCmd.PersistentFlags().BoolP(overwriteFlag, "o", false, "Remove the old index before starting to index")
 	recoveryIndexCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
 	recoveryIndexCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
@@ -56,17 +56,24 @@ var recoveryQueryCmd = &cobra.Command{
 		}
 		defer reader.Close()
 
-		if _, err := recovery.Query(
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		if _, err := recovery.QueryContext(
+			ctx,
+
 			config.DriveReaderConfig{
 				Drive:          reader,
 				DriveIsRegular: readerIsRegular,
 			},
 			mtio.MagneticTapeIO{},
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -78,6 +85,7 @@ var recoveryQueryCmd = &cobra.Command{
 			viper.GetInt(blockFlag),
 
 			logging.NewCSVLogger().PrintHeader,
+			printCorrectionEvent,
 		); err != nil {
 			return err
 		}
@@ -1,6 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/pojntfx/stfs/pkg/config"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -16,3 +20,7 @@ func init() {
 
 	rootCmd.AddCommand(recoveryCmd)
 }
+
+func printCorrectionEvent(event *config.CorrectionEvent) {
+	fmt.Fprintf(os.Stderr, "Rebuilt %v damaged records from parity in the group starting at record %v on volume %v\n", event.Corrected, event.Record, event.Volume)
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pojntfx/stfs/internal/check"
+	"github.com/pojntfx/stfs/internal/keyext"
+	"github.com/pojntfx/stfs/internal/logging"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/keys"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/tape"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	scrubIntervalFlag = "scrub-interval"
+)
+
+var recoveryVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Read all indexed files from tape or tar file and compare the hashes of their content",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := viper.BindPFlags(cmd.PersistentFlags()); err != nil {
+			return err
+		}
+
+		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
+			return err
+		}
+
+		return check.CheckKeyAccessible(viper.GetString(signatureFlag), viper.GetString(recipientFlag))
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := keyext.ReadKey(viper.GetString(signatureFlag), viper.GetString(recipientFlag))
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.ParseSignerRecipient(viper.GetString(signatureFlag), pubkey)
+		if err != nil {
+			return err
+		}
+
+		privkey, err := keyext.ReadKey(viper.GetString(encryptionFlag), viper.GetString(identityFlag))
+		if err != nil {
+			return err
+		}
+
+		identity, err := keys.ParseIdentity(viper.GetString(encryptionFlag), privkey, viper.GetString(passwordFlag))
+		if err != nil {
+			return err
+		}
+
+		mt := mtio.MagneticTapeIO{}
+		tm := tape.NewTapeManager(
+			viper.GetString(driveFlag),
+			mt,
+			viper.GetInt(recordSizeFlag),
+			false,
+		)
+
+		metadataPersister := persisters.NewMetadataPersister(viper.GetString(metadataFlag))
+		if err := metadataPersister.Open(); err != nil {
+			return err
+		}
+
+		progressLogger := logging.NewProgressLogger(viper.GetInt(verboseFlag))
+		defer progressLogger.Close()
+
+		ops := operations.NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume: changeVolume(tm),
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+			},
+
+			config.PipeConfig{
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
+			},
+			config.CryptoConfig{
+				Recipient: recipient,
+				Identity:  identity,
+				Password:  viper.GetString(passwordFlag),
+			},
+
+			nil,
+			progressLogger.PrintProgressEvent,
+
+			false,
+		)
+
+		ctx, cancel := getInterruptContext(cmd)
+		defer cancel()
+
+		logger := logging.NewCSVLogger()
+		interval := viper.GetDuration(scrubIntervalFlag)
+		for {
+			err := ops.VerifyContext(ctx, logger.PrintVerifyEvent)
+			progressLogger.Close()
+			if interval <= 0 {
+				return err
+			}
+
+			// Keep scrubbing if files are corrupted so that further damage is detected too
+			if err != nil {
+				if !errors.Is(err, config.ErrIntegrityCheckFailed) {
+					return err
+				}
+
+				logging.NewJSONLogger(viper.GetInt(verboseFlag)).Error("Scrub failed", map[string]interface{}{
+					"err": err.Error(),
+				})
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	},
+}
+
+func init() {
+	recoveryVerifyCmd.PersistentFlags().IntP(recordSizeFlag, "z", 20, "Amount of 512-bit blocks per record")
+	recoveryVerifyCmd.PersistentFlags().Duration(scrubIntervalFlag, 0, "Repeat the verification at this interval to detect bit rot, i.e. 168h (0 verifies once)")
+	recoveryVerifyCmd.PersistentFlags().StringP(identityFlag, "i", "", "Path to private key of recipient that has been encrypted for")
+	recoveryVerifyCmd.PersistentFlags().StringP(passwordFlag, "p", "", "Password for the private key")
+	recoveryVerifyCmd.PersistentFlags().StringP(recipientFlag, "r", "", "Path to the public key to verify with")
+
+	viper.AutomaticEnv()
+
+	recoveryCmd.AddCommand(recoveryVerifyCmd)
+}
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/pojntfx/stfs/internal/check"
 	"github.com/pojntfx/stfs/internal/logging"
@@ -15,12 +18,14 @@ import (
 )
 
 const (
-	driveFlag       = "drive"
-	metadataFlag    = "metadata"
-	verboseFlag     = "verbose"
-	compressionFlag = "compression"
-	encryptionFlag  = "encryption"
-	signatureFlag   = "signature"
+	driveFlag        = "drive"
+	metadataFlag     = "metadata"
+	verboseFlag      = "verbose"
+	compressionFlag  = "compression"
+	encryptionFlag   = "encryption"
+	signatureFlag    = "signature"
+	dataShardsFlag   = "data-shards"
+	parityShardsFlag = "parity-shards"
 )
 
 var rootCmd = &cobra.Command{
@@ -55,6 +60,16 @@ https://github.com/pojntfx/stfs`,
 	},
 }
 
+// getInterruptContext returns a context which is cancelled on SIGINT or SIGTERM, which allows operations to finish the tape or tar file and the index before exiting
+func getInterruptContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+}
+
+// getJournalPath returns the path of the journal of unindexed writes, which is stored next to the metadata database
+func getJournalPath(metadata string) string {
+	return metadata + ".journal"
+}
+
 func Execute() error {
 	// Get default working dir
 	home, err := os.UserHomeDir()
@@ -69,6 +84,8 @@ func Execute() error {
 	rootCmd.PersistentFlags().StringP(compressionFlag, "c", config.NoneKey, fmt.Sprintf("Compression format to use (default %v, available are %v)", config.NoneKey, config.KnownCompressionFormats))
 	rootCmd.PersistentFlags().StringP(encryptionFlag, "e", config.NoneKey, fmt.Sprintf("Encryption format to use (default %v, available are %v)", config.NoneKey, config.KnownEncryptionFormats))
 	rootCmd.PersistentFlags().StringP(signatureFlag, "s", config.NoneKey, fmt.Sprintf("Signature format to use (default %v, available are %v)", config.NoneKey, config.KnownSignatureFormats))
+	rootCmd.PersistentFlags().Int(dataShardsFlag, 16, "Amount of data records per group of parity records")
+	rootCmd.PersistentFlags().Int(parityShardsFlag, 0, "Amount of Reed-Solomon parity records to write after every group of data records, which allows rebuilding as many damaged records per group (0 disables parity)")
 
 	if err := viper.BindPFlags(rootCmd.PersistentFlags()); err != nil {
 		return err
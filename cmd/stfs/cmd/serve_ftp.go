@@ -53,6 +53,10 @@ var serveFTPCmd = &cobra.Command{
 			return err
 		}
 
+		if _, err := getAt(); err != nil {
+			return err
+		}
+
 		if err := check.CheckWriteCacheType(viper.GetString(cacheWriteFlag)); err != nil {
 			return err
 		}
@@ -125,16 +129,24 @@ var serveFTPCmd = &cobra.Command{
 			return err
 		}
 
+		at, err := getAt()
+		if err != nil {
+			return err
+		}
+
 		jsonLogger := logging.NewJSONLogger(viper.GetInt(verboseFlag))
 
 		metadataConfig := config.MetadataConfig{
 			Metadata: metadataPersister,
+			Journal:  persisters.NewJournalPersister(getJournalPath(viper.GetString(metadataFlag))),
 		}
 		pipeConfig := config.PipeConfig{
-			Compression: viper.GetString(compressionFlag),
-			Encryption:  viper.GetString(encryptionFlag),
-			Signature:   viper.GetString(signatureFlag),
-			RecordSize:  viper.GetInt(recordSizeFlag),
+			Compression:  viper.GetString(compressionFlag),
+			Encryption:   viper.GetString(encryptionFlag),
+			Signature:    viper.GetString(signatureFlag),
+			RecordSize:   viper.GetInt(recordSizeFlag),
+			DataShards:   viper.GetInt(dataShardsFlag),
+			ParityShards: viper.GetInt(parityShardsFlag),
 		}
 		backendConfig := config.BackendConfig{
 			GetWriter:   tm.GetWriter,
@@ -143,6 +155,8 @@ var serveFTPCmd = &cobra.Command{
 			GetReader:   tm.GetReader,
 			CloseReader: tm.Close,
 
+			TruncateVolume: tm.TruncateVolume,
+
 			MagneticTapeIO: mt,
 		}
 		readCryptoConfig := config.CryptoConfig{
@@ -161,6 +175,9 @@ var serveFTPCmd = &cobra.Command{
 			func(event *config.HeaderEvent) {
 				jsonLogger.Debug("Header read", event)
 			},
+			nil,
+
+			false,
 		)
 
 		writeOps := operations.NewOperations(
@@ -177,6 +194,9 @@ var serveFTPCmd = &cobra.Command{
 			func(event *config.HeaderEvent) {
 				jsonLogger.Debug("Header write", event)
 			},
+			nil,
+
+			false,
 		)
 
 		stfs := fs.NewSTFS(
@@ -195,6 +215,7 @@ var serveFTPCmd = &cobra.Command{
 				)
 			},
 			viper.GetBool(readOnlyFlag),
+			at,
 			true, // FTP requires read permission even if `O_WRONLY` is set if cache is enabled, as the cache needs to read the written file
 
 			func(hdr *config.Header) {
@@ -255,6 +276,7 @@ func init() {
 	serveFTPCmd.PersistentFlags().StringP(cacheWriteFlag, "q", config.WriteCacheTypeFile, fmt.Sprintf("Write cache to use (default %v, available are %v)", config.WriteCacheTypeFile, config.KnownWriteCacheTypes))
 	serveFTPCmd.PersistentFlags().DurationP(cacheDurationFlag, "u", time.Hour, "Duration until cache is invalidated")
 	serveFTPCmd.PersistentFlags().StringP(cacheDirFlag, "w", cacheDir, "Directory to use if dir cache is enabled")
+	serveFTPCmd.PersistentFlags().String(atFlag, "", "Serve the files read-only as they were at this session or RFC3339 timestamp (latest by default)")
 	serveFTPCmd.PersistentFlags().BoolP(readOnlyFlag, "j", false, "Block all write operations")
 
 	viper.AutomaticEnv()
@@ -44,6 +44,10 @@ var serveHTTPCmd = &cobra.Command{
 			return err
 		}
 
+		if _, err := getAt(); err != nil {
+			return err
+		}
+
 		if err := check.CheckKeyAccessible(viper.GetString(encryptionFlag), viper.GetString(identityFlag)); err != nil {
 			return err
 		}
@@ -84,6 +88,11 @@ var serveHTTPCmd = &cobra.Command{
 			return err
 		}
 
+		at, err := getAt()
+		if err != nil {
+			return err
+		}
+
 		jsonLogger := logging.NewJSONLogger(viper.GetInt(verboseFlag))
 
 		readOps := operations.NewOperations(
@@ -101,10 +110,12 @@ var serveHTTPCmd = &cobra.Command{
 			},
 
 			config.PipeConfig{
-				Compression: viper.GetString(compressionFlag),
-				Encryption:  viper.GetString(encryptionFlag),
-				Signature:   viper.GetString(signatureFlag),
-				RecordSize:  viper.GetInt(recordSizeFlag),
+				Compression:  viper.GetString(compressionFlag),
+				Encryption:   viper.GetString(encryptionFlag),
+				Signature:    viper.GetString(signatureFlag),
+				RecordSize:   viper.GetInt(recordSizeFlag),
+				DataShards:   viper.GetInt(dataShardsFlag),
+				ParityShards: viper.GetInt(parityShardsFlag),
 			},
 			config.CryptoConfig{
 				Recipient: recipient,
@@ -115,6 +126,9 @@ var serveHTTPCmd = &cobra.Command{
 			func(event *config.HeaderEvent) {
 				jsonLogger.Debug("Header read", event)
 			},
+			nil,
+
+			false,
 		)
 
 		stfs := fs.NewSTFS(
@@ -125,9 +139,10 @@ var serveHTTPCmd = &cobra.Command{
 				Metadata: metadataPersister,
 			},
 
-			"",    // We never write
-			nil,   // We never write
-			true,  // We never write
+			"",   // We never write
+			nil,  // We never write
+			true, // We never write
+			at,
 			false, // We never write
 
 			func(hdr *config.Header) {
@@ -176,6 +191,7 @@ func init() {
 	serveHTTPCmd.PersistentFlags().StringP(laddrFlag, "a", ":1337", "Listen address")
 	serveHTTPCmd.PersistentFlags().StringP(cacheFileSystemFlag, "n", config.NoneKey, fmt.Sprintf("File system cache to use (default %v, available are %v)", config.NoneKey, config.KnownFileSystemCacheTypes))
 	serveHTTPCmd.PersistentFlags().DurationP(cacheDurationFlag, "u", time.Hour, "Duration until cache is invalidated")
+	serveHTTPCmd.PersistentFlags().String(atFlag, "", "Serve the files read-only as they were at this session or RFC3339 timestamp (latest by default)")
 	serveHTTPCmd.PersistentFlags().StringP(cacheDirFlag, "w", cacheDir, "Directory to use if dir cache is enabled")
 
 	viper.AutomaticEnv()
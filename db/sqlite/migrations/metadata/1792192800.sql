@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Volume (tape or tar file) of this header
+alter table headers add column volume integer not null default 0;
+-- Volume of the last update header of this header
+alter table headers add column lastknownvolume integer not null default 0;
+-- +migrate Down
+alter table headers drop column lastknownvolume;
+alter table headers drop column volume;
@@ -0,0 +1,35 @@
+-- +migrate Up
+create table history (
+    -- Order in which the versions have been indexed
+    id integer not null primary key autoincrement,
+    -- Session of the operation which created this version
+    session integer not null,
+    -- The other columns are a copy of the header after the operation has been indexed
+    record integer not null,
+    lastknownrecord integer not null,
+    block integer not null,
+    lastknownblock integer not null,
+    deleted integer not null,
+    typeflag integer not null,
+    name text not null,
+    linkname text not null,
+    size integer not null,
+    mode integer not null,
+    uid integer not null,
+    gid integer not null,
+    uname text not null,
+    gname text not null,
+    modtime date not null,
+    accesstime date not null,
+    changetime date not null,
+    devmajor integer not null,
+    devminor integer not null,
+    paxrecords text not null,
+    format integer not null,
+    volume integer not null,
+    lastknownvolume integer not null
+);
+create index history_name on history (name);
+-- +migrate Down
+drop index history_name;
+drop table history;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Hash of the content of this header (the `STFS.Hash` PAX record), used to find identical content to reference
+alter table headers add column hash text not null default '';
+update headers set hash = coalesce(json_extract(paxrecords, '$."STFS.Hash"'), '') where json_valid(paxrecords);
+create index headers_hash on headers (hash);
+-- +migrate Down
+drop index headers_hash;
+alter table headers drop column hash;
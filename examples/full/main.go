@@ -140,6 +140,9 @@ func createFs(
 		func(event *config.HeaderEvent) {
 			jsonLogger.Debug("Header read", event)
 		},
+		nil,
+
+		false,
 	)
 
 	writeOps := operations.NewOperations(
@@ -156,6 +159,9 @@ func createFs(
 		func(event *config.HeaderEvent) {
 			jsonLogger.Debug("Header write", event)
 		},
+		nil,
+
+		false,
 	)
 
 	stfs := fs.NewSTFS(
@@ -174,6 +180,7 @@ func createFs(
 			)
 		},
 		readOnly,
+		0,
 		false,
 
 		func(hdr *config.Header) {
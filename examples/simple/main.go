@@ -72,6 +72,9 @@ func main() {
 		func(event *config.HeaderEvent) {
 			l.Debug("Header read", event)
 		},
+		nil,
+
+		false,
 	)
 	writeOps := operations.NewOperations(
 		backendConfig,
@@ -83,6 +86,9 @@ func main() {
 		func(event *config.HeaderEvent) {
 			l.Debug("Header write", event)
 		},
+		nil,
+
+		false,
 	)
 
 	stfs := fs.NewSTFS(
@@ -101,6 +107,7 @@ func main() {
 			)
 		},
 		false,
+		0,
 		false,
 
 		func(hdr *config.Header) {
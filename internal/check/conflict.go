@@ -0,0 +1,19 @@
+package check
+
+import "github.com/pojntfx/stfs/pkg/config"
+
+func CheckConflictPolicy(policy string) error {
+	policyIsKnown := false
+
+	for _, candidate := range config.KnownConflictPolicies {
+		if policy == candidate {
+			policyIsKnown = true
+		}
+	}
+
+	if !policyIsKnown {
+		return config.ErrConflictPolicyUnknown
+	}
+
+	return nil
+}
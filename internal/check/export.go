@@ -0,0 +1,19 @@
+package check
+
+import "github.com/pojntfx/stfs/pkg/config"
+
+func CheckExportFormat(format string) error {
+	formatIsKnown := false
+
+	for _, candidate := range config.KnownExportFormats {
+		if format == candidate {
+			formatIsKnown = true
+		}
+	}
+
+	if !formatIsKnown {
+		return config.ErrExportFormatUnknown
+	}
+
+	return nil
+}
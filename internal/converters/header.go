@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 
 	models "github.com/pojntfx/stfs/internal/db/sqlite/models/metadata"
+	"github.com/pojntfx/stfs/internal/records"
 	"github.com/pojntfx/stfs/pkg/config"
 )
 
@@ -31,6 +32,9 @@ func ConfigHeaderToDBHeader(confighdr *config.Header) *models.Header {
 		Paxrecords:      confighdr.Paxrecords,
 		Format:          confighdr.Format,
 		Deleted:         confighdr.Deleted,
+		Volume:          confighdr.Volume,
+		Lastknownvolume: confighdr.Lastknownvolume,
+		Hash:            confighdr.Hash,
 	}
 }
 
@@ -57,6 +61,48 @@ func DBHeaderToConfigHeader(dbhdr *models.Header) *config.Header {
 		Paxrecords:      dbhdr.Paxrecords,
 		Format:          dbhdr.Format,
 		Deleted:         dbhdr.Deleted,
+		Volume:          dbhdr.Volume,
+		Lastknownvolume: dbhdr.Lastknownvolume,
+		Hash:            dbhdr.Hash,
+	}
+}
+
+func DBHistoryToConfigHeaderVersion(dbversion *models.History) *config.HeaderVersion {
+	// The history doesn't keep the hash, but it is part of the PAX records
+	hash := ""
+	paxRecords := map[string]string{}
+	if err := json.Unmarshal([]byte(dbversion.Paxrecords), &paxRecords); err == nil {
+		hash = paxRecords[records.STFSRecordHash]
+	}
+
+	return &config.HeaderVersion{
+		Session: dbversion.Session,
+		Header: &config.Header{
+			Record:          dbversion.Record,
+			Lastknownrecord: dbversion.Lastknownrecord,
+			Block:           dbversion.Block,
+			Lastknownblock:  dbversion.Lastknownblock,
+			Typeflag:        dbversion.Typeflag,
+			Name:            dbversion.Name,
+			Linkname:        dbversion.Linkname,
+			Size:            dbversion.Size,
+			Mode:            dbversion.Mode,
+			UID:             dbversion.UID,
+			Gid:             dbversion.Gid,
+			Uname:           dbversion.Uname,
+			Gname:           dbversion.Gname,
+			Modtime:         dbversion.Modtime,
+			Accesstime:      dbversion.Accesstime,
+			Changetime:      dbversion.Changetime,
+			Devmajor:        dbversion.Devmajor,
+			Devminor:        dbversion.Devminor,
+			Paxrecords:      dbversion.Paxrecords,
+			Format:          dbversion.Format,
+			Deleted:         dbversion.Deleted,
+			Volume:          dbversion.Volume,
+			Lastknownvolume: dbversion.Lastknownvolume,
+			Hash:            hash,
+		},
 	}
 }
 
@@ -118,6 +164,7 @@ func TarHeaderToDBHeader(record, lastKnownRecord, block, lastKnownBlock int64, t
 		Devminor:        tarhdr.Devminor,
 		Paxrecords:      string(paxRecords),
 		Format:          int64(tarhdr.Format),
+		Hash:            tarhdr.PAXRecords[records.STFSRecordHash],
 	}
 
 	return &hdr, nil
@@ -25,7 +25,7 @@ func bindata_read(data []byte, name string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-var _db_sqlite_migrations_metadata_1637447083_sql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x56\x4d\x73\xdb\x36\x13\x3e\xbf\xfe\x15\x3b\xb9\x44\x9a\x57\xd4\xb9\xd3\x4c\x0f\x6e\xdc\xb8\x9e\x89\x9d\x8c\x62\x35\x39\x1a\x24\x96\x24\x2a\x10\x8b\x2e\x40\xc9\xcc\xaf\xef\x2c\x40\xaa\xb2\x65\xc9\xe9\xf4\x24\x0a\xd8\x7d\x76\xf7\xd9\x2f\x14\x05\xfc\xbf\x33\x0d\xab\x88\xb0\xf6\x17\x15\xa3\x7c\x45\x55\x5a\x84\x16\x95\x46\x0e\x30\xbb\x00\x00\x28\x0a\x58\x61\x45\xac\x81\x6a\x88\xad\x09\xe3\x3d\x90\x83\xd8\x8a\x8e\xc7\x24\xc8\x59\xca\xb8\x88\x0d\x32\x38\x8a\xe0\x7a\x6b\x17\x2f\xa1\x20\x58\x15\x22\xf4\x5e\x8b\xd9\x09\xf0\x3c\xbe\x68\x6c\x1c\xed\xdc\x6b\x86\x7e\xb5\x54\x6d\x9e\xa3\x99\x8c\x96\x75\x93\x64\x99\xc4\x7e\x04\xe5\x47\xbc\x3d\xc6\xdf\xfb\xfb\x8a\xa1\x9b\x1a\x02\xc6\x45\x52\x1f\xc1\x5a\x15\xa0\x44\x74\xa0\xd1\x62\x44\x9d\xb8\x50\x1e\x17\x50\xf6\x11\x1e\x9e\x31\xf1\x00\xca\xe9\x83\xd3\x64\xef\x01\x14\x23\x84\x68\xac\x15\x57\x19\x2d\x6e\x95\xab\x32\x95\x13\xec\x49\x9f\xee\x07\x8f\xb5\x55\x0d\x98\x90\x93\x30\x78\x14\x98\xd1\x3f\x74\x91\x87\xe5\x5e\xb8\x45\xf8\x8e\x4c\xb0\x55\xb6\x47\x51\x51\x7d\xa4\x4e\x45\x53\x29\x6b\x07\xf0\x4c\x1d\x89\xb9\x48\x80\x26\xb6\xc8\x09\x7f\x85\x0d\x50\xfe\xbc\x32\x3c\x81\x69\xf4\xe8\xb4\x71\xcd\x94\x7f\xcf\x18\xd0\x55\xc9\xbc\x82\xc8\xca\x58\xb9\x0d\x56\x85\x56\x58\xbf\x53\x1d\x66\x57\xe2\xde\xe9\x53\x61\x89\xac\xe0\xd4\xc6\x62\x0e\x22\xdd\x38\x39\x8e\xf8\x18\x5f\x20\x42\x71\x83\x31\x4b\x50\x0d\xd6\xb8\x0d\xcc\xb6\xca\x1a\x0d\xf5\xe8\xfc\x47\x39\x1b\xbf\xbf\x0c\x9d\x88\xcc\x73\x01\x18\xb7\x39\x03\xfd\x91\x1a\x21\x28\x3b\x13\xcc\x77\x94\x68\xca\x21\x62\x48\x12\xe3\xc9\x89\x48\x3e\x23\x77\x26\x04\x43\x2e\x25\xbf\x23\x8d\x50\x9a\x98\x55\xd3\xbf\x93\xaa\xeb\x80\x0c\x37\x57\x12\x0e\xed\x1c\x66\xe6\x7b\x73\xa6\x1a\xae\x99\x7a\x7f\xa4\xd2\x9c\x53\x49\x46\x26\xd6\x0e\xcc\x9c\xe1\x23\x5b\x39\xd6\x69\xce\xe8\xdc\xe4\xf6\xfc\x40\xdc\xa9\x28\x95\xd7\xbb\xe0\xb1\x32\xb5\x41\x9d\x3a\xca\xc1\x57\x36\x11\x79\x99\x7e\x7e\xcf\xe5\xcb\xd4\x3b\x1d\xe0\x96\xf4\xbd\xe9\x70\xc2\x8a\x94\xb0\x1c\x2a\xc6\x10\x21\x60\x45\x4e\x27\x76\x4d\xe3\x88\x31\x77\xc2\x65\x55\x61\x08\xa2\x97\xae\xde\xb7\xca\x35\x98\xfe\xd6\x06\xad\x0e\x53\x57\xec\x0b\x88\xa0\x0f\x4f\xd4\x88\x0f\xb4\x16\x90\xfd\x1d\x0e\xe3\x50\x01\x3e\x5f\x7e\x13\xc1\xeb\xbb\xf5\xf2\x19\x52\xe8\xcb\x62\xf4\x8d\x31\x90\xed\xa3\x21\x77\x06\x66\xaf\x7f\x4b\xda\xd4\xa6\x52\x22\x0f\x71\x0a\xbc\x23\x2d\xdf\x90\x46\xdb\x11\xbf\xd9\xed\x24\x0d\x33\xc6\xbf\x7a\x23\x3c\x8c\x4d\xfc\x8f\x8f\x10\x7a\xef\x89\x63\xae\x7b\x95\x94\xce\xa0\xe6\xf0\xff\x25\x6a\x95\x94\xce\xa0\xde\xaa\x3f\x89\x41\xe3\xd6\x54\x08\xae\xef\x4a\xe4\xe7\xad\xfa\xbe\x55\x3c\xb5\x6a\x9a\xef\xf3\x71\x1c\x6e\xbb\xa4\x7c\xb2\x9c\x6f\x8d\xfb\x0f\xd8\x49\xf9\x74\x2b\x5f\x7e\xcb\xab\x31\xa4\xd1\x09\x9d\xf2\xd2\x00\xc2\x03\x3e\x46\x74\x1a\xf5\x34\x77\xf3\xbc\x3f\xaa\x31\x69\xb6\x42\x63\x6d\x1c\xea\x49\x06\x42\x4b\xbd\xd5\xd0\xaa\x2d\xc2\x06\x87\x30\xad\xb2\x9a\xac\xa5\x9d\x8c\xd0\x9a\xb8\xfb\x79\xc4\xf8\xdf\x1f\xbf\xdd\x5d\x7d\x5a\x2d\x37\x38\xec\xa6\x05\x56\x14\xf0\xb5\x45\x46\xc8\x77\xe2\x5d\xa0\x0e\x53\x8b\x06\xaf\xaa\x34\xb0\x94\xb5\xd0\x7b\x8f\x5c\xa9\x80\x8b\xd4\x13\x23\x06\x74\x6a\x98\x70\x24\xe8\x8a\x5c\x54\xe3\x9a\x7c\xfb\xcb\x5b\x49\x28\xab\x2a\x0a\x93\xb8\x6c\x96\x0b\x78\x73\xfd\xe9\xe3\xe5\xdd\xf5\xd2\x6f\x9a\xe5\x16\x59\x86\xdb\x9b\xf9\x93\x2d\xb3\xc1\x21\x59\xc8\x8b\x66\x8c\xb0\x94\x5a\x70\x05\x76\x3e\x0e\xb0\xbe\xff\x50\xfc\x04\x21\xb2\x71\xcd\x11\x4f\x5f\x4f\xcc\x03\x13\x40\x36\x95\x8c\x0c\x61\x7d\x62\x50\x23\x9b\x2d\x6a\xa8\x99\x3a\xf1\x7a\x82\xa1\x54\xa7\xb9\xdd\x85\x82\x11\x25\xaa\x4d\xda\x56\x15\xea\xbc\xaf\xb6\xb9\x9a\x57\x87\x49\xf3\xea\x71\x82\x7f\x79\xa2\x8d\xbd\x3b\x4d\xb1\x30\xe6\x2c\x1d\x8e\x19\x8c\x8a\xc7\x82\x38\x1a\x36\xf2\x20\x91\x34\x61\x84\x72\x80\x55\x16\xba\x13\x43\x4a\x4a\xab\xc4\x10\x0b\xac\x6b\xe2\x08\x4d\x2f\x9d\xad\xe2\x81\x81\x3d\xd7\x5f\x8c\x04\x20\x17\x19\x02\xac\x29\x91\xd3\x32\x67\x54\x7a\xaa\x03\x72\x45\x45\x9d\xb7\x46\xb9\x98\xd6\x58\xd8\x87\x61\xd2\x34\xf6\x14\x82\x91\x27\xa5\x34\x49\x7a\x2d\x45\x92\x7c\xe5\x20\xd7\x2e\x3d\x57\x9e\x07\x71\x53\x1f\xc6\xfc\x74\xa6\xc3\xee\xd5\x14\xee\xc7\xb9\x48\x9a\x28\x33\x73\x24\xd1\x70\x88\x13\xec\x6c\x2c\x44\xe2\xf1\x2d\xb7\xfe\x72\x7f\xb9\x4a\xf9\x5f\xc8\xec\x99\x4f\x30\x95\xf2\xe9\x51\x4c\x35\xa0\xab\x28\xbd\x4c\x52\x24\xa3\xe1\x59\xc0\x29\x9e\xb1\x56\x27\xc7\x8f\xfa\x3d\x17\x00\x9b\x4e\xf1\x90\x4a\x79\x26\x9d\xb4\xd8\xbf\x13\xe6\x17\xf3\x77\x17\x87\x0f\xf3\x2b\xda\xb9\x0b\xcd\xe4\x9f\x3e\xcc\xdf\xfd\x1d\x00\x00\xff\xff\x7e\x7f\x3e\xa6\xbd\x0b\x00\x00")
+var _db_sqlite_migrations_metadata_1637447083_sql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xa4\x56\x4d\x73\x13\x39\x13\x3e\xbf\xf9\x15\x5d\x5c\x48\xea\xb5\x7d\xde\x5a\x6a\x0f\x81\x2c\xd9\x54\x91\x40\x05\x67\xe1\x88\x66\xd4\x33\xa3\xb5\x46\xad\x6d\x69\x1c\x86\x5f\xbf\xd5\xfa\x30\x26\xc6\x86\xad\x3d\x79\x2c\xf5\xf3\xf4\x87\xfa\x6b\xb9\x84\xff\x8f\xa6\x67\x15\x11\x1e\xfc\x59\xcb\x28\x5f\x51\x35\x16\x61\x40\xa5\x91\x03\x9c\x9f\x01\x00\x2c\x97\x70\x8f\x2d\xb1\x06\xea\x20\x0e\x26\x94\x7b\x20\x07\x71\x10\x8c\xc7\x24\xc8\x59\xca\xb8\x88\x3d\x32\x38\x8a\xe0\x26\x6b\x17\xdf\x63\x41\xb0\x2a\x44\x98\xbc\x16\xb5\x95\xf0\x34\xbf\x20\x36\x8e\x1e\xdd\x8f\x14\xbd\xb4\xd4\x6e\x9e\xb2\x99\x6c\x6d\xc6\x26\xc9\x26\x89\xfd\x0c\xcb\xcf\x58\x7b\xc8\xbf\xb3\xf7\x07\x8a\x6e\x3a\x08\x18\x17\x09\x5e\xc8\x06\x15\xa0\x41\x74\xa0\xd1\x62\x44\x9d\x62\xad\x3c\x2e\xa0\x99\x22\x7c\x7a\x12\x89\x4f\xa0\x9c\xde\x3b\x4d\xfa\x3e\x81\x62\x84\x10\x8d\xb5\x62\x2a\xa3\xc5\xad\x72\x6d\x0e\x65\xa5\x3d\x6a\xd3\x7a\xf6\xd8\x59\xd5\x83\x09\xc9\xae\x38\x7b\x14\x9a\x62\x1f\xba\xc8\xf3\x6a\x27\x3c\x20\x7c\x41\x26\xd8\x2a\x3b\xa1\x40\xd4\x14\x69\x54\xd1\xb4\xca\xda\x19\x3c\xd3\x48\xa2\x2e\x12\xa0\x89\x03\x72\xe2\xbf\xc7\x1e\x28\x7f\x5e\x19\xae\x64\x1a\x3d\x3a\x6d\x5c\x5f\xdf\xdf\x33\x06\x74\x6d\x52\xaf\x20\xb2\x32\x56\x6e\x83\x55\x61\x00\xe3\xe0\x4e\x8d\x98\x4d\x89\x3b\xa3\x8f\xb9\x25\xb2\xc2\xd3\x19\x8b\xd9\x89\x74\xe3\xe4\x38\xe2\xe7\xf8\x9d\x40\x28\xee\x31\x82\x2b\x40\x6b\xdc\x06\xce\xb7\xca\x1a\x0d\x5d\x31\xfe\x8d\x9c\x95\xef\xf7\xf3\x28\x22\x17\x09\x2e\x5f\x27\xa8\xdf\x50\x2f\x01\xca\xc6\x04\xf3\x05\xc5\x9b\x66\x8e\x18\x12\xba\x9c\x1c\xf1\xe4\x1d\xf2\x68\x42\x30\xe4\xd2\xe3\x8f\xa4\x11\x1a\x13\x33\x34\xfd\x3b\x0a\x7d\x08\xc8\x70\x73\x25\xee\xd0\xa3\xc3\x1c\xf9\xc9\x9c\xc8\x86\x6b\xa6\xc9\x1f\x40\xfa\x53\x90\xa4\xa4\x46\x6d\x4f\xcd\x89\x78\x64\x2d\x87\x98\xfe\x04\xe6\x46\x8a\x10\xe1\x35\xf1\xa8\xa2\x64\xde\xe4\x82\xc7\xd6\x74\x06\x75\xaa\x28\x07\x1f\xd8\x44\xe4\x55\xfa\xf9\x23\xa7\x2f\xd3\xe4\x74\x80\x5b\xd2\x6b\x33\x62\xe5\x8a\x24\x00\x70\xa8\x18\x43\x84\x80\x2d\x39\x9d\xa2\x6b\x7a\x47\x8c\xb9\x12\x2e\xdb\x16\x43\x10\x5c\xba\x7a\x35\x28\xd7\x63\xfa\xdb\x19\xb4\x3a\xd4\xaa\xa8\xac\x6b\x82\x29\x7c\x03\x23\xde\x43\x2d\x20\xdb\x3b\xef\xfb\xa1\x02\xbc\xbb\xfc\x28\xc5\x71\x7d\xf7\xf0\xb5\xcc\x32\x53\x98\x9a\x65\xb1\x8d\x31\x90\x9d\xa2\x21\x77\x82\x66\x87\xbf\x25\x6d\x3a\xd3\x2a\x91\x87\x58\x1d\x1f\x49\xcb\x37\xa4\x46\x7c\x10\xdf\x6c\x76\x92\x86\x73\xc6\xbf\x27\x23\x71\x28\x45\xfc\xd5\x46\x08\x93\xf7\xc4\x31\xe7\xbd\x4a\xa0\x13\xac\xd9\xfd\x7f\xc9\xda\x26\xd0\x09\xd6\x5b\xf5\x17\x31\x68\xdc\x9a\x16\xc1\x4d\x63\x83\xfc\xb4\x54\x5f\x0d\x8a\x6b\xa9\xbe\x94\x2e\x79\x51\xda\xe1\x76\x4c\xe0\xa3\xe9\x7c\x6b\xdc\x7f\xe0\x36\xee\x14\xf7\xbb\xcb\x8f\x79\x34\x06\x49\x60\x05\xa3\xf2\x52\x00\x12\x5d\xfc\x1c\xd1\x69\xd4\xb5\xef\xe6\xe9\x75\x90\x63\x52\x6c\x4b\x8d\x9d\x71\xa8\xab\x0c\x84\x81\x26\xab\x61\x50\x5b\x84\x0d\xce\xa1\x8e\xb2\x8e\xac\xa5\x47\x69\xa1\x1d\xf1\xf8\x6b\xe1\xf8\xdf\x9f\xbf\xdf\x5d\xbd\xbd\x5f\x6d\x70\x7e\xac\x03\x6c\xb9\x84\x0f\x03\x32\x42\xbe\x13\xeb\x02\x8d\x98\x9a\x61\xf0\xaa\x4d\x0d\x4b\x59\x0b\x93\xf7\xc8\xad\x0a\xb8\x48\x35\x51\x38\x60\x54\x73\xe5\x91\x80\xb6\xe4\xa2\x2a\x63\xf2\xf9\x6f\xcf\xa1\x1d\x14\xab\x36\x4a\x24\x71\xd5\xaf\x16\xf0\xec\xfa\xed\x9b\xcb\xbb\xeb\x95\xdf\xf4\xab\x2d\xb2\x34\xb7\x67\x17\xbb\xf4\x5d\x0f\xc9\x8f\xa4\x21\x0f\x9a\xe2\x61\x23\xb9\xe0\x96\x38\xfa\x38\xc3\xc3\xfa\xf5\xf2\x17\x08\x91\x8d\xeb\x0f\xe2\xf4\xe1\x48\x3f\x30\x01\x64\x52\x49\xcb\x90\xa8\xd7\x08\x6a\x64\xb3\x45\x0d\x1d\xd3\x28\x56\x57\x1a\x4a\x79\x9a\xcb\x5d\x42\x50\x58\xa2\xda\xa4\x69\xd5\xa2\xce\xf3\x6a\x8b\xbc\xf7\xb8\xd9\x18\xaf\x3e\x57\xfa\xef\x77\xb4\x52\xbb\xb5\x8b\x85\xf2\x66\xe9\xb0\xbc\x60\x54\x5c\x12\xe2\xa9\x83\x6b\x59\xcf\xe4\x99\x30\x42\x33\xc3\x7d\x16\xba\x13\x45\x4a\x52\xab\xc1\x10\x97\xd8\x75\xc4\x11\xfa\x09\x43\x00\x15\xf7\x14\x54\x3a\x78\x6f\xc4\x01\xb9\xc8\x14\x60\x4d\x83\x9c\x86\x39\xa3\xd2\x35\x0f\xc8\x2d\x5b\x1a\xbd\x35\xca\xc5\x34\xc6\xc2\xce\x0d\x93\xba\xb1\xa7\x10\x8c\xac\x94\x52\x24\x69\x5b\x8a\x04\x4d\x6d\x50\x0f\x2e\xad\x2b\x4f\x9d\xb8\xe9\xf6\x7d\xfe\xb6\xa7\xc3\xe3\x0f\x9f\xb0\xd2\xa4\xe6\x6f\xa2\xf4\xcc\x12\x44\xc3\x21\x56\xda\xf3\x92\x88\xc4\x65\x97\x7b\x78\xbf\xbe\xbc\x4f\xef\xbf\x90\x8e\x76\x51\x69\x5a\xe5\xd3\x52\x4c\x1d\xa0\x6b\x29\x6d\x26\xc9\x93\xa2\xf8\x3c\x60\xf5\xa7\xe4\x6a\xd1\x70\x58\xef\xe9\xd6\xb3\x19\x15\xcf\x29\x95\xcf\xa5\x92\x16\xbb\x3d\xe1\xe2\xec\xe2\xc5\xd9\xfe\x62\x7e\x45\x8f\xee\x4c\x33\xf9\x6f\x17\xf3\x17\xff\x0c\x00\x7e\x7f\x3e\xa6\xbd\x0b\x00\x00")
 
 func db_sqlite_migrations_metadata_1637447083_sql() ([]byte, error) {
 	return bindata_read(
@@ -34,6 +34,33 @@ func db_sqlite_migrations_metadata_1637447083_sql() ([]byte, error) {
 	)
 }
 
+var _db_sqlite_migrations_metadata_1792192800_sql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x94\xcf\xb1\x8e\xc2\x30\x0c\x06\xe0\xbd\x4f\xf1\x8f\x77\x3a\x45\xba\xbd\xeb\xbd\xc2\xb1\x1b\xe2\xb6\x11\xae\x1d\xa5\x4e\xfb\xfa\x28\x80\x10\x03\x08\x98\x2c\xd9\xf2\xf7\xdb\x21\xe0\x67\x4e\x63\x21\x67\xfc\xe7\x2e\x04\xec\x4c\xea\xcc\xf8\x72\xca\x0c\x2b\x70\x2a\x18\x92\xf0\x37\x6c\x80\x4f\x69\xc1\xc4\x14\xb9\x74\x24\xce\x6d\xbc\x17\xbe\xb6\x16\x50\x8c\x38\x34\x40\xb1\xb6\xc2\x48\xea\x3c\x72\x81\x9a\x43\xab\x08\x22\x0f\x54\xc5\xf1\xdb\xdf\xa5\x9d\x69\x86\xd0\xe2\xa8\x39\xb6\x6b\x2e\xe4\x87\xa1\x0d\x38\xaa\x6d\xfa\x5e\xfa\xed\xf5\x3f\xdb\xf4\xa1\x1d\x8b\xe5\x27\x78\xff\x72\x61\x35\xa9\x33\xf7\xdd\x69\x00\x3b\x20\x51\xd2\x65\x01\x00\x00")
+
+func db_sqlite_migrations_metadata_1792192800_sql() ([]byte, error) {
+	return bindata_read(
+		_db_sqlite_migrations_metadata_1792192800_sql,
+		"../../db/sqlite/migrations/metadata/1792192800.sql",
+	)
+}
+
+var _db_sqlite_migrations_metadata_1792281600_sql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\x84\x94\xc1\x92\xd3\x30\x0c\x86\xef\x79\x0a\x1d\xd9\x81\x3e\x41\xaf\xdc\x39\x00\x67\x46\xb5\xd5\xd8\xc4\x96\x32\xb2\x92\x36\x3c\x3d\xe3\x84\x00\xdb\x8d\xb7\xb7\x8c\x3e\x49\xbf\x94\xf9\xe5\xd3\x09\x3e\xe6\xd8\x2b\x1a\xc1\xf7\xb1\x73\x4a\xf5\xcb\xf0\x92\x08\x42\x2c\x26\xba\xc0\x87\x0e\x00\xe0\x74\x82\x2f\xea\x49\x21\x32\xdc\x42\x74\x01\x2c\x10\xcc\xa4\x25\x0a\x17\x08\x38\x13\x5c\x88\x18\x22\x7b\xba\x93\x5f\x8b\xa2\x87\xc8\x46\x3d\x29\xb0\x18\xf0\x94\x12\x8c\x1a\x33\xea\x02\x03\x2d\x80\x93\x49\x64\xa7\x94\x89\xed\xd3\xae\xf3\x95\x4a\x6d\x0a\x72\x5d\x35\x64\x24\x45\xab\x81\x4d\x77\x1b\xd2\x83\x85\x58\xf6\x01\xd6\xd2\xf2\xa7\xee\x51\xf2\x6f\xe3\x6f\xb5\x9b\x05\x52\x70\x92\xa6\xcc\x05\x50\x09\x10\x9c\x8c\xcb\xae\x16\x08\xeb\x96\x78\x35\xd2\x07\xf9\x80\xe5\xed\x8a\x4a\x4e\xf4\xed\x9a\x9b\x66\xc2\x62\x03\xcb\x8d\xdf\xcd\xba\x24\x71\xc3\xb3\x0e\xef\x25\x79\x4a\x54\xff\xc8\x31\xb5\x65\xa4\x6b\xc2\xbe\x81\x19\x33\x81\xd1\xdd\x1e\xe2\x29\xf2\xd0\x62\x25\xfe\xa2\x46\xbb\x2c\xbe\x85\xa6\x03\x3b\x6c\xa4\x6f\x92\xa9\x35\x42\xdf\x02\x59\xbc\xc5\x4c\xe0\xab\x93\x5f\x23\x74\x8e\x4a\x69\x51\x17\x90\x7b\x6a\x51\x4f\x73\xc6\x9f\xa2\x8d\x39\x2b\x8e\xdc\xc4\x23\xde\x37\x0b\x94\xa3\x91\xaf\xa2\x19\xad\x51\x3a\x57\xab\xd2\x33\x7b\x34\xb2\xba\x97\xf3\x7e\xd4\xab\x69\xf7\xa3\xfe\xb1\xfe\x3d\xe1\x7f\x47\x5e\x03\x2f\xe7\xee\xff\x07\xe1\xb3\xdc\xb8\xf3\x2a\xe3\x41\xed\x79\x03\xaf\x5e\x8a\x73\xf7\x7b\x00\x71\xb5\x1c\x53\x4f\x04\x00\x00")
+
+func db_sqlite_migrations_metadata_1792281600_sql() ([]byte, error) {
+	return bindata_read(
+		_db_sqlite_migrations_metadata_1792281600_sql,
+		"../../db/sqlite/migrations/metadata/1792281600.sql",
+	)
+}
+
+var _db_sqlite_migrations_metadata_1792368000_sql = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x6d\x90\xc1\x6a\xc3\x30\x0c\x86\xef\x7e\x0a\x51\x06\x4e\x59\xd3\x17\x08\x3b\x0c\x46\xd9\x71\xd0\x0d\x76\x6b\x35\x4b\x59\x3c\x5c\x3b\xd8\xca\x9a\xc7\x9f\x9d\xac\x21\x87\x1e\x7c\x90\xf5\x7f\xbf\x7e\xa9\xae\xe1\xf1\x62\xbf\x23\x0a\xc3\x47\xaf\xea\x1a\x5e\x31\x75\x10\x5a\x90\x8e\xc1\x04\x2f\xec\x65\x2e\x6d\x82\x8e\x91\x38\x42\x55\x7a\xe7\xe3\xfb\xe1\xb8\x2f\xea\x33\xbc\x3d\x7f\x42\x64\x13\x22\x6d\x77\x30\x24\x26\x90\x00\xad\xf5\x04\x96\x32\x6f\x0d\xba\xc5\x2b\x77\x22\xb7\x1c\xd9\x1b\x56\xe8\x24\xfb\x09\x7e\x39\xfe\x37\x4f\x80\x44\x59\xec\x86\x8b\x87\xae\x64\x11\x1e\x05\x7c\xc8\x6f\x70\x0e\x88\x5b\x1c\x9c\x80\xd6\x8d\x1a\x7a\x2a\xb9\x6f\x60\x62\x99\x89\xa7\xcc\xa3\xe3\x64\xb8\xfa\x49\xc1\x9f\xb2\x41\x44\x23\x55\x8f\xe3\x9c\x32\xed\x40\x3f\xec\x37\xcb\x06\x1b\x9d\x73\x6b\xbd\x85\x6b\x97\x83\xc1\x04\xfd\xa2\xb3\xb4\x42\xb6\x8d\x32\x91\xcb\xbc\xbc\x17\x8f\xb7\xa9\xa7\x69\x62\xf0\x4b\x8a\xaa\x7c\x64\x71\xbd\xba\xec\x4b\xb8\x7a\x45\x31\xf4\x77\xd8\xe6\xee\x11\x26\xf1\xea\x0a\x8d\xfa\x03\xfc\x08\xf6\x4f\xaa\x01\x00\x00")
+
+func db_sqlite_migrations_metadata_1792368000_sql() ([]byte, error) {
+	return bindata_read(
+		_db_sqlite_migrations_metadata_1792368000_sql,
+		"../../db/sqlite/migrations/metadata/1792368000.sql",
+	)
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -57,6 +84,9 @@ func AssetNames() []string {
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() ([]byte, error){
 	"../../db/sqlite/migrations/metadata/1637447083.sql": db_sqlite_migrations_metadata_1637447083_sql,
+	"../../db/sqlite/migrations/metadata/1792192800.sql": db_sqlite_migrations_metadata_1792192800_sql,
+	"../../db/sqlite/migrations/metadata/1792281600.sql": db_sqlite_migrations_metadata_1792281600_sql,
+	"../../db/sqlite/migrations/metadata/1792368000.sql": db_sqlite_migrations_metadata_1792368000_sql,
 }
 // AssetDir returns the file names below a certain
 // directory embedded in the file by go-bindata.
@@ -106,6 +136,12 @@ var _bintree = &_bintree_t{nil, map[string]*_bintree_t{
 						"metadata": &_bintree_t{nil, map[string]*_bintree_t{
 							"1637447083.sql": &_bintree_t{db_sqlite_migrations_metadata_1637447083_sql, map[string]*_bintree_t{
 							}},
+							"1792192800.sql": &_bintree_t{db_sqlite_migrations_metadata_1792192800_sql, map[string]*_bintree_t{
+							}},
+							"1792281600.sql": &_bintree_t{db_sqlite_migrations_metadata_1792281600_sql, map[string]*_bintree_t{
+							}},
+							"1792368000.sql": &_bintree_t{db_sqlite_migrations_metadata_1792368000_sql, map[string]*_bintree_t{
+							}},
 						}},
 					}},
 				}},
@@ -6,7 +6,9 @@ package models
 var TableNames = struct {
 	GorpMigrations string
 	Headers        string
+	History        string
 }{
 	GorpMigrations: "gorp_migrations",
 	Headers:        "headers",
+	History:        "history",
 }
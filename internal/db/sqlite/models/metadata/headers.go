@@ -44,6 +44,9 @@ type Header struct {
 	Devminor        int64     `boil:"devminor" json:"devminor" toml:"devminor" yaml:"devminor"`
 	Paxrecords      string    `boil:"paxrecords" json:"paxrecords" toml:"paxrecords" yaml:"paxrecords"`
 	Format          int64     `boil:"format" json:"format" toml:"format" yaml:"format"`
+	Volume          int64     `boil:"volume" json:"volume" toml:"volume" yaml:"volume"`
+	Lastknownvolume int64     `boil:"lastknownvolume" json:"lastknownvolume" toml:"lastknownvolume" yaml:"lastknownvolume"`
+	Hash            string    `boil:"hash" json:"hash" toml:"hash" yaml:"hash"`
 
 	R *headerR `boil:"-" json:"-" toml:"-" yaml:"-"`
 	L headerL  `boil:"-" json:"-" toml:"-" yaml:"-"`
@@ -71,6 +74,9 @@ var HeaderColumns = struct {
 	Devminor        string
 	Paxrecords      string
 	Format          string
+	Volume          string
+	Lastknownvolume string
+	Hash            string
 }{
 	Record:          "record",
 	Lastknownrecord: "lastknownrecord",
@@ -93,6 +99,9 @@ var HeaderColumns = struct {
 	Devminor:        "devminor",
 	Paxrecords:      "paxrecords",
 	Format:          "format",
+	Volume:          "volume",
+	Lastknownvolume: "lastknownvolume",
+	Hash:            "hash",
 }
 
 var HeaderTableColumns = struct {
@@ -117,6 +126,9 @@ var HeaderTableColumns = struct {
 	Devminor        string
 	Paxrecords      string
 	Format          string
+	Volume          string
+	Lastknownvolume string
+	Hash            string
 }{
 	Record:          "headers.record",
 	Lastknownrecord: "headers.lastknownrecord",
@@ -139,6 +151,9 @@ var HeaderTableColumns = struct {
 	Devminor:        "headers.devminor",
 	Paxrecords:      "headers.paxrecords",
 	Format:          "headers.format",
+	Volume:          "headers.volume",
+	Lastknownvolume: "headers.lastknownvolume",
+	Hash:            "headers.hash",
 }
 
 // Generated where
@@ -209,6 +224,9 @@ var HeaderWhere = struct {
 	Devminor        whereHelperint64
 	Paxrecords      whereHelperstring
 	Format          whereHelperint64
+	Volume          whereHelperint64
+	Lastknownvolume whereHelperint64
+	Hash            whereHelperstring
 }{
 	Record:          whereHelperint64{field: "\"headers\".\"record\""},
 	Lastknownrecord: whereHelperint64{field: "\"headers\".\"lastknownrecord\""},
@@ -231,6 +249,9 @@ var HeaderWhere = struct {
 	Devminor:        whereHelperint64{field: "\"headers\".\"devminor\""},
 	Paxrecords:      whereHelperstring{field: "\"headers\".\"paxrecords\""},
 	Format:          whereHelperint64{field: "\"headers\".\"format\""},
+	Volume:          whereHelperint64{field: "\"headers\".\"volume\""},
+	Lastknownvolume: whereHelperint64{field: "\"headers\".\"lastknownvolume\""},
+	Hash:            whereHelperstring{field: "\"headers\".\"hash\""},
 }
 
 // HeaderRels is where relationship names are stored.
@@ -250,9 +271,9 @@ func (*headerR) NewStruct() *headerR {
 type headerL struct{}
 
 var (
-	headerAllColumns            = []string{"record", "lastknownrecord", "block", "lastknownblock", "deleted", "typeflag", "name", "linkname", "size", "mode", "uid", "gid", "uname", "gname", "modtime", "accesstime", "changetime", "devmajor", "devminor", "paxrecords", "format"}
+	headerAllColumns            = []string{"record", "lastknownrecord", "block", "lastknownblock", "deleted", "typeflag", "name", "linkname", "size", "mode", "uid", "gid", "uname", "gname", "modtime", "accesstime", "changetime", "devmajor", "devminor", "paxrecords", "format", "volume", "lastknownvolume", "hash"}
 	headerColumnsWithoutDefault = []string{"record", "lastknownrecord", "block", "lastknownblock", "deleted", "typeflag", "name", "linkname", "size", "mode", "uid", "gid", "uname", "gname", "modtime", "accesstime", "changetime", "devmajor", "devminor", "paxrecords", "format"}
-	headerColumnsWithDefault    = []string{}
+	headerColumnsWithDefault    = []string{"volume", "lastknownvolume", "hash"}
 	headerPrimaryKeyColumns     = []string{"name", "linkname"}
 	headerGeneratedColumns      = []string{}
 )
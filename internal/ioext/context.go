@@ -0,0 +1,20 @@
+package ioext
+
+import (
+	"context"
+	"io"
+)
+
+// ContextReader stops reading once Context has been cancelled
+type ContextReader struct {
+	Context context.Context
+	Reader  io.Reader
+}
+
+func (r *ContextReader) Read(p []byte) (n int, err error) {
+	if err := r.Context.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.Reader.Read(p)
+}
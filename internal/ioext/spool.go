@@ -0,0 +1,85 @@
+package ioext
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+type SpoolWriter struct {
+	memoryLimit int
+	dir         string
+
+	buf  bytes.Buffer
+	file *os.File
+	size int64
+}
+
+func NewSpoolWriter(memoryLimit int, dir string) *SpoolWriter {
+	return &SpoolWriter{
+		memoryLimit: memoryLimit,
+		dir:         dir,
+	}
+}
+
+func (w *SpoolWriter) Write(p []byte) (n int, err error) {
+	if w.file == nil && w.buf.Len()+len(p) > w.memoryLimit {
+		// Spill the in-memory buffer to disk once it exceeds the limit
+		f, err := os.CreateTemp(w.dir, "stfs-spool-*")
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err := w.buf.WriteTo(f); err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+
+			return 0, err
+		}
+
+		w.file = f
+		w.buf = bytes.Buffer{}
+	}
+
+	if w.file != nil {
+		n, err = w.file.Write(p)
+	} else {
+		n, err = w.buf.Write(p)
+	}
+
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *SpoolWriter) Size() int64 {
+	return w.size
+}
+
+func (w *SpoolWriter) Reader() (io.Reader, error) {
+	if w.file != nil {
+		if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		return w.file, nil
+	}
+
+	return bytes.NewReader(w.buf.Bytes()), nil
+}
+
+func (w *SpoolWriter) Close() error {
+	w.buf = bytes.Buffer{}
+
+	if w.file == nil {
+		return nil
+	}
+
+	name := w.file.Name()
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	return os.Remove(name)
+}
@@ -11,14 +11,17 @@ import (
 
 var (
 	tarHeaderCSV = []string{
-		"record", "lastknownrecord", "block", "lastknownblock", "typeflag", "name", "linkname", "size", "mode", "uid", "gid", "uname", "gname", "modtime", "accesstime", "changetime", "devmajor", "devminor", "paxrecords", "format",
+		"record", "lastknownrecord", "block", "lastknownblock", "typeflag", "name", "linkname", "size", "mode", "uid", "gid", "uname", "gname", "modtime", "accesstime", "changetime", "devmajor", "devminor", "paxrecords", "format", "volume", "lastknownvolume",
 	}
 	tarHeaderEventCSV = append([]string{"type", "indexed"}, tarHeaderCSV...)
+	verifyEventCSV    = append([]string{"type", "expected", "actual", "corrected", "error"}, tarHeaderCSV...)
+	headerVersionCSV  = append([]string{"session", "time", "deleted"}, tarHeaderCSV...)
+	restorePlanCSV    = append([]string{"pass", "startrecord", "endrecord", "to", "linkto"}, tarHeaderCSV...)
 )
 
 func headerToCSV(hdr *config.Header) []string {
 	return []string{
-		fmt.Sprintf("%v", hdr.Record), fmt.Sprintf("%v", hdr.Lastknownrecord), fmt.Sprintf("%v", hdr.Block), fmt.Sprintf("%v", hdr.Lastknownblock), fmt.Sprintf("%v", hdr.Typeflag), hdr.Name, hdr.Linkname, fmt.Sprintf("%v", hdr.Size), fmt.Sprintf("%v", hdr.Mode), fmt.Sprintf("%v", hdr.UID), fmt.Sprintf("%v", hdr.Gid), fmt.Sprintf("%v", hdr.Uname), fmt.Sprintf("%v", hdr.Gname), hdr.Modtime.Format(time.RFC3339), hdr.Accesstime.Format(time.RFC3339), hdr.Changetime.Format(time.RFC3339), fmt.Sprintf("%v", hdr.Devmajor), fmt.Sprintf("%v", hdr.Devminor), fmt.Sprintf("%v", hdr.Paxrecords), fmt.Sprintf("%v", hdr.Format),
+		fmt.Sprintf("%v", hdr.Record), fmt.Sprintf("%v", hdr.Lastknownrecord), fmt.Sprintf("%v", hdr.Block), fmt.Sprintf("%v", hdr.Lastknownblock), fmt.Sprintf("%v", hdr.Typeflag), hdr.Name, hdr.Linkname, fmt.Sprintf("%v", hdr.Size), fmt.Sprintf("%v", hdr.Mode), fmt.Sprintf("%v", hdr.UID), fmt.Sprintf("%v", hdr.Gid), fmt.Sprintf("%v", hdr.Uname), fmt.Sprintf("%v", hdr.Gname), hdr.Modtime.Format(time.RFC3339), hdr.Accesstime.Format(time.RFC3339), hdr.Changetime.Format(time.RFC3339), fmt.Sprintf("%v", hdr.Devmajor), fmt.Sprintf("%v", hdr.Devminor), fmt.Sprintf("%v", hdr.Paxrecords), fmt.Sprintf("%v", hdr.Format), fmt.Sprintf("%v", hdr.Volume), fmt.Sprintf("%v", hdr.Lastknownvolume),
 	}
 }
 
@@ -26,6 +29,23 @@ func headerEventToCSV(event *config.HeaderEvent) []string {
 	return append([]string{event.Type, fmt.Sprintf("%v", event.Indexed)}, headerToCSV(event.Header)...)
 }
 
+func verifyEventToCSV(event *config.VerifyEvent) []string {
+	err := ""
+	if event.Error != nil {
+		err = event.Error.Error()
+	}
+
+	return append([]string{event.Type, event.Expected, event.Actual, fmt.Sprintf("%v", event.Corrected), err}, headerToCSV(event.Header)...)
+}
+
+func headerVersionToCSV(version *config.HeaderVersion) []string {
+	return append([]string{fmt.Sprintf("%v", version.Session), time.Unix(0, version.Session).Format(time.RFC3339), fmt.Sprintf("%v", version.Header.Deleted == 1)}, headerToCSV(version.Header)...)
+}
+
+func restorePlanEntryToCSV(pass int, startRecord, endRecord int64, entry *config.RestorePlanEntry) []string {
+	return append([]string{fmt.Sprintf("%v", pass), fmt.Sprintf("%v", startRecord), fmt.Sprintf("%v", endRecord), entry.To, entry.Linkname}, headerToCSV(entry.Content)...)
+}
+
 type CSVLogger struct {
 	n int
 }
@@ -61,3 +81,56 @@ func (l *CSVLogger) PrintHeaderEvent(event *config.HeaderEvent) {
 
 	l.n++
 }
+
+func (l *CSVLogger) PrintVerifyEvent(event *config.VerifyEvent) {
+	w := csv.NewWriter(os.Stdout)
+
+	if l.n <= 0 {
+		_ = w.Write(verifyEventCSV) // Errors are ignored for compatibility with traditional logging APIs
+	}
+
+	_ = w.Write(verifyEventToCSV(event)) // Errors are ignored for compatibility with traditional logging APIs
+
+	w.Flush()
+
+	l.n++
+}
+
+func (l *CSVLogger) PrintHeaderVersion(version *config.HeaderVersion) {
+	w := csv.NewWriter(os.Stdout)
+
+	if l.n <= 0 {
+		_ = w.Write(headerVersionCSV) // Errors are ignored for compatibility with traditional logging APIs
+	}
+
+	_ = w.Write(headerVersionToCSV(version)) // Errors are ignored for compatibility with traditional logging APIs
+
+	w.Flush()
+
+	l.n++
+}
+
+// PrintRestorePlan prints the entries in the order in which they are restored; links have -1 as their pass
+func (l *CSVLogger) PrintRestorePlan(plan *config.RestorePlan) {
+	w := csv.NewWriter(os.Stdout)
+
+	if l.n <= 0 {
+		_ = w.Write(restorePlanCSV) // Errors are ignored for compatibility with traditional logging APIs
+	}
+
+	for i, pass := range plan.Passes {
+		for _, entry := range pass.Entries {
+			_ = w.Write(restorePlanEntryToCSV(i, pass.StartRecord, pass.EndRecord, entry)) // Errors are ignored for compatibility with traditional logging APIs
+
+			l.n++
+		}
+	}
+
+	for _, entry := range plan.Links {
+		_ = w.Write(restorePlanEntryToCSV(-1, -1, -1, entry)) // Errors are ignored for compatibility with traditional logging APIs
+
+		l.n++
+	}
+
+	w.Flush()
+}
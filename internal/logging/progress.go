@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// ProgressLogger prints progress events as a live line if stderr is a terminal, or as JSON events if the verbosity is at least 3
+type ProgressLogger struct {
+	verbosity int
+	terminal  bool
+
+	lock       sync.Mutex
+	filesTotal int64
+	bytesTotal int64
+	line       bool
+}
+
+func NewProgressLogger(verbosity int) *ProgressLogger {
+	terminal := false
+	if stat, err := os.Stderr.Stat(); err == nil {
+		terminal = stat.Mode()&os.ModeCharDevice != 0
+	}
+
+	return &ProgressLogger{
+		verbosity: verbosity,
+		terminal:  terminal,
+	}
+}
+
+// Enabled returns whether the events will be printed, so that counting the totals can be skipped if they won't
+func (l *ProgressLogger) Enabled() bool {
+	return l.verbosity >= 3 || (l.verbosity >= 2 && l.terminal)
+}
+
+// SetTotal sets the totals for operations which can't count them themselves, i.e. archiving a directory
+func (l *ProgressLogger) SetTotal(files int64, bytes int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.filesTotal = files
+	l.bytesTotal = bytes
+}
+
+func (l *ProgressLogger) PrintProgressEvent(event *config.ProgressEvent) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if event.FilesTotal <= 0 && event.BytesTotal <= 0 && (l.filesTotal > 0 || l.bytesTotal > 0) {
+		e := *event
+		e.FilesTotal = l.filesTotal
+		e.BytesTotal = l.bytesTotal
+
+		if e.BytesTotal > 0 && e.Throughput > 0 {
+			e.ETA = time.Duration(float64(max(e.BytesTotal-e.BytesRead, 0)) / e.Throughput * float64(time.Second))
+		}
+
+		event = &e
+	}
+
+	if l.verbosity >= 3 {
+		NewJSONLogger(l.verbosity).Debug("Progress", event)
+
+		return
+	}
+
+	if l.verbosity < 2 || !l.terminal {
+		return
+	}
+
+	files := fmt.Sprintf("%v", event.FilesDone)
+	if event.FilesTotal > 0 {
+		files = fmt.Sprintf("%v/%v", event.FilesDone, event.FilesTotal)
+	}
+
+	eta := "unknown"
+	if event.ETA >= 0 {
+		eta = event.ETA.Round(time.Second).String()
+	}
+
+	line := fmt.Sprintf("%v: %v files, %v read, %v written, %v/s, ETA %v", event.Type, files, formatBytes(event.BytesRead), formatBytes(event.BytesWritten), formatBytes(int64(event.Throughput)), eta)
+	if event.Name != "" {
+		line += ", " + event.Name
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, "\r\033[K"+line) // We are ignoring printing errors in line wih the stdlib
+
+	l.line = true
+}
+
+// Close ends the live line
+func (l *ProgressLogger) Close() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.line {
+		_, _ = fmt.Fprintln(os.Stderr) // We are ignoring printing errors in line wih the stdlib
+	}
+
+	l.line = false
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%v B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for i := n / unit; i >= unit; i /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
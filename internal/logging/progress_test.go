@@ -0,0 +1,83 @@
+package logging
+
+import "testing"
+
+var formatBytesTests = []struct {
+	name string
+	n    int64
+	want string
+}{
+	{
+		"Can format bytes",
+		1023,
+		"1023 B",
+	},
+	{
+		"Can format kibibytes",
+		1536,
+		"1.5 KiB",
+	},
+	{
+		"Can format mebibytes",
+		3 * 1024 * 1024,
+		"3.0 MiB",
+	},
+	{
+		"Can format gibibytes",
+		5 * 1024 * 1024 * 1024,
+		"5.0 GiB",
+	},
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range formatBytesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatBytes(tt.n); got != tt.want {
+				t.Errorf("formatBytes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var enabledTests = []struct {
+	name      string
+	verbosity int
+	terminal  bool
+	want      bool
+}{
+	{
+		"Can disable progress by default",
+		1,
+		true,
+		false,
+	},
+	{
+		"Can enable live line on terminal",
+		2,
+		true,
+		true,
+	},
+	{
+		"Can disable live line without terminal",
+		2,
+		false,
+		false,
+	},
+	{
+		"Can enable events without terminal",
+		3,
+		false,
+		true,
+	},
+}
+
+func TestProgressLogger_Enabled(t *testing.T) {
+	for _, tt := range enabledTests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &ProgressLogger{verbosity: tt.verbosity, terminal: tt.terminal}
+			if got := l.Enabled(); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
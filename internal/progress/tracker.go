@@ -0,0 +1,247 @@
+package progress
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+const (
+	interval = 100 * time.Millisecond // Minimum time between two events, except for the last one
+)
+
+// Tracker counts the files and bytes of an operation and reports them to onProgress; all methods can be called on a nil Tracker, which does nothing
+type Tracker struct {
+	onProgress func(event *config.ProgressEvent)
+
+	lock  sync.Mutex
+	event config.ProgressEvent
+	start time.Time
+	last  time.Time
+}
+
+// NewTracker returns nil if onProgress is nil, so that operations without a progress callback don't have to count anything
+func NewTracker(eventType string, filesTotal int64, bytesTotal int64, onProgress func(event *config.ProgressEvent)) *Tracker {
+	if onProgress == nil {
+		return nil
+	}
+
+	return &Tracker{
+		onProgress: onProgress,
+
+		event: config.ProgressEvent{
+			Type:       eventType,
+			FilesTotal: filesTotal,
+			BytesTotal: bytesTotal,
+			ETA:        -1,
+		},
+		start: time.Now(),
+	}
+}
+
+// Start sets the file which is currently being processed
+func (t *Tracker) Start(name string) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.Name = name
+
+	t.emit(false)
+}
+
+// Done marks a file as processed completely
+func (t *Tracker) Done() {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.FilesDone++
+
+	t.emit(false)
+}
+
+func (t *Tracker) AddRead(n int64) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.BytesRead += n
+
+	t.emit(false)
+}
+
+func (t *Tracker) AddWritten(n int64) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.BytesWritten += n
+
+	t.emit(false)
+}
+
+// SetRead sets the bytes which have been read from the source, i.e. the position in a tape or tar file
+func (t *Tracker) SetRead(n int64) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.BytesRead = n
+
+	t.emit(false)
+}
+
+// Finish reports the final counts, regardless of when the last event has been reported
+func (t *Tracker) Finish() {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.event.Name = ""
+
+	t.emit(true)
+}
+
+// Reader counts the bytes which are read from r as read from the source
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+
+	return &reader{r, t.AddRead}
+}
+
+// Writer counts the bytes which are written to w as written to the destination
+func (t *Tracker) Writer(w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+
+	tw := &writer{w, t.AddWritten}
+
+	// Keep the methods which i.e. sparse.Writer uses to seek over holes instead of writing zeros
+	switch dst := w.(type) {
+	case seekTruncater:
+		return &seekTruncateWriter{seekWriter{tw, dst}, dst}
+	case io.Seeker:
+		return &seekWriter{tw, dst}
+	}
+
+	return tw
+}
+
+// Forward returns a callback which adds the bytes of the events of a nested operation, i.e. fetching a single file, to the tracker
+func (t *Tracker) Forward() func(event *config.ProgressEvent) {
+	if t == nil {
+		return nil
+	}
+
+	read, written := int64(0), int64(0)
+	return func(event *config.ProgressEvent) {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		t.event.BytesRead += event.BytesRead - read
+		t.event.BytesWritten += event.BytesWritten - written
+		read, written = event.BytesRead, event.BytesWritten
+
+		t.emit(false)
+	}
+}
+
+func (t *Tracker) emit(force bool) {
+	now := time.Now()
+	if !force && now.Sub(t.last) < interval {
+		return
+	}
+	t.last = now
+
+	event := t.event
+
+	elapsed := now.Sub(t.start).Seconds()
+	if elapsed > 0 {
+		event.Throughput = float64(event.BytesRead) / elapsed
+	}
+
+	event.ETA = -1
+	switch {
+	case event.BytesTotal > 0 && event.Throughput > 0:
+		event.ETA = time.Duration(float64(max(event.BytesTotal-event.BytesRead, 0)) / event.Throughput * float64(time.Second))
+	case event.BytesTotal <= 0 && event.FilesTotal > 0 && event.FilesDone > 0:
+		event.ETA = time.Duration(float64(max(event.FilesTotal-event.FilesDone, 0)) / float64(event.FilesDone) * elapsed * float64(time.Second))
+	}
+
+	t.onProgress(&event)
+}
+
+type reader struct {
+	r   io.Reader
+	add func(n int64)
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.add(int64(n))
+	}
+
+	return n, err
+}
+
+type writer struct {
+	w   io.Writer
+	add func(n int64)
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.add(int64(n))
+	}
+
+	return n, err
+}
+
+type seekTruncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+type seekWriter struct {
+	*writer
+	s io.Seeker
+}
+
+func (w *seekWriter) Seek(offset int64, whence int) (int64, error) {
+	return w.s.Seek(offset, whence)
+}
+
+type seekTruncateWriter struct {
+	seekWriter
+	t seekTruncater
+}
+
+func (w *seekTruncateWriter) Truncate(size int64) error {
+	return w.t.Truncate(size)
+}
@@ -0,0 +1,207 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var trackerTests = []struct {
+	name         string
+	filesTotal   int64
+	bytesTotal   int64
+	track        func(t *Tracker) error
+	wantFiles    int64
+	wantRead     int64
+	wantWritten  int64
+	wantETAKnown bool
+}{
+	{
+		"Can count files and bytes",
+		2,
+		6,
+		func(t *Tracker) error {
+			t.Start("a.txt")
+			t.AddRead(2)
+			t.AddWritten(3)
+			t.Done()
+
+			t.Start("b.txt")
+			t.AddRead(4)
+			t.AddWritten(5)
+			t.Done()
+
+			return nil
+		},
+		2,
+		6,
+		8,
+		true,
+	},
+	{
+		"Can set position of source",
+		0,
+		0,
+		func(t *Tracker) error {
+			t.AddRead(10)
+			t.SetRead(4)
+
+			return nil
+		},
+		0,
+		4,
+		0,
+		false,
+	},
+	{
+		"Can count bytes of reader and writer",
+		1,
+		5,
+		func(t *Tracker) error {
+			_, err := io.Copy(t.Writer(&bytes.Buffer{}), t.Reader(strings.NewReader("hello")))
+
+			return err
+		},
+		0,
+		5,
+		5,
+		true,
+	},
+	{
+		"Can add deltas of nested operation",
+		1,
+		0,
+		func(t *Tracker) error {
+			forward := t.Forward()
+			forward(&config.ProgressEvent{BytesRead: 2, BytesWritten: 1})
+			forward(&config.ProgressEvent{BytesRead: 5, BytesWritten: 3})
+
+			t.AddRead(1)
+
+			return nil
+		},
+		0,
+		6,
+		3,
+		false,
+	},
+}
+
+func TestTracker(t *testing.T) {
+	for _, tt := range trackerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []config.ProgressEvent{}
+			tracker := NewTracker(config.ProgressEventTypeArchive, tt.filesTotal, tt.bytesTotal, func(event *config.ProgressEvent) {
+				events = append(events, *event)
+			})
+
+			if err := tt.track(tracker); err != nil {
+				t.Fatal(err)
+			}
+
+			tracker.Finish()
+
+			if len(events) == 0 {
+				t.Errorf("Finish() reported no event")
+
+				return
+			}
+
+			last := events[len(events)-1]
+			if last.Name != "" {
+				t.Errorf("Finish() name = %v, want %v", last.Name, "")
+			}
+
+			if last.Type != config.ProgressEventTypeArchive || last.FilesTotal != tt.filesTotal || last.BytesTotal != tt.bytesTotal {
+				t.Errorf("Finish() = %v, want type %v with %v files and %v bytes total", last, config.ProgressEventTypeArchive, tt.filesTotal, tt.bytesTotal)
+			}
+
+			if last.FilesDone != tt.wantFiles {
+				t.Errorf("Finish() files done = %v, want %v", last.FilesDone, tt.wantFiles)
+			}
+
+			if last.BytesRead != tt.wantRead {
+				t.Errorf("Finish() bytes read = %v, want %v", last.BytesRead, tt.wantRead)
+			}
+
+			if last.BytesWritten != tt.wantWritten {
+				t.Errorf("Finish() bytes written = %v, want %v", last.BytesWritten, tt.wantWritten)
+			}
+
+			if (last.ETA >= 0) != tt.wantETAKnown {
+				t.Errorf("Finish() ETA = %v, want known %v", last.ETA, tt.wantETAKnown)
+			}
+
+			// All events but the last one are throttled
+			if len(events) > 2 {
+				t.Errorf("Tracker reported %v events, want at most %v", len(events), 2)
+			}
+		})
+	}
+}
+
+func TestNewTracker(t *testing.T) {
+	tracker := NewTracker(config.ProgressEventTypeArchive, 1, 1, nil)
+	if tracker != nil {
+		t.Errorf("NewTracker() = %v, want %v", tracker, nil)
+
+		return
+	}
+
+	// A nil tracker must not count anything or wrap the readers and writers
+	tracker.Start("a.txt")
+	tracker.AddRead(1)
+	tracker.AddWritten(1)
+	tracker.SetRead(1)
+	tracker.Done()
+	tracker.Finish()
+
+	r := strings.NewReader("")
+	if got := tracker.Reader(r); got != r {
+		t.Errorf("Reader() = %v, want %v", got, r)
+	}
+
+	w := &bytes.Buffer{}
+	if got := tracker.Writer(w); got != w {
+		t.Errorf("Writer() = %v, want %v", got, w)
+	}
+
+	if got := tracker.Forward(); got != nil {
+		t.Errorf("Forward() returned callback, want %v", nil)
+	}
+}
+
+func TestTracker_Writer(t *testing.T) {
+	tracker := NewTracker(config.ProgressEventTypeRestore, 0, 0, func(event *config.ProgressEvent) {})
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// Files can be sparse, so their writers have to keep seeking and truncating
+	w, ok := tracker.Writer(f).(seekTruncater)
+	if !ok {
+		t.Errorf("Writer() of file does not implement Seek and Truncate")
+
+		return
+	}
+
+	if _, err := w.Seek(4, io.SeekStart); err != nil {
+		t.Errorf("Seek() error = %v, wantErr %v", err, false)
+	}
+
+	if err := w.Truncate(8); err != nil {
+		t.Errorf("Truncate() error = %v, wantErr %v", err, false)
+	}
+
+	if _, ok := tracker.Writer(&bytes.Buffer{}).(io.Seeker); ok {
+		t.Errorf("Writer() of buffer implements Seek")
+	}
+}
@@ -6,10 +6,11 @@ const (
 	STFSRecordVersion  = STFSPrefix + "Version"
 	STFSRecordVersion1 = "1"
 
-	STFSRecordAction       = STFSPrefix + "Action"
-	STFSRecordActionCreate = "CREATE"
-	STFSRecordActionDelete = "DELETE"
-	STFSRecordActionUpdate = "UPDATE"
+	STFSRecordAction         = STFSPrefix + "Action"
+	STFSRecordActionCreate   = "CREATE"
+	STFSRecordActionDelete   = "DELETE"
+	STFSRecordActionUndelete = "UNDELETE"
+	STFSRecordActionUpdate   = "UPDATE"
 
 	STFSRecordReplacesContent      = STFSPrefix + "ReplacesContent"
 	STFSRecordReplacesContentTrue  = "true"
@@ -19,7 +20,21 @@ const (
 
 	STFSRecordUncompressedSize = STFSPrefix + "UncompressedSize"
 
+	STFSRecordHash = STFSPrefix + "Hash"
+
+	STFSRecordSparseMap = STFSPrefix + "SparseMap"
+
+	STFSRecordReferencesVolume = STFSPrefix + "ReferencesVolume"
+	STFSRecordReferencesRecord = STFSPrefix + "ReferencesRecord"
+	STFSRecordReferencesBlock  = STFSPrefix + "ReferencesBlock"
+
+	STFSRecordContinuationOffset = STFSPrefix + "ContinuationOffset"
+
+	STFSRecordSession = STFSPrefix + "Session"
+
 	STFSRecordSignature = STFSPrefix + "Signature"
 
 	STFSRecordEmbeddedHeader = STFSPrefix + "EmbeddedHeader"
+
+	SchilyRecordXattrPrefix = "SCHILY.xattr."
 )
@@ -0,0 +1,57 @@
+//go:build linux
+
+package sparse
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+const (
+	seekData = 3 // Seek to the next data region
+	seekHole = 4 // Seek to the next hole
+)
+
+// GetDataRegions returns the regions of f which contain data; holes are left out
+func GetDataRegions(f *os.File, size int64) ([]Region, error) {
+	regions := []Region{}
+
+	offset := int64(0)
+	for offset < size {
+		start, err := f.Seek(offset, seekData)
+		if err != nil {
+			// There is no more data after offset
+			if errors.Is(err, syscall.ENXIO) {
+				break
+			}
+
+			// The file system doesn't support detecting holes
+			if errors.Is(err, syscall.EINVAL) {
+				return []Region{{Offset: 0, Length: size}}, nil
+			}
+
+			return nil, err
+		}
+
+		end, err := f.Seek(start, seekHole)
+		if err != nil {
+			return nil, err
+		}
+
+		if end > size {
+			end = size
+		}
+
+		regions = append(regions, Region{Offset: start, Length: end - start})
+
+		offset = end
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return regions, nil
+}
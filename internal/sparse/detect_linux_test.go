@@ -0,0 +1,52 @@
+package sparse
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDataRegions(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	size := int64(16 * 1024 * 1024)
+	if _, err := f.WriteAt([]byte("data"), size/2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+
+	regions, err := GetDataRegions(f, size)
+	if err != nil {
+		t.Errorf("GetDataRegions() error = %v, wantErr %v", err, false)
+
+		return
+	}
+
+	if !IsSparse(regions, size) {
+		t.Skip("file system doesn't support holes")
+	}
+
+	// The data regions are at least the written bytes and are aligned to the blocks of the file system
+	found := false
+	for _, region := range regions {
+		if region.Offset <= size/2 && region.Offset+region.Length >= size/2+4 {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("GetDataRegions() = %v, want region containing offset %v", regions, size/2)
+	}
+
+	if offset, err := f.Seek(0, io.SeekCurrent); err != nil || offset != 0 {
+		t.Errorf("GetDataRegions() offset = %v, want %v", offset, 0)
+	}
+}
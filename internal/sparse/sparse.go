@@ -0,0 +1,246 @@
+package sparse
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var (
+	zeros = make([]byte, 32*1024)
+)
+
+type Region struct {
+	Offset int64
+	Length int64
+}
+
+// IsSparse returns true if the regions leave out parts of a file with size
+func IsSparse(regions []Region, size int64) bool {
+	length := int64(0)
+	for _, region := range regions {
+		length += region.Length
+	}
+
+	return length < size
+}
+
+// EncodeMap encodes regions as comma-separated offset and length pairs, like `GNU.sparse.map`
+func EncodeMap(regions []Region) string {
+	values := []string{}
+	for _, region := range regions {
+		values = append(values, strconv.FormatInt(region.Offset, 10), strconv.FormatInt(region.Length, 10))
+	}
+
+	return strings.Join(values, ",")
+}
+
+func DecodeMap(sparseMap string) ([]Region, error) {
+	regions := []Region{}
+	if sparseMap == "" {
+		return regions, nil
+	}
+
+	values := strings.Split(sparseMap, ",")
+	if len(values)%2 != 0 {
+		return nil, config.ErrSparseMapInvalid
+	}
+
+	for i := 0; i < len(values); i += 2 {
+		offset, err := strconv.ParseInt(values[i], 10, 64)
+		if err != nil {
+			return nil, config.ErrSparseMapInvalid
+		}
+
+		length, err := strconv.ParseInt(values[i+1], 10, 64)
+		if err != nil {
+			return nil, config.ErrSparseMapInvalid
+		}
+
+		regions = append(regions, Region{Offset: offset, Length: length})
+	}
+
+	return regions, nil
+}
+
+// Reader reads the data regions of a file; the holes are written to Holes as zeros so that it can hash the full content
+type Reader struct {
+	r       io.ReaderAt
+	regions []Region
+	size    int64
+	holes   io.Writer
+
+	region int
+	offset int64
+}
+
+func NewReader(r io.ReaderAt, regions []Region, size int64, holes io.Writer) *Reader {
+	return &Reader{
+		r:       r,
+		regions: regions,
+		size:    size,
+		holes:   holes,
+	}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for {
+		if r.region >= len(r.regions) {
+			// Skip the trailing hole
+			if err := r.skip(r.size); err != nil {
+				return 0, err
+			}
+
+			return 0, io.EOF
+		}
+
+		region := r.regions[r.region]
+		if err := r.skip(region.Offset); err != nil {
+			return 0, err
+		}
+
+		end := region.Offset + region.Length
+		if r.offset >= end {
+			r.region++
+
+			continue
+		}
+
+		if int64(len(p)) > end-r.offset {
+			p = p[:end-r.offset]
+		}
+
+		n, err := r.r.ReadAt(p, r.offset)
+		r.offset += int64(n)
+
+		if err == io.EOF && n > 0 {
+			err = nil
+		}
+
+		return n, err
+	}
+}
+
+func (r *Reader) skip(to int64) error {
+	if r.offset >= to {
+		return nil
+	}
+
+	if err := writeZeros(r.holes, to-r.offset); err != nil {
+		return err
+	}
+
+	r.offset = to
+
+	return nil
+}
+
+// Writer writes the data regions read by Reader back to their offsets, seeking over the holes if possible
+type Writer struct {
+	w       io.Writer
+	regions []Region
+
+	region    int
+	offset    int64
+	remaining int64
+}
+
+func NewWriter(w io.Writer, regions []Region) *Writer {
+	return &Writer{
+		w:       w,
+		regions: regions,
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if w.remaining == 0 {
+			if w.region >= len(w.regions) {
+				return written, config.ErrSparseMapInvalid
+			}
+
+			region := w.regions[w.region]
+			if err := w.skip(region.Offset); err != nil {
+				return written, err
+			}
+
+			w.remaining = region.Length
+			w.region++
+
+			continue
+		}
+
+		chunk := p
+		if int64(len(chunk)) > w.remaining {
+			chunk = chunk[:w.remaining]
+		}
+
+		n, err := w.w.Write(chunk)
+		written += n
+		w.offset += int64(n)
+		w.remaining -= int64(n)
+		if err != nil {
+			return written, err
+		}
+
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// Finish creates the trailing hole up to size
+func (w *Writer) Finish(size int64) error {
+	if w.offset >= size {
+		return nil
+	}
+
+	if truncater, ok := w.w.(interface{ Truncate(size int64) error }); ok {
+		if _, ok := w.w.(io.Seeker); ok {
+			w.offset = size
+
+			return truncater.Truncate(size)
+		}
+	}
+
+	return w.skip(size)
+}
+
+func (w *Writer) skip(to int64) error {
+	if w.offset >= to {
+		return nil
+	}
+
+	if seeker, ok := w.w.(io.Seeker); ok {
+		if _, err := seeker.Seek(to-w.offset, io.SeekCurrent); err != nil {
+			return err
+		}
+	} else if err := writeZeros(w.w, to-w.offset); err != nil {
+		return err
+	}
+
+	w.offset = to
+
+	return nil
+}
+
+func writeZeros(w io.Writer, n int64) error {
+	for n > 0 {
+		chunk := zeros
+		if int64(len(chunk)) > n {
+			chunk = chunk[:n]
+		}
+
+		written, err := w.Write(chunk)
+		if err != nil {
+			return err
+		}
+
+		n -= int64(written)
+	}
+
+	return nil
+}
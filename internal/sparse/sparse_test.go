@@ -0,0 +1,212 @@
+package sparse
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var mapTests = []struct {
+	name      string
+	sparseMap string
+	want      []Region
+	wantErr   error
+}{
+	{
+		"Can decode empty map",
+		"",
+		[]Region{},
+		nil,
+	},
+	{
+		"Can decode map with one region",
+		"0,512",
+		[]Region{{Offset: 0, Length: 512}},
+		nil,
+	},
+	{
+		"Can decode map with multiple regions",
+		"4096,10,1048576,20",
+		[]Region{{Offset: 4096, Length: 10}, {Offset: 1048576, Length: 20}},
+		nil,
+	},
+	{
+		"Can not decode map with odd amount of values",
+		"4096,10,1048576",
+		nil,
+		config.ErrSparseMapInvalid,
+	},
+	{
+		"Can not decode map with invalid value",
+		"4096,ten",
+		nil,
+		config.ErrSparseMapInvalid,
+	},
+}
+
+func TestDecodeMap(t *testing.T) {
+	for _, tt := range mapTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMap(tt.sparseMap)
+			if err != tt.wantErr {
+				t.Errorf("DecodeMap() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeMap() = %v, want %v", got, tt.want)
+			}
+
+			if tt.wantErr == nil {
+				if encoded := EncodeMap(got); encoded != tt.sparseMap {
+					t.Errorf("EncodeMap() = %v, want %v", encoded, tt.sparseMap)
+				}
+			}
+		})
+	}
+}
+
+var regionTests = []struct {
+	name       string
+	size       int64
+	regions    []Region
+	wantSparse bool
+}{
+	{
+		"Can copy file without holes",
+		100,
+		[]Region{{Offset: 0, Length: 100}},
+		false,
+	},
+	{
+		"Can copy file with leading hole",
+		100000,
+		[]Region{{Offset: 65536, Length: 34464}},
+		true,
+	},
+	{
+		"Can copy file with holes between regions",
+		200000,
+		[]Region{{Offset: 0, Length: 10}, {Offset: 70000, Length: 5000}, {Offset: 199990, Length: 10}},
+		true,
+	},
+	{
+		"Can copy file with trailing hole",
+		200000,
+		[]Region{{Offset: 0, Length: 4096}},
+		true,
+	},
+	{
+		"Can copy file which is one hole",
+		100000,
+		[]Region{},
+		true,
+	},
+}
+
+// getRegionContent creates content of size with non-zero bytes in the regions
+func getRegionContent(size int64, regions []Region) []byte {
+	content := make([]byte, size)
+	for _, region := range regions {
+		for i := region.Offset; i < region.Offset+region.Length; i++ {
+			content[i] = byte(i%251) + 1
+		}
+	}
+
+	return content
+}
+
+func TestReader_Writer(t *testing.T) {
+	for _, tt := range regionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSparse(tt.regions, tt.size); got != tt.wantSparse {
+				t.Errorf("IsSparse() = %v, want %v", got, tt.wantSparse)
+			}
+
+			content := getRegionContent(tt.size, tt.regions)
+
+			holes := &bytes.Buffer{}
+			data, err := io.ReadAll(NewReader(bytes.NewReader(content), tt.regions, tt.size, holes))
+			if err != nil {
+				t.Errorf("Reader.Read() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			length := int64(0)
+			for _, region := range tt.regions {
+				length += region.Length
+			}
+
+			if int64(len(data)) != length {
+				t.Errorf("Reader.Read() length = %v, want %v", len(data), length)
+			}
+
+			if int64(holes.Len()) != tt.size-length {
+				t.Errorf("Reader.Read() holes = %v, want %v", holes.Len(), tt.size-length)
+			}
+
+			// Write to a buffer, which can't seek and gets the holes as zeros
+			buf := &bytes.Buffer{}
+			w := NewWriter(buf, tt.regions)
+			if _, err := w.Write(data); err != nil {
+				t.Errorf("Writer.Write() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if err := w.Finish(tt.size); err != nil {
+				t.Errorf("Writer.Finish() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !bytes.Equal(buf.Bytes(), content) {
+				t.Errorf("Writer.Write() to buffer got %v bytes, want content of %v bytes", buf.Len(), len(content))
+			}
+
+			// Write to a file, which seeks over the holes and is truncated to the trailing hole
+			f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			w = NewWriter(f, tt.regions)
+			if _, err := w.Write(data); err != nil {
+				t.Errorf("Writer.Write() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if err := w.Finish(tt.size); err != nil {
+				t.Errorf("Writer.Finish() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err := os.ReadFile(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(got, content) {
+				t.Errorf("Writer.Write() to file got %v bytes, want content of %v bytes", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestWriter_Write(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, []Region{{Offset: 10, Length: 2}})
+
+	if _, err := w.Write([]byte("abc")); err != config.ErrSparseMapInvalid {
+		t.Errorf("Writer.Write() error = %v, want %v", err, config.ErrSparseMapInvalid)
+	}
+}
@@ -9,18 +9,19 @@ import (
 	"github.com/pojntfx/stfs/pkg/config"
 )
 
-func NewTapeWriter(f io.Writer, isRegular bool, recordSize int) (tw *tar.Writer, cleanup func(dirty *bool) error, err error) {
+// NewTapeWriter also returns a counter of the bytes which have been written to the archive, including those which are still buffered
+func NewTapeWriter(f io.Writer, isRegular bool, recordSize int) (tw *tar.Writer, counter *ioext.CounterWriter, cleanup func(dirty *bool) error, err error) {
 	var bw *bufio.Writer
-	var counter *ioext.CounterWriter
 	if isRegular {
-		tw = tar.NewWriter(f)
+		counter = &ioext.CounterWriter{Writer: f, BytesRead: 0}
+		tw = tar.NewWriter(counter)
 	} else {
 		bw = bufio.NewWriterSize(f, config.MagneticTapeBlockSize*recordSize)
 		counter = &ioext.CounterWriter{Writer: bw, BytesRead: 0}
 		tw = tar.NewWriter(counter)
 	}
 
-	return tw, func(dirty *bool) error {
+	return tw, counter, func(dirty *bool) error {
 		// Only write the trailer if we wrote to the archive
 		if *dirty {
 			if err := tw.Close(); err != nil {
@@ -0,0 +1,41 @@
+package xattrext
+
+import (
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/records"
+)
+
+var (
+	privilegedNamespaces = []string{"security.", "system.", "trusted."}
+)
+
+// IsPrivileged returns true if setting the attribute requires privileges, i.e. for SELinux labels, POSIX ACLs and file capabilities
+func IsPrivileged(name string) bool {
+	for _, namespace := range privilegedNamespaces {
+		if strings.HasPrefix(name, namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddToPAXRecords stores the extended attributes as `SCHILY.xattr.*` PAX records
+func AddToPAXRecords(paxRecords map[string]string, xattrs map[string]string) {
+	for name, value := range xattrs {
+		paxRecords[records.SchilyRecordXattrPrefix+name] = value
+	}
+}
+
+// GetFromPAXRecords returns the extended attributes stored in the PAX records
+func GetFromPAXRecords(paxRecords map[string]string) map[string]string {
+	xattrs := map[string]string{}
+	for key, value := range paxRecords {
+		if strings.HasPrefix(key, records.SchilyRecordXattrPrefix) {
+			xattrs[strings.TrimPrefix(key, records.SchilyRecordXattrPrefix)] = value
+		}
+	}
+
+	return xattrs
+}
@@ -0,0 +1,78 @@
+//go:build linux
+
+package xattrext
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// Get returns the extended attributes of path, including POSIX ACLs and file capabilities
+func Get(path string) (map[string]string, error) {
+	xattrs := map[string]string{}
+
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		if isUnsupported(err) {
+			return xattrs, nil
+		}
+
+		return nil, err
+	}
+
+	if size == 0 {
+		return xattrs, nil
+	}
+
+	names := make([]byte, size)
+	size, err = syscall.Listxattr(path, names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Split(strings.TrimSuffix(string(names[:size]), "\x00"), "\x00") {
+		valueSize, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			// The attribute has been removed in the meantime
+			if errors.Is(err, syscall.ENODATA) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		value := make([]byte, valueSize)
+		valueSize, err = syscall.Getxattr(path, name, value)
+		if err != nil {
+			return nil, err
+		}
+
+		xattrs[name] = string(value[:valueSize])
+	}
+
+	return xattrs, nil
+}
+
+// Set sets the extended attributes of path; those which require privileges are skipped unless privileged is set
+func Set(path string, xattrs map[string]string, privileged bool) error {
+	for name, value := range xattrs {
+		if !privileged && IsPrivileged(name) {
+			continue
+		}
+
+		if err := syscall.Setxattr(path, name, []byte(value), 0); err != nil {
+			if isUnsupported(err) {
+				return nil
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
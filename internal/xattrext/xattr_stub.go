@@ -0,0 +1,13 @@
+//go:build !linux
+
+package xattrext
+
+// Get returns no extended attributes, as they are not supported on this system
+func Get(path string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// Set ignores the extended attributes, as they are not supported on this system
+func Set(path string, xattrs map[string]string, privileged bool) error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"archive/tar"
 	"context"
 	"io"
 	"io/fs"
@@ -15,11 +16,13 @@ type ReadSeekFder interface {
 type DriveReaderConfig struct {
 	Drive          ReadSeekFder
 	DriveIsRegular bool
+	Volume         int
 }
 
 type DriveWriterConfig struct {
 	Drive          io.Writer
 	DriveIsRegular bool
+	Volume         int
 }
 
 type BackendConfig struct {
@@ -29,6 +32,10 @@ type BackendConfig struct {
 	GetReader   func() (DriveReaderConfig, error)
 	CloseReader func() error
 
+	ChangeVolume func(volume int) error // Switches to another tape or tar file; nil if only one volume is supported
+
+	TruncateVolume func(size int64) error // Cuts the current tar file off after size bytes to remove an incomplete write; nil if unsupported
+
 	MagneticTapeIO MagneticTapeIO
 }
 
@@ -54,32 +61,75 @@ type Header struct {
 	Devminor        int64
 	Paxrecords      string
 	Format          int64
+	Volume          int64
+	Lastknownvolume int64
+	Hash            string
+}
+
+// HeaderVersion is the state of a header after an operation has been indexed
+type HeaderVersion struct {
+	Session int64
+	Header  *Header
 }
 
 type MetadataPersister interface {
 	UpsertHeader(ctx context.Context, dbhdr *Header, initializing bool) error
 	UpdateHeaderMetadata(ctx context.Context, dbhdr *Header) error
-	MoveHeader(ctx context.Context, oldName string, newName string, lastknownrecord, lastknownblock int64) error
+	MoveHeader(ctx context.Context, oldName string, newName string, lastknownvolume, lastknownrecord, lastknownblock int64) error
 	GetHeaders(ctx context.Context) ([]*Header, error)
 	GetHeader(ctx context.Context, name string) (*Header, error)
 	GetHeaderByLinkname(ctx context.Context, linkname string) (*Header, error)
+	GetHeaderByHash(ctx context.Context, hash string) (*Header, error)
+	ResolveHardlink(ctx context.Context, linkname string) (*Header, error)
 	GetHeaderChildren(ctx context.Context, name string) ([]*Header, error)
 	GetRootPath(ctx context.Context) (string, error)
 	GetHeaderDirectChildren(ctx context.Context, name string, limit int) ([]*Header, error)
-	DeleteHeader(ctx context.Context, name string, lastknownrecord, lastknownblock int64) (*Header, error)
+	DeleteHeader(ctx context.Context, name string, lastknownvolume, lastknownrecord, lastknownblock int64) (*Header, error)
+	GetDeletedHeader(ctx context.Context, name string) (*Header, error)
+	GetDeletedHeaderChildren(ctx context.Context, name string) ([]*Header, error)
+	UndeleteHeader(ctx context.Context, name string, linkname string, lastknownvolume, lastknownrecord, lastknownblock int64) (*Header, error)
 	GetLastIndexedRecordAndBlock(ctx context.Context, recordSize int) (int64, int64, error)
+	GetLastIndexedVolume(ctx context.Context) (int64, error)
 	PurgeAllHeaders(ctx context.Context) error
+	AddHeaderVersion(ctx context.Context, session int64, replacesName string, lastknownvolume, lastknownrecord, lastknownblock int64) error
+	GetHeaderHistory(ctx context.Context, name string) ([]*HeaderVersion, error)
+	SetSnapshot(ctx context.Context, session int64) error
+}
+
+// JournalSession is a write to the tape or tar file which hasn't been indexed yet
+type JournalSession struct {
+	Volume       int64
+	Record       int64
+	Block        int64
+	Offset       int64 // Amount of headers at Record and Block which have been indexed before the write
+	Overwrite    bool
+	Initializing bool
+
+	Headers []*Header // Plaintext headers in the order in which they are written
+}
+
+type JournalPersister interface {
+	Begin(session *JournalSession) error
+	Append(hdr *Header) error
+	Get() (*JournalSession, error) // Returns nil if there is no unfinished session
+	Commit() error
+	Close() error // Closes the unfinished session without committing it, so that it is indexed by the next write
 }
 
 type MetadataConfig struct {
 	Metadata MetadataPersister
+	Journal  JournalPersister // Records writes until they are indexed so that they can be indexed after a crash; nil disables journaling
 }
 
 type PipeConfig struct {
-	Compression string
-	Encryption  string
-	Signature   string
-	RecordSize  int
+	Compression  string
+	Encryption   string
+	Signature    string
+	RecordSize   int
+	DataShards   int   // Amount of data records per group of parity records
+	ParityShards int   // Amount of parity records per group; 0 disables parity
+	Workers      int   // Amount of files which are compressed, encrypted and signed concurrently when archiving; 0 or 1 processes them one after another
+	MemoryBudget int64 // Maximum amount of bytes of payloads which are kept in memory while archiving; larger payloads are spooled to temporary files; 0 uses the default
 }
 
 type CryptoConfig struct {
@@ -93,10 +143,28 @@ type PasswordConfig struct {
 }
 
 type FileConfig struct {
-	GetFile func() (io.ReadSeekCloser, error)
-	Info    fs.FileInfo
-	Path    string
-	Link    string
+	GetFile   func() (io.ReadSeekCloser, error)
+	GetReader func() (io.ReadCloser, error) // Opens content which can't be seeked, such as a pipe or an entry of a tar archive; used instead of GetFile if set
+	Info      fs.FileInfo
+	Path      string
+	Link      string
+	Stream    bool              // The size of Info is unknown; the content is read until EOF
+	Xattrs    map[string]string // Extended attributes, including POSIX ACLs and file capabilities
+
+	Hardlink bool // Link is the path of the file which this file is a hard link to
+}
+
+// RestoreSink creates the files, directories and links which are restored; hdr is the archived header, its name and size are the ones on the tape or tar file
+type RestoreSink interface {
+	Lstat(path string) (fs.FileInfo, error) // Returns an error for which os.IsNotExist is true if nothing is at path, i.e. to resolve conflicts with existing files
+	CreateFile(path string, hdr *tar.Header) (io.WriteCloser, error)
+	CreateDirectory(path string, hdr *tar.Header) error
+	CreateSymlink(path string, hdr *tar.Header) error
+	CreateHardlink(path string, target string, hdr *tar.Header) error // Returns ErrHardlinksUnsupported to restore the content of target instead
+	CreateDevice(path string, hdr *tar.Header) error                  // Character and block devices and FIFOs
+	SetXattrs(path string, xattrs map[string]string) error            // Called before SetMetadata, which has to keep them, i.e. file capabilities which changing the owner clears
+	SetMetadata(path string, hdr *tar.Header) error                   // Called once the file has been written, i.e. to set its ownership, permissions and times
+	Close() error                                                     // Called by the creator of the sink once everything has been restored, i.e. to set the metadata of directories after their children
 }
 
 type MagneticTapeIO interface {
@@ -106,3 +174,37 @@ type MagneticTapeIO interface {
 	EjectTape(fd uintptr) error
 	SeekToRecordOnTape(fd uintptr, record int32) error
 }
+
+type RestorePlanEntry struct {
+	Header   *Header // Header of the file or directory which is restored
+	Content  *Header // Header whose content is restored; the target for hard links whose target isn't restored, Header otherwise
+	To       string
+	Linkname string // Path of the restored file to link to instead of reading the content again; empty if the content is read
+}
+
+// RestorePass is a sequential read of files which are close to each other on a volume
+type RestorePass struct {
+	Volume      int64
+	StartRecord int64
+	EndRecord   int64 // Estimated from the sizes of the files
+	Entries     []*RestorePlanEntry
+}
+
+type TapeMovement struct {
+	Seeks         int64 // Repositionings of the tape, including the ones to the start of the volumes
+	VolumeChanges int64
+	SeekRecords   int64 // Records the tape moves over while repositioning
+	ReadRecords   int64 // Records which are read, including the ones between the files of a pass
+}
+
+// RestorePlan is the order in which files are restored, sorted by their position on the tape or tar file
+type RestorePlan struct {
+	Passes []*RestorePass
+	Links  []*RestorePlanEntry // Linked after the passes, or read if linking isn't supported
+
+	Files int64
+	Bytes int64
+
+	Movement          TapeMovement // Estimated for the planned order
+	UnplannedMovement TapeMovement // Estimated for restoring the files in the order of the index
+}
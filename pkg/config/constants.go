@@ -21,11 +21,39 @@ const (
 	CompressionLevelBalancedKey = "balanced"
 	CompressionLevelSmallestKey = "smallest"
 
-	HeaderEventTypeArchive = "archive"
-	HeaderEventTypeDelete  = "delete"
-	HeaderEventTypeMove    = "move"
-	HeaderEventTypeRestore = "restore"
-	HeaderEventTypeUpdate  = "update"
+	HeaderEventTypeArchive  = "archive"
+	HeaderEventTypeCompact  = "compact"
+	HeaderEventTypeDelete   = "delete"
+	HeaderEventTypeMove     = "move"
+	HeaderEventTypeRecover  = "recover"
+	HeaderEventTypeRestore  = "restore"
+	HeaderEventTypeUndelete = "undelete"
+	HeaderEventTypeUpdate   = "update"
+
+	VerifyEventTypeOK       = "ok"
+	VerifyEventTypeMismatch = "mismatch"
+	VerifyEventTypeMissing  = "missing"
+	VerifyEventTypeError    = "error"
+
+	ProgressEventTypeArchive = "archive"
+	ProgressEventTypeUpdate  = "update"
+	ProgressEventTypeRestore = "restore"
+	ProgressEventTypeIndex   = "index"
+	ProgressEventTypeVerify  = "verify"
+
+	ConflictPolicyOverwrite    = "overwrite"
+	ConflictPolicySkipExisting = "skip-existing"
+	ConflictPolicyKeepNewer    = "keep-newer"
+	ConflictPolicyRename       = "rename"
+
+	ConflictEventTypeOverwrite = "overwrite"
+	ConflictEventTypeSkip      = "skip"
+	ConflictEventTypeSkipNewer = "skip-newer" // The existing file is newer than the archived one
+	ConflictEventTypeRename    = "rename"
+
+	ExportFormatTar     = "tar"
+	ExportFormatTarZstd = "tar.zst"
+	ExportFormatZip     = "zip"
 
 	FileSystemNameSTFS = "STFS"
 
@@ -50,4 +78,8 @@ var (
 	KnownFileSystemCacheTypes = []string{NoneKey, FileSystemCacheTypeMemory, FileSystemCacheTypeDir}
 
 	KnownWriteCacheTypes = []string{WriteCacheTypeMemory, WriteCacheTypeFile}
+
+	KnownExportFormats = []string{ExportFormatTar, ExportFormatTarZstd, ExportFormatZip}
+
+	KnownConflictPolicies = []string{ConflictPolicyOverwrite, ConflictPolicySkipExisting, ConflictPolicyKeepNewer, ConflictPolicyRename}
 )
@@ -27,6 +27,7 @@ var (
 
 	ErrTarHeaderMissing         = errors.New("tar header missing")
 	ErrTarHeaderEmbeddedMissing = errors.New("embedded tar header missing")
+	ErrTarEntryPassed           = errors.New("tar entry can't be opened anymore, as the archive has been read past it")
 
 	ErrTapeDrivesUnsupported = errors.New("system unsupported for tape drives")
 
@@ -36,6 +37,7 @@ var (
 	ErrNotImplemented = errors.New("not implemented")
 	ErrIsDirectory    = errors.New("is a directory")
 	ErrIsFile         = errors.New("is a file")
+	ErrIsHardlink     = errors.New("is a hard link")
 
 	ErrFileSystemCacheTypeUnsupported = errors.New("file system cache type unsupported")
 	ErrFileSystemCacheTypeUnknown     = errors.New("file system cache type unknown")
@@ -43,6 +45,38 @@ var (
 	ErrWriteCacheTypeUnsupported = errors.New("write cache type unsupported")
 	ErrWriteCacheTypeUnknown     = errors.New("write cache type unknown")
 
+	ErrNameRequired   = errors.New("name is required")
+	ErrSessionInvalid = errors.New("session invalid")
+	ErrAtInvalid      = errors.New("point in time invalid, must be a session or RFC3339 timestamp")
+	ErrModeInvalid    = errors.New("mode invalid, must be octal permissions such as 0644")
+	ErrModTimeInvalid = errors.New("modification time invalid, must be a RFC3339 timestamp")
+
+	ErrVolumeChangeUnsupported = errors.New("volume change unsupported")
+	ErrContinuationMissing     = errors.New("continuation header missing")
+
+	ErrSparseMapInvalid = errors.New("sparse map invalid")
+
+	ErrIntegrityCheckFailed = errors.New("integrity check failed")
+
+	ErrCompactDestinationInvalid = errors.New("compaction destination must differ from the source")
+
+	ErrUndeleteOccupied = errors.New("can't undelete, a file or directory with this name exists")
+
+	ErrParityInsufficient = errors.New("too many damaged records to rebuild them from parity")
+
+	ErrResumeStateMissing     = errors.New("no interrupted archive run to resume")
+	ErrResumeStdinUnsupported = errors.New("archiving stdin can't be resumed")
+
+	ErrJournalInvalid        = errors.New("journal invalid")
+	ErrJournalSessionMissing = errors.New("journal session missing, begin a session before appending to it")
+
+	ErrHardlinksUnsupported = errors.New("hard links are unsupported by this restore sink")
+	ErrDevicesUnsupported   = errors.New("device nodes are unsupported on this platform")
+	ErrIDMapInvalid         = errors.New("ID map invalid, must be of the form from:to")
+
+	ErrConflictPolicyUnknown = errors.New("conflict policy unknown")
+	ErrExportFormatUnknown   = errors.New("export format unknown")
+
 	ErrNoRootDirectory   = errors.New("root directory could not be found")
 	ErrDirectoryNotEmpty = errors.New("directory not empty")
 )
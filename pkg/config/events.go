@@ -1,7 +1,42 @@
 package config
 
+import "time"
+
 type HeaderEvent struct {
 	Type    string
 	Indexed bool
 	Header  *Header
 }
+
+type VerifyEvent struct {
+	Type      string
+	Header    *Header
+	Expected  string // Hash which has been recorded when archiving the file
+	Actual    string // Hash of the content which has been read
+	Corrected int    // Amount of records which have been rebuilt from the parity records
+	Error     error
+}
+
+type CorrectionEvent struct {
+	Volume    int
+	Record    int64 // First record of the group which has been corrected
+	Corrected int   // Amount of records which have been rebuilt from the parity records
+}
+
+type ConflictEvent struct {
+	Type string // What the conflict policy decided to do with the file
+	Path string // Path which already existed
+	To   string // Path which the file has been restored to instead; empty if it has been skipped
+}
+
+type ProgressEvent struct {
+	Type         string
+	Name         string        // File which is currently being processed
+	FilesDone    int64         // Files which have been processed completely
+	FilesTotal   int64         // 0 if unknown
+	BytesRead    int64         // Bytes which have been read from the source
+	BytesWritten int64         // Bytes which have been written to the destination, after compression and encryption if they are enabled
+	BytesTotal   int64         // Bytes which will be read from the source; 0 if unknown
+	Throughput   float64       // Bytes read per second
+	ETA          time.Duration // -1 if unknown
+}
@@ -0,0 +1,227 @@
+package filter
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the name of the per-directory files with exclude patterns.
+// Like a .gitignore file, an ignore file is archived with the other files (unless a pattern excludes it), so that updates of restored files use the same rules.
+const IgnoreFileName = ".stfsignore"
+
+type pattern struct {
+	base     string   // Directory which the pattern is relative to
+	segments []string // Slash-separated parts of the pattern, with "**" matching any amount of directories
+	anchored bool     // The pattern only matches relative to base instead of at any depth below it
+	dirOnly  bool
+	negate   bool
+}
+
+// Rules decides which files to exclude using gitignore-style patterns; the last matching pattern wins
+type Rules struct {
+	low  []pattern // Patterns from exclude files and per-directory ignore files
+	high []pattern // Patterns from excludes and includes, which override the ignore files
+}
+
+// NewRules creates rules from the patterns of an exclude file and from explicit excludes and includes.
+// Includes are negated patterns which re-include files that would otherwise be excluded; as all files are included by default,
+// they have no effect on their own. Like in gitignore, they can't re-include a file in an excluded directory, as its contents are skipped.
+func NewRules(patterns []string, excludes []string, includes []string) (*Rules, error) {
+	r := &Rules{}
+
+	for _, p := range patterns {
+		if err := r.add(&r.low, "", p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range excludes {
+		if err := r.add(&r.high, "", p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range includes {
+		if err := r.add(&r.high, "", "!"+strings.TrimPrefix(p, "!")); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// ParsePatterns reads the patterns of an exclude or ignore file, skipping blank lines and comments
+func ParsePatterns(reader io.Reader) ([]string, error) {
+	patterns := []string{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// Trailing spaces are ignored unless they are escaped
+		if trimmed := strings.TrimRight(line, " "); !strings.HasSuffix(trimmed, "\\") {
+			line = trimmed
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// Load returns the rules extended by the ignore file in the directory dir, which is at rel relative to the root
+func (r *Rules) Load(dir string, rel string) (*Rules, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	patterns, err := ParsePatterns(f)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{
+		low:  append([]pattern{}, r.low...),
+		high: r.high,
+	}
+
+	base := path.Clean(rel)
+	if base == "." {
+		base = ""
+	}
+
+	for _, p := range patterns {
+		if err := rules.add(&rules.low, base, p); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules, nil
+}
+
+// Excluded checks whether the file at rel relative to the root is excluded; the contents of excluded directories should be skipped
+func (r *Rules) Excluded(rel string, isDir bool) bool {
+	rel = path.Clean(rel)
+	if rel == "." || rel == "/" {
+		return false
+	}
+	rel = strings.TrimPrefix(rel, "/")
+
+	excluded := false
+	for _, patterns := range [][]pattern{r.low, r.high} {
+		for _, p := range patterns {
+			if p.matches(rel, isDir) {
+				excluded = !p.negate
+			}
+		}
+	}
+
+	return excluded
+}
+
+func (r *Rules) add(patterns *[]pattern, base string, raw string) error {
+	p := pattern{
+		base: base,
+	}
+
+	if strings.HasPrefix(raw, "!") {
+		p.negate = true
+		raw = raw[1:]
+	} else if strings.HasPrefix(raw, "\\!") || strings.HasPrefix(raw, "\\#") {
+		raw = raw[1:]
+	}
+
+	if strings.HasSuffix(raw, "/") {
+		p.dirOnly = true
+		raw = strings.TrimRight(raw, "/")
+	}
+
+	// Patterns with a slash in the beginning or middle are relative to the base
+	if strings.Contains(raw, "/") {
+		p.anchored = true
+		raw = strings.TrimLeft(raw, "/")
+	}
+
+	if raw == "" {
+		return nil
+	}
+
+	p.segments = strings.Split(raw, "/")
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+
+	*patterns = append(*patterns, p)
+
+	return nil
+}
+
+func (p pattern) matches(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if p.base != "" {
+		if !strings.HasPrefix(rel, p.base+"/") {
+			return false
+		}
+
+		rel = strings.TrimPrefix(rel, p.base+"/")
+	}
+
+	parts := strings.Split(rel, "/")
+	if !p.anchored {
+		matched, _ := path.Match(p.segments[0], parts[len(parts)-1])
+
+		return matched
+	}
+
+	return matchSegments(p.segments, parts)
+}
+
+func matchSegments(segments []string, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	if segments[0] == "**" {
+		// A trailing "**" matches everything inside, but not the directory itself
+		if len(segments) == 1 {
+			return len(parts) > 0
+		}
+
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	if matched, _ := path.Match(segments[0], parts[0]); !matched {
+		return false
+	}
+
+	return matchSegments(segments[1:], parts[1:])
+}
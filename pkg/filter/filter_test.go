@@ -0,0 +1,431 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var excludedTests = []struct {
+	name     string
+	patterns []string // Patterns of the exclude file
+	excludes []string
+	includes []string
+	rel      string
+	isDir    bool
+	want     bool
+}{
+	{
+		"Can include everything without patterns",
+		[]string{},
+		[]string{},
+		[]string{},
+		"a/b.txt",
+		false,
+		false,
+	},
+	{
+		"Can not exclude root",
+		[]string{},
+		[]string{"*"},
+		[]string{},
+		".",
+		true,
+		false,
+	},
+	{
+		"Can exclude by name at any depth",
+		[]string{},
+		[]string{"*.log"},
+		[]string{},
+		"a/b/c.log",
+		false,
+		true,
+	},
+	{
+		"Can exclude directory by name",
+		[]string{},
+		[]string{"build"},
+		[]string{},
+		"a/build",
+		true,
+		true,
+	},
+	{
+		"Can anchor pattern with leading slash",
+		[]string{},
+		[]string{"/a.txt"},
+		[]string{},
+		"a.txt",
+		false,
+		true,
+	},
+	{
+		"Can not match anchored pattern below root",
+		[]string{},
+		[]string{"/a.txt"},
+		[]string{},
+		"b/a.txt",
+		false,
+		false,
+	},
+	{
+		"Can anchor pattern with slash in the middle",
+		[]string{},
+		[]string{"b/c.txt"},
+		[]string{},
+		"b/c.txt",
+		false,
+		true,
+	},
+	{
+		"Can not match pattern with slash in the middle below root",
+		[]string{},
+		[]string{"b/c.txt"},
+		[]string{},
+		"a/b/c.txt",
+		false,
+		false,
+	},
+	{
+		"Can match leading ** in root",
+		[]string{},
+		[]string{"**/c.txt"},
+		[]string{},
+		"c.txt",
+		false,
+		true,
+	},
+	{
+		"Can match leading ** at any depth",
+		[]string{},
+		[]string{"**/c.txt"},
+		[]string{},
+		"a/b/c.txt",
+		false,
+		true,
+	},
+	{
+		"Can match ** in the middle without directories",
+		[]string{},
+		[]string{"a/**/c.txt"},
+		[]string{},
+		"a/c.txt",
+		false,
+		true,
+	},
+	{
+		"Can match ** in the middle with multiple directories",
+		[]string{},
+		[]string{"a/**/c.txt"},
+		[]string{},
+		"a/b/d/c.txt",
+		false,
+		true,
+	},
+	{
+		"Can match trailing ** inside directory",
+		[]string{},
+		[]string{"a/**"},
+		[]string{},
+		"a/b.txt",
+		false,
+		true,
+	},
+	{
+		"Can not match trailing ** on directory itself",
+		[]string{},
+		[]string{"a/**"},
+		[]string{},
+		"a",
+		true,
+		false,
+	},
+	{
+		"Can match dir-only pattern on directory",
+		[]string{},
+		[]string{"b/"},
+		[]string{},
+		"b",
+		true,
+		true,
+	},
+	{
+		"Can not match dir-only pattern on file",
+		[]string{},
+		[]string{"b/"},
+		[]string{},
+		"b",
+		false,
+		false,
+	},
+	{
+		"Can negate pattern in exclude file",
+		[]string{"*.log", "!keep.log"},
+		[]string{},
+		[]string{},
+		"keep.log",
+		false,
+		false,
+	},
+	{
+		"Can use last matching pattern",
+		[]string{"!keep.log", "*.log"},
+		[]string{},
+		[]string{},
+		"keep.log",
+		false,
+		true,
+	},
+	{
+		"Can override exclude file with excludes",
+		[]string{"!a.log"},
+		[]string{"*.log"},
+		[]string{},
+		"a.log",
+		false,
+		true,
+	},
+	{
+		"Can re-include excluded file",
+		[]string{},
+		[]string{"*.log"},
+		[]string{"a.log"},
+		"a.log",
+		false,
+		false,
+	},
+	{
+		"Can re-include file with negated include",
+		[]string{},
+		[]string{"*.log"},
+		[]string{"!a.log"},
+		"a.log",
+		false,
+		false,
+	},
+	{
+		"Can not exclude other files with include",
+		[]string{},
+		[]string{},
+		[]string{"a.log"},
+		"b.txt",
+		false,
+		false,
+	},
+	{
+		"Can match escaped exclamation mark",
+		[]string{},
+		[]string{"\\!a.txt"},
+		[]string{},
+		"!a.txt",
+		false,
+		true,
+	},
+}
+
+func TestRules_Excluded(t *testing.T) {
+	for _, tt := range excludedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRules(tt.patterns, tt.excludes, tt.includes)
+			if err != nil {
+				t.Errorf("NewRules() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if got := r.Excluded(tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("Excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var newRulesTests = []struct {
+	name     string
+	excludes []string
+	wantErr  bool
+}{
+	{
+		"Can create rules with valid pattern",
+		[]string{"[ab].txt"},
+		false,
+	},
+	{
+		"Can not create rules with invalid pattern",
+		[]string{"[a.txt"},
+		true,
+	},
+}
+
+func TestNewRules(t *testing.T) {
+	for _, tt := range newRulesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRules([]string{}, tt.excludes, []string{}); (err != nil) != tt.wantErr {
+				t.Errorf("NewRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+var parsePatternsTests = []struct {
+	name  string
+	input string
+	want  []string
+}{
+	{
+		"Can parse patterns",
+		"a.txt\nb/\n",
+		[]string{"a.txt", "b/"},
+	},
+	{
+		"Can skip blank lines and comments",
+		"# Comment\n\na.txt\n",
+		[]string{"a.txt"},
+	},
+	{
+		"Can keep escaped comment",
+		"\\#a.txt\n",
+		[]string{"\\#a.txt"},
+	},
+	{
+		"Can trim trailing spaces and carriage returns",
+		"a.txt  \r\nb.txt\r\n",
+		[]string{"a.txt", "b.txt"},
+	},
+	{
+		"Can keep escaped trailing space",
+		"a.txt\\ \n",
+		[]string{"a.txt\\ "},
+	},
+}
+
+func TestParsePatterns(t *testing.T) {
+	for _, tt := range parsePatternsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePatterns(strings.NewReader(tt.input))
+			if err != nil {
+				t.Errorf("ParsePatterns() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePatterns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var loadTests = []struct {
+	name     string
+	excludes []string
+	dirs     []string // Directories to load the ignore files of, from the root down
+	rel      string
+	isDir    bool
+	want     bool
+}{
+	{
+		"Can exclude by ignore file",
+		[]string{},
+		[]string{"."},
+		"a.tmp",
+		false,
+		true,
+	},
+	{
+		"Can exclude by parent ignore file",
+		[]string{},
+		[]string{".", "sub"},
+		"sub/a.tmp",
+		false,
+		true,
+	},
+	{
+		"Can anchor ignore file patterns to their directory",
+		[]string{},
+		[]string{".", "sub"},
+		"sub/top.txt",
+		false,
+		false,
+	},
+	{
+		"Can exclude by ignore file in subdirectory",
+		[]string{},
+		[]string{".", "sub"},
+		"sub/c.txt",
+		false,
+		true,
+	},
+	{
+		"Can not exclude by ignore file outside of its directory",
+		[]string{},
+		[]string{".", "sub"},
+		"c.txt",
+		false,
+		false,
+	},
+	{
+		"Can negate parent ignore file",
+		[]string{},
+		[]string{".", "sub"},
+		"sub/keep.tmp",
+		false,
+		false,
+	},
+	{
+		"Can override ignore file with excludes",
+		[]string{"sub/keep.tmp"},
+		[]string{".", "sub"},
+		"sub/keep.tmp",
+		false,
+		true,
+	},
+	{
+		"Can load directory without ignore file",
+		[]string{},
+		[]string{".", "sub", "sub/empty"},
+		"sub/empty/c.txt",
+		false,
+		true,
+	},
+}
+
+func TestRules_Load(t *testing.T) {
+	for _, tt := range loadTests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range map[string]string{
+				IgnoreFileName:                       "*.tmp\n/top.txt\n",
+				filepath.Join("sub", IgnoreFileName): "c.txt\n!keep.tmp\n",
+			} {
+				if err := os.MkdirAll(filepath.Join(root, "sub", "empty"), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := NewRules([]string{}, tt.excludes, []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, dir := range tt.dirs {
+				if r, err = r.Load(filepath.Join(root, filepath.FromSlash(dir)), dir); err != nil {
+					t.Errorf("Load() error = %v, wantErr %v", err, false)
+
+					return
+				}
+			}
+
+			if got := r.Excluded(tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("Excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"database/sql"
 	"io"
-	"io/fs"
 	"os"
 	"path"
 	"sync"
@@ -17,6 +16,7 @@ import (
 	"github.com/pojntfx/stfs/pkg/inventory"
 	"github.com/pojntfx/stfs/pkg/logging"
 	"github.com/pojntfx/stfs/pkg/operations"
+	"github.com/pojntfx/stfs/pkg/sinks"
 	"github.com/spf13/afero"
 )
 
@@ -253,18 +253,14 @@ func (f *File) enterWriteMode() error {
 		// Read existing file into buffer
 		if exists {
 			if err := f.readOps.Restore(
-				func(path string, mode fs.FileMode) (io.WriteCloser, error) {
-					// Don't close the file here, we want to re-use it!
-					return ioext.AddCloseNopToWriter(f.writeBuf), nil
-				},
-				func(path string, mode fs.FileMode) error {
-					// Not necessary; can't read on a directory
-					return nil
-				},
+				sinks.NewWriterSink(ioext.AddCloseNopToWriter(f.writeBuf)), // Don't close the file here, we want to re-use it!
 
 				f.path,
 				"",
 				true,
+				config.ConflictPolicyOverwrite,
+
+				nil,
 			); err != nil {
 				return err
 			}
@@ -329,17 +325,14 @@ func (f *File) seekWithoutLocking(offset int64, whence int) (int64, error) {
 
 		go func() {
 			if err := f.readOps.Restore(
-				func(path string, mode fs.FileMode) (io.WriteCloser, error) {
-					return writer, nil
-				},
-				func(path string, mode fs.FileMode) error {
-					// Not necessary; can't read on a directory
-					return nil
-				},
+				sinks.NewWriterSink(writer),
 
 				f.path,
 				"",
 				true,
+				config.ConflictPolicyOverwrite,
+
+				nil,
 			); err != nil {
 				if err == io.ErrClosedPipe {
 					return
@@ -518,17 +511,14 @@ func (f *File) Read(p []byte) (n int, err error) {
 
 		go func() {
 			if err := f.readOps.Restore(
-				func(path string, mode fs.FileMode) (io.WriteCloser, error) {
-					return writer, nil
-				},
-				func(path string, mode fs.FileMode) error {
-					// Not necessary; can't read on a directory
-					return nil
-				},
+				sinks.NewWriterSink(writer),
 
 				f.path,
 				"",
 				true,
+				config.ConflictPolicyOverwrite,
+
+				nil,
 			); err != nil {
 				if err == io.ErrClosedPipe {
 					return
@@ -35,6 +35,7 @@ type STFS struct {
 	compressionLevel         string
 	getFileBuffer            func() (cache.WriteCache, func() error, error)
 	readOnly                 bool
+	asOf                     int64
 	writePermImpliesReadPerm bool
 
 	ioLock sync.Mutex
@@ -52,6 +53,7 @@ func NewSTFS(
 	compressionLevel string,
 	getFileBuffer func() (cache.WriteCache, func() error, error),
 	readOnly bool,
+	asOf int64, // Session of the snapshot to serve read-only once initialized; 0 serves the latest state
 	writePermImpliesReadPerm bool,
 
 	onHeader func(hdr *config.Header),
@@ -65,7 +67,8 @@ func NewSTFS(
 
 		compressionLevel:         compressionLevel,
 		getFileBuffer:            getFileBuffer,
-		readOnly:                 readOnly,
+		readOnly:                 readOnly || asOf > 0,
+		asOf:                     asOf,
 		writePermImpliesReadPerm: writePermImpliesReadPerm,
 
 		onHeader: onHeader,
@@ -211,6 +214,19 @@ func (f *STFS) Initialize(rootProposal string, rootPerm os.FileMode) (root strin
 	f.ioLock.Lock()
 	defer f.ioLock.Unlock()
 
+	// Switch to the snapshot once the index is complete
+	defer func() {
+		if err != nil || f.asOf <= 0 {
+			return
+		}
+
+		if err = f.metadata.Metadata.SetSnapshot(context.Background(), f.asOf); err != nil {
+			return
+		}
+
+		root, err = f.metadata.Metadata.GetRootPath(context.Background())
+	}()
+
 	existingRoot, err := f.metadata.Metadata.GetRootPath(context.Background())
 	if err == config.ErrNoRootDirectory {
 		mkdirRoot := func() (string, error) {
@@ -256,6 +272,8 @@ func (f *STFS) Initialize(rootProposal string, rootPerm os.FileMode) (root strin
 			},
 
 			f.onHeader,
+			nil,
+			nil,
 		); err != nil {
 			return mkdirRoot()
 		}
@@ -1190,6 +1208,122 @@ func (f *STFS) SymlinkIfPossible(oldname, newname string) error {
 	return f.mknodeWithoutLocking(false, oldname, os.ModePerm, false, newname, false)
 }
 
+// LinkIfPossible creates newname as a hard link to the file oldname
+func (f *STFS) LinkIfPossible(oldname, newname string) error {
+	f.log.Debug("FileSystem.LinkIfPossible", map[string]interface{}{
+		"oldname": oldname,
+		"newname": newname,
+	})
+
+	if f.readOnly {
+		return os.ErrPermission
+	}
+
+	if checkName(oldname) {
+		return os.ErrInvalid
+	}
+
+	if checkName(newname) {
+		return os.ErrInvalid
+	}
+
+	var err error
+	oldname, err = f.resolveCleanName(oldname, true)
+	if err != nil {
+		return err
+	}
+
+	rawNewName := newname
+	newname, err = f.resolveCleanName(newname, true)
+	if err != nil {
+		return err
+	}
+
+	f.ioLock.Lock()
+	defer f.ioLock.Unlock()
+
+	hdr, err := inventory.Stat(
+		f.metadata,
+
+		oldname,
+		false,
+
+		f.onHeader,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return os.ErrNotExist
+		}
+
+		return err
+	}
+
+	// Directories can't be hard linked
+	if hdr.Typeflag == tar.TypeDir {
+		return os.ErrPermission
+	}
+
+	// The PAX records describe how the content of the file has been written, i.e. as an update, so they can't be shared with the link
+	link := *hdr
+	link.PAXRecords = nil
+
+	if _, err := inventory.Stat(
+		f.metadata,
+
+		filepath.Dir(newname),
+		false,
+
+		f.onHeader,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return os.ErrNotExist
+		}
+
+		return err
+	}
+
+	if pathext.IsRoot(rawNewName, true) {
+		return os.ErrExist
+	}
+
+	if _, err := inventory.Stat(
+		f.metadata,
+
+		newname,
+		false,
+
+		f.onHeader,
+	); err == nil {
+		return os.ErrExist
+	}
+
+	done := false
+	if _, err := f.writeOps.Archive(
+		func() (config.FileConfig, error) {
+			// Exit after the first write
+			if done {
+				return config.FileConfig{}, io.EOF
+			}
+			done = true
+
+			return config.FileConfig{
+				GetFile:  nil, // Not required as hard links have no content
+				Info:     link.FileInfo(),
+				Path:     filepath.ToSlash(newname),
+				Link:     filepath.ToSlash(oldname),
+				Hardlink: true,
+			}, nil
+		},
+		f.compressionLevel,
+		false,
+		false,
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (f *STFS) ReadlinkIfPossible(name string) (string, error) {
 	f.log.Debug("FileSystem.ReadlinkIfPossible", map[string]interface{}{
 		"name": name,
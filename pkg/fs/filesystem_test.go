@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -47,6 +48,11 @@ type symFs interface {
 	afero.Symlinker
 }
 
+type linkFs interface {
+	afero.Fs
+	LinkIfPossible(oldname, newname string) error
+}
+
 type stfsConfig struct {
 	recordSize int
 	readOnly   bool
@@ -317,6 +323,7 @@ func createSTFS(
 
 	recordSize int,
 	readOnly bool,
+	asOf int64,
 	verbose bool,
 
 	signature string,
@@ -390,6 +397,9 @@ func createSTFS(
 		func(event *config.HeaderEvent) {
 			jsonLogger.Debug("Header read", event)
 		},
+		nil,
+
+		false,
 	)
 
 	writeOps := operations.NewOperations(
@@ -406,6 +416,9 @@ func createSTFS(
 		func(event *config.HeaderEvent) {
 			jsonLogger.Debug("Header write", event)
 		},
+		nil,
+
+		false,
 	)
 
 	stfs := NewSTFS(
@@ -424,6 +437,7 @@ func createSTFS(
 			)
 		},
 		readOnly,
+		asOf,
 		false,
 
 		func(hdr *config.Header) {
@@ -496,6 +510,7 @@ func createFss(initialize bool, withCache bool, withOsFs bool) ([]fsConfig, erro
 
 			cfg.recordSize,
 			cfg.readOnly,
+			0,
 			verbose,
 
 			cfg.signature,
@@ -936,6 +951,8 @@ func runIntegrationTest(t *testing.T, name string, action func(t *testing.T, tmp
 
 			cfg.fileSystemCacheDuration,
 		}), func(t *testing.T) {
+			cfg := cfg
+
 			tmp, err := os.MkdirTemp(baseTmp, "fs-*")
 			if err != nil {
 				t.Error(err)
@@ -1040,6 +1057,7 @@ func TestSTFS_InitializeEmptyIntegration(t *testing.T) {
 
 				cfg.recordSize,
 				cfg.readOnly,
+				0,
 				verbose,
 
 				cfg.signature,
@@ -5839,6 +5857,167 @@ func TestSTFS_Symlink(t *testing.T) {
 	}
 }
 
+type linkArgs struct {
+	oldname string
+	newname string
+}
+
+var linkTests = []struct {
+	name      string
+	args      linkArgs
+	wantErr   bool
+	prepare   func(linkFs) error
+	check     func(linkFs) error
+	withCache bool
+	withOsFs  bool
+}{
+	{
+		"Can link /test.txt to /new.txt if does exist",
+		linkArgs{"/test.txt", "/new.txt"},
+		false,
+		func(f linkFs) error {
+			return afero.WriteFile(f, "/test.txt", []byte("Hello, world!"), os.ModePerm)
+		},
+		func(f linkFs) error {
+			content, err := afero.ReadFile(f, "/new.txt")
+			if err != nil {
+				return err
+			}
+
+			want := "Hello, world!"
+			got := string(content)
+
+			if want != got {
+				return fmt.Errorf("linked file has wrong content, got %v, want %v", got, want)
+			}
+
+			return nil
+		},
+		false,
+		false,
+	},
+	{
+		"Can link /mydir/test.txt to /new.txt",
+		linkArgs{"/mydir/test.txt", "/new.txt"},
+		false,
+		func(f linkFs) error {
+			if err := f.Mkdir("/mydir", os.ModePerm); err != nil {
+				return err
+			}
+
+			return afero.WriteFile(f, "/mydir/test.txt", []byte("Hello, world!"), os.ModePerm)
+		},
+		func(f linkFs) error {
+			info, err := f.Stat("/new.txt")
+			if err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return fmt.Errorf("linked file is not a regular file, got mode %v", info.Mode())
+			}
+
+			return nil
+		},
+		false,
+		false,
+	},
+	{
+		"Can not link /test.txt to /new.txt if does not exist",
+		linkArgs{"/test.txt", "/new.txt"},
+		true,
+		func(f linkFs) error { return nil },
+		func(f linkFs) error { return nil },
+		false,
+		false,
+	},
+	{
+		"Can not link /mydir to /new",
+		linkArgs{"/mydir", "/new"},
+		true,
+		func(f linkFs) error {
+			return f.Mkdir("/mydir", os.ModePerm)
+		},
+		func(f linkFs) error { return nil },
+		false,
+		false,
+	},
+	{
+		"Can not link /test.txt to /existing.txt",
+		linkArgs{"/test.txt", "/existing.txt"},
+		true,
+		func(f linkFs) error {
+			if _, err := f.Create("/test.txt"); err != nil {
+				return err
+			}
+
+			_, err := f.Create("/existing.txt")
+
+			return err
+		},
+		func(f linkFs) error { return nil },
+		false,
+		false,
+	},
+	{
+		"Can not link /test.txt to /",
+		linkArgs{"/test.txt", "/"},
+		true,
+		func(f linkFs) error {
+			_, err := f.Create("/test.txt")
+
+			return err
+		},
+		func(f linkFs) error { return nil },
+		false,
+		false,
+	},
+	{
+		"Can not link /test.txt to /mydir/new.txt if /mydir does not exist",
+		linkArgs{"/test.txt", "/mydir/new.txt"},
+		true,
+		func(f linkFs) error {
+			_, err := f.Create("/test.txt")
+
+			return err
+		},
+		func(f linkFs) error { return nil },
+		false,
+		false,
+	},
+}
+
+func TestSTFS_Link(t *testing.T) {
+	for _, tt := range linkTests {
+		tt := tt
+
+		runTestForAllFss(t, tt.name, true, tt.withCache, tt.withOsFs, func(t *testing.T, fs fsConfig) {
+			linkFs, ok := fs.fs.(linkFs)
+			if !ok {
+				return
+			}
+
+			if err := tt.prepare(linkFs); err != nil {
+				t.Errorf("%v prepare() error = %v", linkFs.Name(), err)
+
+				return
+			}
+
+			if err := linkFs.LinkIfPossible(tt.args.oldname, tt.args.newname); (err != nil) != tt.wantErr {
+				t.Errorf("%v.LinkIfPossible() error = %v, wantErr %v", linkFs.Name(), err, tt.wantErr)
+
+				return
+			}
+
+			if err := tt.check(linkFs); err != nil {
+				t.Errorf("%v check() error = %v", linkFs.Name(), err)
+
+				return
+			}
+		})
+	}
+}
+
 type readlinkArgs struct {
 	name string
 }
@@ -6001,3 +6180,150 @@ func TestSTFS_Readlink(t *testing.T) {
 		})
 	}
 }
+
+var asOfTests = []struct {
+	name    string
+	asOf    int // Index of the write to serve the snapshot of, or -1 to serve the latest state
+	want    map[string]string
+	wantErr bool // Whether writing to the filesystem fails
+}{
+	{
+		"Can serve snapshot before changes",
+		0,
+		map[string]string{"/a.txt": "old", "/b.txt": "old"},
+		true,
+	},
+	{
+		"Can serve snapshot before deletion",
+		1,
+		map[string]string{"/a.txt": "new", "/b.txt": "old", "/c.txt": "new"},
+		true,
+	},
+	{
+		"Can serve latest state",
+		-1,
+		map[string]string{"/a.txt": "new", "/c.txt": "new"},
+		false,
+	},
+}
+
+func TestSTFS_AsOf(t *testing.T) {
+	for _, tt := range asOfTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+
+			drive := filepath.Join(tmp, "drive.tar")
+			metadata := filepath.Join(tmp, "metadata.sqlite")
+
+			create := func(asOf int64) (afero.Fs, error) {
+				return createSTFS(
+					drive,
+					metadata,
+
+					recordSizes[0],
+					false,
+					asOf,
+					verbose,
+
+					config.NoneKey,
+					nil,
+					nil,
+
+					config.NoneKey,
+					nil,
+					nil,
+
+					config.NoneKey,
+					config.CompressionLevelFastestKey,
+
+					config.WriteCacheTypeMemory,
+					filepath.Join(tmp, "write-cache"),
+
+					config.NoneKey,
+					filepath.Join(tmp, "filesystem-cache"),
+					time.Hour,
+
+					true,
+				)
+			}
+
+			stfs, err := create(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range []string{"/a.txt", "/b.txt"} {
+				if err := afero.WriteFile(stfs, name, []byte("old"), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, name := range []string{"/a.txt", "/c.txt"} {
+				if err := afero.WriteFile(stfs, name, []byte("new"), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := stfs.Remove("/b.txt"); err != nil {
+				t.Fatal(err)
+			}
+
+			metadataPersister := persisters.NewMetadataPersister(metadata)
+			if err := metadataPersister.Open(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The snapshots are taken after the content of the last file of a write has been written
+			sessions := []int64{}
+			for _, name := range []string{"/b.txt", "/c.txt"} {
+				versions, err := metadataPersister.GetHeaderHistory(context.Background(), name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for i := len(versions) - 1; i >= 0; i-- {
+					if versions[i].Header.Deleted != 1 {
+						sessions = append(sessions, versions[i].Session)
+
+						break
+					}
+				}
+			}
+
+			asOf := int64(0)
+			if tt.asOf >= 0 {
+				asOf = sessions[tt.asOf]
+			}
+
+			snapshot, err := create(asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			infos, err := afero.ReadDir(snapshot, "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			for _, info := range infos {
+				name := path.Join("/", info.Name())
+
+				content, err := afero.ReadFile(snapshot, name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got[name] = string(content)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadDir() got = %v, want %v", got, tt.want)
+			}
+
+			if err := afero.WriteFile(snapshot, "/d.txt", []byte("new"), os.ModePerm); (err != nil) != tt.wantErr {
+				t.Errorf("WriteFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
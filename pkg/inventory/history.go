@@ -0,0 +1,44 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+func History(
+	metadata config.MetadataConfig,
+
+	name string,
+
+	onHeaderVersion func(version *config.HeaderVersion),
+) ([]*config.HeaderVersion, error) {
+	name = filepath.ToSlash(name)
+
+	versions, err := metadata.Metadata.GetHeaderHistory(context.Background(), name)
+	if err != nil {
+		return []*config.HeaderVersion{}, err
+	}
+
+	if len(versions) == 0 {
+		versions, err = metadata.Metadata.GetHeaderHistory(context.Background(), strings.TrimSuffix(name, "/")+"/")
+		if err != nil {
+			return []*config.HeaderVersion{}, err
+		}
+	}
+
+	if len(versions) == 0 {
+		return []*config.HeaderVersion{}, sql.ErrNoRows
+	}
+
+	if onHeaderVersion != nil {
+		for _, version := range versions {
+			onHeaderVersion(version)
+		}
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,21 @@
+package inventory
+
+import (
+	"archive/tar"
+
+	"github.com/pojntfx/stfs/internal/records"
+)
+
+// Savings returns the amount of headers which reference already archived content and the amount of bytes they didn't take up
+func Savings(hdrs []*tar.Header) (int, int64) {
+	files := 0
+	bytes := int64(0)
+	for _, hdr := range hdrs {
+		if _, ok := hdr.PAXRecords[records.STFSRecordReferencesRecord]; ok {
+			files++
+			bytes += hdr.Size
+		}
+	}
+
+	return files, bytes
+}
@@ -52,8 +52,18 @@ func Stat(
 		}
 	}
 
-	// Prevent returning broken symlinks as headers
-	if !symlink && dbhdr.Linkname != "" {
+	if dbhdr.Typeflag == tar.TypeLink {
+		// Hard links share the metadata of the file they link to
+		target, err := metadata.Metadata.ResolveHardlink(context.Background(), dbhdr.Linkname)
+		if err != nil {
+			return nil, err
+		}
+
+		resolved := *target
+		resolved.Name = dbhdr.Name
+		dbhdr = &resolved
+	} else if !symlink && dbhdr.Linkname != "" {
+		// Prevent returning broken symlinks as headers
 		return nil, sql.ErrNoRows
 	}
 
@@ -3,21 +3,20 @@ package operations
 import (
 	"archive/tar"
 	"context"
+	"database/sql"
 	"errors"
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pojntfx/stfs/internal/converters"
 	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
 	"github.com/pojntfx/stfs/internal/records"
 	"github.com/pojntfx/stfs/internal/suffix"
-	"github.com/pojntfx/stfs/internal/tarext"
-	"github.com/pojntfx/stfs/pkg/compression"
+	"github.com/pojntfx/stfs/internal/xattrext"
 	"github.com/pojntfx/stfs/pkg/config"
-	"github.com/pojntfx/stfs/pkg/encryption"
-	"github.com/pojntfx/stfs/pkg/recovery"
-	"github.com/pojntfx/stfs/pkg/signature"
 )
 
 var (
@@ -29,280 +28,381 @@ func (o *Operations) Archive(
 	compressionLevel string,
 	overwrite bool,
 	initializing bool,
+) ([]*tar.Header, error) {
+	return o.ArchiveContext(context.Background(), getSrc, compressionLevel, overwrite, initializing)
+}
+
+// ArchiveContext is like Archive, but stops once ctx has been cancelled; the files which have been archived until then are indexed and returned together with the error of ctx
+func (o *Operations) ArchiveContext(
+	ctx context.Context,
+	getSrc func() (config.FileConfig, error),
+	compressionLevel string,
+	overwrite bool,
+	initializing bool,
 ) ([]*tar.Header, error) {
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
 
-	return o.archive(getSrc, compressionLevel, overwrite, initializing)
+	return o.archive(ctx, getSrc, compressionLevel, overwrite, initializing, "")
+}
+
+// ArchiveSession archives the files with session instead of a new one. Files which have already been indexed with session
+// and haven't changed since are skipped, which allows continuing a session which has been interrupted; files which have been cut off are archived again.
+func (o *Operations) ArchiveSession(
+	session string,
+	getSrc func() (config.FileConfig, error),
+	compressionLevel string,
+	overwrite bool,
+) ([]*tar.Header, error) {
+	return o.ArchiveSessionContext(context.Background(), session, getSrc, compressionLevel, overwrite)
+}
+
+// ArchiveSessionContext is like ArchiveSession, but stops once ctx has been cancelled in the same way as ArchiveContext
+func (o *Operations) ArchiveSessionContext(
+	ctx context.Context,
+	session string,
+	getSrc func() (config.FileConfig, error),
+	compressionLevel string,
+	overwrite bool,
+) ([]*tar.Header, error) {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	return o.archive(ctx, getSrc, compressionLevel, overwrite, false, session)
 }
 
 func (o *Operations) archive(
+	ctx context.Context,
 	getSrc func() (config.FileConfig, error),
 	compressionLevel string,
 	overwrite bool,
 	initializing bool,
+	session string, // Skip the files which have already been archived with this session; a new session is used if empty
 ) ([]*tar.Header, error) {
-
-	writer, err := o.backend.GetWriter()
-	if err != nil {
-		return []*tar.Header{}, err
+	onHeader := func(hdr *config.Header) {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeArchive,
+			Indexed: true,
+			Header:  hdr,
+		})
 	}
 
-	dirty := false
-	tw, cleanup, err := tarext.NewTapeWriter(writer.Drive, writer.DriveIsRegular, o.pipes.RecordSize)
+	vw, err := o.newVolumeWriter(ctx, overwrite, initializing, onHeader)
 	if err != nil {
 		return []*tar.Header{}, err
 	}
+	defer vw.discard()
 
-	lastIndexedRecord := int64(0)
-	lastIndexedBlock := int64(0)
-	if !overwrite {
-		lastIndexedRecord, lastIndexedBlock, err = o.metadata.Metadata.GetLastIndexedRecordAndBlock(context.Background(), o.pipes.RecordSize)
-		if err != nil {
-			return []*tar.Header{}, err
+	var payload *ioext.SpoolWriter
+	defer func() {
+		if payload != nil {
+			_ = payload.Close()
 		}
+	}()
+
+	tracker := progress.NewTracker(config.ProgressEventTypeArchive, 0, 0, o.onProgress)
+	defer tracker.Finish()
+
+	// Limit the amount of files which are processed at the same time so that memory stays within the budget
+	workers := o.pipes.Workers
+	if workers < 1 {
+		workers = 1
 	}
 
-	hdrs := []*tar.Header{}
-	for {
-		file, err := getSrc()
-		if err == io.EOF {
-			break
-		}
+	inFlight := 1
+	if workers > 1 {
+		inFlight = workers * 2
+	}
 
-		if err != nil {
-			return []*tar.Header{}, err
-		}
+	memoryLimit := payloadSpoolMemoryLimit
+	if o.pipes.MemoryBudget > 0 {
+		memoryLimit = int(o.pipes.MemoryBudget / int64(inFlight))
+	}
 
-		hdr, err := tar.FileInfoHeader(file.Info, file.Link)
-		if err != nil {
-			// Skip sockets
-			if strings.Contains(err.Error(), errSocketsNotSupported.Error()) {
-				continue
+	// Compress, encrypt and sign the upcoming files while the previous ones are being written
+	isRegular := vw.isRegular
+	encodeCtx, cancel := context.WithCancel(ctx)
+	jobs := make(chan *archiveJob, inFlight)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				job.payload, job.err = o.encodeFile(encodeCtx, job.hdr, job.file, compressionLevel, isRegular, memoryLimit, tracker)
+
+				close(job.done)
 			}
+		}()
+	}
 
-			return []*tar.Header{}, err
+	queue := []*archiveJob{}
+	defer func() {
+		cancel()
+		close(jobs)
+		wg.Wait()
+
+		for _, job := range queue {
+			if job.payload != nil {
+				_ = job.payload.Close()
+			}
 		}
+	}()
 
-		hdr.Name = file.Path
-		hdr.Format = tar.FormatPAX
+	resume := session != ""
+	if !resume {
+		session = NewSession()
+	}
+
+	// Content which is stored more than once in this run references the first header with it
+	hashes := map[string]*tar.Header{}
 
-		var f io.ReadSeekCloser
-		if file.Info.Mode().IsRegular() && file.Info.Size() > 0 {
-			// Get the compressed size for the header
-			fileSizeCounter := &ioext.CounterWriter{
-				Writer: io.Discard,
+	hardlinks := map[inode]string{}
+	hdrs := []*tar.Header{}
+	eof := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		// Queue the next files; headers are prepared in order so that hard links always point to earlier files
+		for !eof && len(queue) < inFlight {
+			file, err := getSrc()
+			if err == io.EOF {
+				eof = true
+
+				break
 			}
 
-			encryptor, err := encryption.Encrypt(fileSizeCounter, o.pipes.Encryption, o.crypto.Recipient)
 			if err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 
-			compressor, err := compression.Compress(
-				encryptor,
-				o.pipes.Compression,
-				compressionLevel,
-				writer.DriveIsRegular,
-				o.pipes.RecordSize,
-			)
+			hdr, err := tar.FileInfoHeader(file.Info, file.Link)
 			if err != nil {
-				return []*tar.Header{}, err
+				// Skip sockets
+				if strings.Contains(err.Error(), errSocketsNotSupported.Error()) {
+					continue
+				}
+
+				return hdrs, vw.abort(err)
 			}
 
-			f, err = file.GetFile()
-			if err != nil {
-				return []*tar.Header{}, err
+			// FileInfoHeader doesn't copy the device numbers of headers, i.e. of imported tar archives
+			if src, ok := file.Info.Sys().(*tar.Header); ok {
+				hdr.Devmajor = src.Devmajor
+				hdr.Devminor = src.Devminor
 			}
 
-			signer, sign, err := signature.Sign(f, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity)
-			if err != nil {
-				return []*tar.Header{}, err
+			hdr.Name = file.Path
+			hdr.Format = tar.FormatPAX
+			if hdr.PAXRecords == nil {
+				hdr.PAXRecords = map[string]string{}
+			}
+			hdr.PAXRecords[records.STFSRecordSession] = session
+			xattrext.AddToPAXRecords(hdr.PAXRecords, file.Xattrs)
+
+			// Store repeated occurrences of the same inode as hard links to the first one
+			if file.Hardlink {
+				hdr.Typeflag = tar.TypeLink
+				hdr.Linkname = file.Link
+				hdr.Size = 0
+			} else if id, ok := getInode(file.Info); ok {
+				if linkname, ok := hardlinks[id]; ok {
+					hdr.Typeflag = tar.TypeLink
+					hdr.Linkname = linkname
+					hdr.Size = 0
+				} else {
+					hardlinks[id] = file.Path
+				}
 			}
 
-			if writer.DriveIsRegular {
-				if _, err := io.Copy(compressor, signer); err != nil {
-					return []*tar.Header{}, err
+			if resume {
+				archived, err := o.isArchived(ctx, hdr, session)
+				if err != nil {
+					return hdrs, vw.abort(err)
 				}
-			} else {
-				buf := make([]byte, config.MagneticTapeBlockSize*o.pipes.RecordSize)
-				if _, err := io.CopyBuffer(compressor, signer, buf); err != nil {
-					return []*tar.Header{}, err
+
+				if archived {
+					tracker.Done()
+
+					continue
 				}
 			}
 
-			if err := compressor.Flush(); err != nil {
-				return []*tar.Header{}, err
+			job := &archiveJob{
+				file:   file,
+				hdr:    hdr,
+				encode: hdr.Typeflag != tar.TypeLink && file.Info.Mode().IsRegular() && (file.Info.Size() > 0 || file.Stream),
+				done:   make(chan struct{}),
 			}
 
-			if err := compressor.Close(); err != nil {
-				return []*tar.Header{}, err
+			if job.encode {
+				jobs <- job
+			} else {
+				close(job.done)
 			}
 
-			if err := encryptor.Close(); err != nil {
-				return []*tar.Header{}, err
+			queue = append(queue, job)
+		}
+
+		if len(queue) == 0 {
+			break
+		}
+
+		// The files are written in order, so the first one in the queue is the one which is being written now
+		job := queue[0]
+		tracker.Start(job.file.Path)
+
+		<-job.done
+		queue = queue[1:]
+
+		hdr := job.hdr
+		payload = job.payload
+		if job.err != nil {
+			if ctx.Err() != nil {
+				return hdrs, vw.abort(ctx.Err())
 			}
 
-			if hdr.PAXRecords == nil {
-				hdr.PAXRecords = map[string]string{}
+			return hdrs, vw.abort(job.err)
+		}
+
+		if job.encode {
+			// Reference identical content which has already been archived; the index is outdated when overwriting
+			if !overwrite {
+				payload, err = o.deduplicatePayload(hdr, payload)
+				if err != nil {
+					return hdrs, vw.abort(err)
+				}
 			}
-			hdr.PAXRecords[records.STFSRecordUncompressedSize] = strconv.Itoa(int(hdr.Size))
-			signature, err := sign()
-			if err != nil {
-				return []*tar.Header{}, err
+
+			if payload != nil {
+				hash := hdr.PAXRecords[records.STFSRecordHash]
+				if original, ok := hashes[hash]; !ok {
+					hashes[hash] = hdr
+				} else if position, ok := vw.position(original); ok {
+					setReference(hdr, strconv.FormatInt(position.volume, 10), strconv.FormatInt(position.record, 10), strconv.FormatInt(position.block, 10))
+
+					err = payload.Close()
+					payload = nil
+					if err != nil {
+						return hdrs, vw.abort(err)
+					}
+				}
 			}
 
-			if signature != "" {
-				hdr.PAXRecords[records.STFSRecordSignature] = signature
+			if payload != nil {
+				tracker.AddWritten(payload.Size())
 			}
-			hdr.Size = int64(fileSizeCounter.BytesRead)
 
 			hdr.Name, err = suffix.AddSuffix(hdr.Name, o.pipes.Compression, o.pipes.Encryption)
 			if err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 		}
 
-		if o.onHeader != nil {
-			dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
-			if err != nil {
-				return []*tar.Header{}, err
-			}
-
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeArchive,
-				Indexed: false,
-				Header:  converters.DBHeaderToConfigHeader(dbhdr),
-			})
+		if err := o.emitArchiveHeader(hdr); err != nil {
+			return hdrs, vw.abort(err)
 		}
 
 		hdrToAppend := *hdr
 		hdrs = append(hdrs, &hdrToAppend)
 
-		if err := signature.SignHeader(hdr, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity); err != nil {
+		// The volume writer takes ownership of the payload
+		err = vw.write(hdr, payload)
+		payload = nil
+		if err != nil {
+			// Nothing more can be written, so the journal is kept for the next write to index what has reached the tape or tar file
 			return []*tar.Header{}, err
 		}
 
-		if err := encryption.EncryptHeader(hdr, o.pipes.Encryption, o.crypto.Recipient); err != nil {
-			return []*tar.Header{}, err
-		}
+		tracker.Done()
+	}
 
-		if err := tw.WriteHeader(hdr); err != nil {
-			return []*tar.Header{}, err
-		}
+	return hdrs, vw.close()
+}
 
-		dirty = true
+func (o *Operations) emitArchiveHeader(hdr *tar.Header) error {
+	if o.onHeader == nil {
+		return nil
+	}
 
-		if !file.Info.Mode().IsRegular() || file.Info.Size() <= 0 {
-			if f != nil {
-				if err := f.Close(); err != nil {
-					return []*tar.Header{}, err
-				}
-			}
+	dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+	if err != nil {
+		return err
+	}
 
-			continue
-		}
+	o.onHeader(&config.HeaderEvent{
+		Type:    config.HeaderEventTypeArchive,
+		Indexed: false,
+		Header:  converters.DBHeaderToConfigHeader(dbhdr),
+	})
 
-		// Compress and write the file
-		encryptor, err := encryption.Encrypt(tw, o.pipes.Encryption, o.crypto.Recipient)
-		if err != nil {
-			return []*tar.Header{}, err
-		}
+	return nil
+}
 
-		compressor, err := compression.Compress(
-			encryptor,
-			o.pipes.Compression,
-			compressionLevel,
-			writer.DriveIsRegular,
-			o.pipes.RecordSize,
-		)
-		if err != nil {
-			return []*tar.Header{}, err
-		}
+// archiveJob is a file whose payload is compressed, encrypted and signed by a worker while the files before it are being written
+type archiveJob struct {
+	file   config.FileConfig
+	hdr    *tar.Header
+	encode bool
 
-		if _, err := f.Seek(0, io.SeekStart); err != nil {
-			return []*tar.Header{}, err
-		}
+	payload *ioext.SpoolWriter
+	err     error
+	done    chan struct{}
+}
 
-		if writer.DriveIsRegular {
-			if _, err := io.Copy(compressor, f); err != nil {
-				return []*tar.Header{}, err
-			}
-		} else {
-			buf := make([]byte, config.MagneticTapeBlockSize*o.pipes.RecordSize)
-			if _, err := io.CopyBuffer(compressor, f, buf); err != nil {
-				return []*tar.Header{}, err
-			}
-		}
+func (o *Operations) encodeFile(
+	ctx context.Context,
+	hdr *tar.Header,
+	file config.FileConfig,
+	compressionLevel string,
+	isRegular bool,
+	memoryLimit int,
+	tracker *progress.Tracker,
+) (*ioext.SpoolWriter, error) {
+	f, err := openFile(file)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := compressor.Flush(); err != nil {
-			return []*tar.Header{}, err
-		}
+	payload, err := o.encodePayload(ctx, hdr, f, compressionLevel, isRegular, memoryLimit, tracker)
+	if err != nil {
+		_ = f.Close()
 
-		if err := compressor.Close(); err != nil {
-			return []*tar.Header{}, err
-		}
+		return nil, err
+	}
 
-		if err := encryptor.Close(); err != nil {
-			return []*tar.Header{}, err
-		}
+	if err := f.Close(); err != nil {
+		_ = payload.Close()
 
-		if err := f.Close(); err != nil {
-			return []*tar.Header{}, err
-		}
+		return nil, err
 	}
 
-	if err := cleanup(&dirty); err != nil {
-		return []*tar.Header{}, err
-	}
+	return payload, nil
+}
 
-	index := 1 // Ignore the first header, which is the last header which we already indexed
-	if overwrite {
-		index = 0 // If we are starting fresh, index from start
-	}
+// isArchived returns whether hdr has already been archived and indexed with session and hasn't changed since
+func (o *Operations) isArchived(ctx context.Context, hdr *tar.Header, session string) (bool, error) {
+	dbhdr, err := o.metadata.Metadata.GetHeader(ctx, hdr.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
 
-	if err := o.backend.CloseWriter(); err != nil {
-		return []*tar.Header{}, err
+		return false, err
 	}
 
-	reader, err := o.backend.GetReader()
+	archivedSession, err := getSession(dbhdr)
 	if err != nil {
-		return []*tar.Header{}, err
+		return false, err
 	}
-	defer o.backend.CloseReader()
-
-	return hdrs, recovery.Index(
-		reader,
-		o.backend.MagneticTapeIO,
-		o.metadata,
-		o.pipes,
-		o.crypto,
-
-		int(lastIndexedRecord),
-		int(lastIndexedBlock),
-		overwrite,
-		initializing,
-		index,
-
-		func(hdr *tar.Header, i int) error {
-			if len(hdrs) <= i {
-				return config.ErrTarHeaderMissing
-			}
 
-			*hdr = *hdrs[i]
-
-			return nil
-		},
-		func(hdr *tar.Header, isRegular bool) error {
-			return nil // We sign above, no need to verify
-		},
-
-		func(hdr *config.Header) {
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeArchive,
-				Indexed: true,
-				Header:  hdr,
-			})
-		},
-	)
+	return strconv.FormatInt(archivedSession, 10) == session &&
+		dbhdr.Typeflag == int64(hdr.Typeflag) &&
+		dbhdr.Linkname == hdr.Linkname &&
+		dbhdr.Size == hdr.Size &&
+		isModTimeEqual(dbhdr.Modtime, hdr.ModTime), nil
 }
@@ -0,0 +1,689 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/suffix"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var archiveDeduplicateTests = []struct {
+	name       string
+	pipes      config.PipeConfig
+	runs       []map[string]string
+	wantStored int
+}{
+	{
+		"Can archive distinct files",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": "b"},
+		},
+		2,
+	},
+	{
+		"Can deduplicate identical files in one run",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "same", "b.txt": "same", "c.txt": "same", "d.txt": "other"},
+		},
+		2,
+	},
+	{
+		"Can deduplicate identical compressed files in one run",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "same", "b.txt": "same", "c.txt": "other", "d.txt": "other"},
+		},
+		2,
+	},
+	{
+		"Can deduplicate identical files after files which don't fill their last block",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30001), "b.txt": getTestContent(0, 30001), "c.txt": getTestContent(1, 777), "d.txt": getTestContent(1, 777)},
+		},
+		2,
+	},
+	{
+		"Can deduplicate identical files in one run with parity",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2},
+		[]map[string]string{
+			{"a.txt": "other"},
+			{"b.txt": getTestContent(0, 30001), "c.txt": getTestContent(0, 30001)},
+		},
+		2,
+	},
+	{
+		"Can deduplicate identical files in an appended run",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30001)},
+			{"b.txt": "same", "c.txt": "same"},
+		},
+		2,
+	},
+	{
+		"Can deduplicate files of a previous run",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "same"},
+			{"b.txt": "same", "c.txt": "same"},
+		},
+		1,
+	},
+}
+
+func TestOperations_ArchiveDeduplicate(t *testing.T) {
+	for _, tt := range archiveDeduplicateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]string{}
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				hdrs, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false)
+				if err != nil {
+					t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				// Deduplicated files are archived in the order of the source
+				names := []string{}
+				for _, hdr := range hdrs {
+					names = append(names, hdr.Name)
+				}
+
+				if !sort.StringsAreSorted(names) {
+					t.Errorf("Archive() got headers in order %v, want them sorted", names)
+				}
+
+				for name, content := range files {
+					want[name] = content
+				}
+			}
+
+			dbhdrs, err := to.ops.metadata.Metadata.GetHeaders(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored := 0
+			positions := map[string]string{}
+			references := map[string]string{}
+			for _, dbhdr := range dbhdrs {
+				hdr, err := getCompactHeader(dbhdr)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if hdr.Typeflag != tar.TypeReg {
+					continue
+				}
+
+				if record, ok := hdr.PAXRecords[records.STFSRecordReferencesRecord]; ok {
+					references[hdr.Name] = hdr.PAXRecords[records.STFSRecordReferencesVolume] + "/" + record + "/" + hdr.PAXRecords[records.STFSRecordReferencesBlock]
+				} else {
+					stored++
+					positions[fmt.Sprintf("%v/%v/%v", dbhdr.Volume, dbhdr.Record, dbhdr.Block)] = hdr.Name
+				}
+			}
+
+			// References point to the position at which the index found the content
+			for name, reference := range references {
+				if _, ok := positions[reference]; !ok {
+					t.Errorf("Archive() got reference of %v to %v, want one of %v", name, reference, positions)
+				}
+			}
+
+			if stored != tt.wantStored {
+				t.Errorf("Archive() stored = %v, want %v", stored, tt.wantStored)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+var archiveSessionTests = []struct {
+	name   string
+	change func(src string) error
+	want   []string
+}{
+	{
+		"Can skip archived files",
+		func(src string) error {
+			return nil
+		},
+		[]string{},
+	},
+	{
+		"Can archive new file",
+		func(src string) error {
+			return writeTestFiles(src, map[string]string{"c.txt": "c"})
+		},
+		[]string{"c.txt"},
+	},
+	{
+		"Can archive file with changed size",
+		func(src string) error {
+			return writeTestFiles(src, map[string]string{"b.txt": "bb"})
+		},
+		[]string{"b.txt"},
+	},
+	{
+		"Can archive file which changed within the same second",
+		func(src string) error {
+			modTime := archivedModTime.Add(time.Millisecond)
+
+			return os.Chtimes(filepath.Join(src, "b.txt"), modTime, modTime)
+		},
+		[]string{"b.txt"},
+	},
+}
+
+func TestOperations_ArchiveSession(t *testing.T) {
+	for _, tt := range archiveSessionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "a", "b.txt": "b"}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range []string{"a.txt", "b.txt"} {
+				if err := os.Chtimes(filepath.Join(src, name), archivedModTime, archivedModTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			session := NewSession()
+			if _, err := to.ops.ArchiveSession(session, newTestSource(src), config.CompressionLevelFastestKey, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.change(src); err != nil {
+				t.Fatal(err)
+			}
+
+			hdrs, err := to.ops.ArchiveSession(session, newTestSource(src), config.CompressionLevelFastestKey, false)
+			if err != nil {
+				t.Errorf("ArchiveSession() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got := []string{}
+			for _, hdr := range hdrs {
+				got = append(got, hdr.Name)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ArchiveSession() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var archiveStreamTests = []struct {
+	name  string
+	pipes config.PipeConfig
+	size  int
+}{
+	{
+		"Can archive empty stream",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		0,
+	},
+	{
+		"Can archive small stream",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		5,
+	},
+	{
+		"Can archive stream larger than a record",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		3*recordSize*config.MagneticTapeBlockSize + 7,
+	},
+	{
+		"Can archive compressed stream",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		3*recordSize*config.MagneticTapeBlockSize + 7,
+	},
+	{
+		"Can archive stream with parity",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2},
+		3*recordSize*config.MagneticTapeBlockSize + 7,
+	},
+}
+
+func TestOperations_ArchiveStream(t *testing.T) {
+	for _, tt := range archiveStreamTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content := getTestContent(1, tt.size)
+
+			read := false
+			if _, err := to.ops.Archive(func() (config.FileConfig, error) {
+				if read {
+					return config.FileConfig{}, io.EOF
+				}
+				read = true
+
+				return NewStreamFileConfig("a.txt", 0640, archivedModTime, func() (io.ReadCloser, error) {
+					// A pipe can't be seeked and has no known size, like stdin
+					r, w := io.Pipe()
+					go func() {
+						_, err := io.Copy(w, strings.NewReader(content))
+						_ = w.CloseWithError(err)
+					}()
+
+					return r, nil
+				}), nil
+			}, config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			dbhdr, err := to.ops.metadata.Metadata.GetHeader(context.Background(), "a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dbhdr.Size != int64(tt.size) {
+				t.Errorf("Archive() indexed size = %v, want %v", dbhdr.Size, tt.size)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			got, err := restoreTestFiles(to.ops, dst, map[string]string{"a.txt": content})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got["a.txt"] != content {
+				t.Errorf("Restore() got %v bytes, want %v bytes", len(got["a.txt"]), len(content))
+			}
+
+			info, err := os.Stat(filepath.Join(dst, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("Restore() mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+			}
+
+			if !info.ModTime().Equal(archivedModTime) {
+				t.Errorf("Restore() modification time = %v, want %v", info.ModTime(), archivedModTime)
+			}
+		})
+	}
+}
+
+var archiveProgressTests = []struct {
+	name    string
+	workers int
+}{
+	{
+		"Can report file which is being written with one worker",
+		1,
+	},
+	{
+		"Can report file which is being written with multiple workers",
+		4,
+	},
+}
+
+// delayedReader waits before the first read, like a slow disk
+type delayedReader struct {
+	io.Reader
+
+	delay   time.Duration
+	delayed bool
+}
+
+func (r *delayedReader) Read(p []byte) (int, error) {
+	if !r.delayed {
+		time.Sleep(r.delay)
+
+		r.delayed = true
+	}
+
+	return r.Reader.Read(p)
+}
+
+func TestOperations_ArchiveProgress(t *testing.T) {
+	names := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt", "f.txt"}
+
+	for _, tt := range archiveProgressTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			var lock sync.Mutex
+			written := -1 // Index of the last file which has been written
+			wrong := []string{}
+			var last config.ProgressEvent
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, Workers: tt.workers}, func(event *config.ProgressEvent) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				last = *event
+				if event.Name == "" {
+					return
+				}
+
+				// The file which is being written follows the last written one; events of the workers can be reported after the next file has been written
+				if i := indexOf(names, event.Name); i < written-1 || i > written+1 {
+					wrong = append(wrong, fmt.Sprintf("%v after %v", event.Name, written))
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			to.ops.onHeader = func(event *config.HeaderEvent) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				if !event.Indexed {
+					written = indexOf(names, event.Header.Name)
+				}
+			}
+
+			i := 0
+			if _, err := to.ops.Archive(func() (config.FileConfig, error) {
+				if i >= len(names) {
+					return config.FileConfig{}, io.EOF
+				}
+
+				name := names[i]
+				i++
+
+				return NewStreamFileConfig(name, 0600, archivedModTime, func() (io.ReadCloser, error) {
+					return io.NopCloser(&delayedReader{Reader: strings.NewReader(name), delay: 150 * time.Millisecond}), nil
+				}), nil
+			}, config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if len(wrong) > 0 {
+				t.Errorf("Archive() reported files which aren't being written: %v", wrong)
+			}
+
+			if last.FilesDone != int64(len(names)) || last.Name != "" {
+				t.Errorf("Archive() last progress event = %v files done with name %v, want %v files done without name", last.FilesDone, last.Name, len(names))
+			}
+		})
+	}
+}
+
+func indexOf(values []string, value string) int {
+	for i, candidate := range values {
+		if candidate == value {
+			return i
+		}
+	}
+
+	return -1
+}
+
+var archiveWorkersTests = []struct {
+	name        string
+	compression string
+	workers     int
+}{
+	{
+		"Can archive files one after another",
+		config.NoneKey,
+		1,
+	},
+	{
+		"Can archive files concurrently",
+		config.NoneKey,
+		4,
+	},
+	{
+		"Can archive compressed files concurrently",
+		config.CompressionFormatZStandardKey,
+		4,
+	},
+	{
+		"Can archive compressed files with more workers than files",
+		config.CompressionFormatGZipKey,
+		32,
+	},
+}
+
+func TestOperations_ArchiveWorkers(t *testing.T) {
+	for _, tt := range archiveWorkersTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// Files of different sizes take different times to encode, so that later files could be done first
+			files := map[string]string{}
+			names := []string{}
+			for i := 0; i < 12; i++ {
+				name := fmt.Sprintf("%02d.txt", i)
+
+				files[name] = getTestContent(i, (12-i)*3000)
+				names = append(names, name)
+			}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, files); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: tt.compression, Encryption: config.NoneKey, Signature: config.NoneKey, Workers: tt.workers}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hdrs, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false)
+			if err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			// The files are written in the order of the source
+			archived := []string{}
+			for _, hdr := range hdrs {
+				name, err := suffix.RemoveSuffix(hdr.Name, tt.compression, config.NoneKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				archived = append(archived, name)
+			}
+
+			if !reflect.DeepEqual(archived, names) {
+				t.Errorf("Archive() headers = %v, want %v", archived, names)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), files)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, files) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(files))
+			}
+		})
+	}
+}
+
+var archiveSinglePassTests = []struct {
+	name         string
+	compression  string
+	memoryBudget int64
+}{
+	{
+		"Can archive uncompressed files in a single pass",
+		config.NoneKey,
+		0,
+	},
+	{
+		"Can archive compressed files in a single pass",
+		config.CompressionFormatGZipKey,
+		0,
+	},
+	{
+		"Can archive compressed files which are spooled to disk in a single pass",
+		config.CompressionFormatZStandardKey,
+		1,
+	},
+}
+
+// countingFile counts the bytes which are read from a file
+type countingFile struct {
+	io.ReadSeekCloser
+
+	read *int
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.ReadSeekCloser.Read(p)
+	*f.read += n
+
+	return n, err
+}
+
+func TestOperations_ArchiveSinglePass(t *testing.T) {
+	for _, tt := range archiveSinglePassTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			files := map[string]string{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, files); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: tt.compression, Encryption: config.NoneKey, Signature: config.NoneKey, MemoryBudget: tt.memoryBudget}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lock sync.Mutex
+			opens := map[string]int{}
+			reads := map[string]*int{}
+
+			getSrc := newTestSource(src)
+			if _, err := to.ops.Archive(func() (config.FileConfig, error) {
+				file, err := getSrc()
+				if err != nil || file.GetFile == nil {
+					return file, err
+				}
+
+				getFile := file.GetFile
+				file.GetFile = func() (io.ReadSeekCloser, error) {
+					lock.Lock()
+					defer lock.Unlock()
+
+					opens[file.Path]++
+					if _, ok := reads[file.Path]; !ok {
+						reads[file.Path] = new(int)
+					}
+
+					f, err := getFile()
+					if err != nil {
+						return nil, err
+					}
+
+					return &countingFile{f, reads[file.Path]}, nil
+				}
+
+				return file, nil
+			}, config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			// The content is compressed, hashed and spooled while it is read the first time
+			for name, content := range files {
+				if opens[name] != 1 {
+					t.Errorf("Archive() opened %v %v times, want %v", name, opens[name], 1)
+				}
+
+				read := 0
+				if reads[name] != nil {
+					read = *reads[name]
+				}
+
+				if read != len(content) {
+					t.Errorf("Archive() read %v bytes of %v, want %v", read, name, len(content))
+				}
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), files)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, files) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(files))
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/suffix"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/recovery"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+// Compact copies all files which haven't been deleted or replaced to the tape or tar file of dst, which is overwritten, and indexes them there.
+// The content is re-encoded with the pipes of dst, which allows changing the compression, encryption or signature;
+// the amount of bytes which have been reclaimed is returned.
+func (o *Operations) Compact(dst *Operations, compressionLevel string) (int64, error) {
+	return o.CompactContext(context.Background(), dst, compressionLevel)
+}
+
+// CompactContext is like Compact, but stops once ctx has been cancelled; the files which have been copied until then are indexed in dst
+func (o *Operations) CompactContext(ctx context.Context, dst *Operations, compressionLevel string) (int64, error) {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	dst.diskOperationLock.Lock()
+	defer dst.diskOperationLock.Unlock()
+
+	dbhdrs, err := o.metadata.Metadata.GetHeaders(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	// Read the files in the order in which they are on the tape or tar file
+	sort.Slice(dbhdrs, func(i, j int) bool {
+		if dbhdrs[i].Volume != dbhdrs[j].Volume {
+			return dbhdrs[i].Volume < dbhdrs[j].Volume
+		}
+
+		if dbhdrs[i].Record != dbhdrs[j].Record {
+			return dbhdrs[i].Record < dbhdrs[j].Record
+		}
+
+		return dbhdrs[i].Block < dbhdrs[j].Block
+	})
+
+	lastVolume, err := o.metadata.Metadata.GetLastIndexedVolume(ctx)
+	if err != nil {
+		return -1, err
+	}
+
+	vr, err := o.newVolumeReader()
+	if err != nil {
+		return -1, err
+	}
+	defer vr.close()
+
+	onHeader := func(hdr *config.Header) {
+		if dst.onHeader != nil {
+			dst.onHeader(&config.HeaderEvent{
+				Type:    config.HeaderEventTypeCompact,
+				Indexed: true,
+				Header:  hdr,
+			})
+		}
+	}
+
+	vw, err := dst.newVolumeWriter(ctx, true, false, onHeader)
+	if err != nil {
+		return -1, err
+	}
+	defer vw.discard()
+
+	// Content which is stored more than once is written after the first pass, once it has been indexed and can be referenced
+	read := int64(0)
+	hashes := map[string]struct{}{}
+	duplicates := []*compactEntry{}
+	linked := map[string]string{} // Hard links which have been given the content of their deleted target, by the name of the target
+	i := 0
+	for volume := 0; volume <= int(lastVolume); volume++ {
+		reader, err := vr.seek(volume)
+		if err != nil {
+			return -1, vw.abort(err)
+		}
+
+		size, err := getVolumeSize(reader, o.backend.MagneticTapeIO, o.pipes.RecordSize)
+		if err != nil {
+			return -1, vw.abort(err)
+		}
+		read += size
+
+		for ; i < len(dbhdrs) && dbhdrs[i].Volume <= int64(volume); i++ {
+			if err := ctx.Err(); err != nil {
+				return -1, vw.abort(err)
+			}
+
+			entry := &compactEntry{
+				hdr:     dbhdrs[i],
+				content: dbhdrs[i],
+			}
+
+			// The content of deleted files isn't copied, so hard links to them get it instead
+			if entry.hdr.Typeflag == tar.TypeLink {
+				target, err := o.metadata.Metadata.ResolveHardlink(ctx, entry.hdr.Linkname)
+				if err != nil {
+					return -1, vw.abort(err)
+				}
+
+				if target.Deleted == 1 {
+					if name, ok := linked[target.Name]; ok {
+						link := *entry.hdr
+						link.Linkname = name
+
+						entry.hdr = &link
+						entry.content = &link
+					} else {
+						linked[target.Name] = entry.hdr.Name
+
+						entry.content = target
+					}
+				}
+			}
+
+			hash := ""
+			if entry.content.Typeflag == tar.TypeReg && entry.content.Size > 0 {
+				hdr, err := getCompactHeader(entry.content)
+				if err != nil {
+					return -1, vw.abort(err)
+				}
+
+				hash = hdr.PAXRecords[records.STFSRecordHash]
+			}
+
+			if hash != "" {
+				if _, ok := hashes[hash]; ok {
+					duplicates = append(duplicates, entry)
+
+					continue
+				}
+
+				hashes[hash] = struct{}{}
+			}
+
+			if err := o.compactHeader(ctx, vr, dst, vw, entry, compressionLevel, false); err != nil {
+				if ctx.Err() != nil {
+					return -1, vw.abort(ctx.Err())
+				}
+
+				return -1, vw.abort(err)
+			}
+		}
+	}
+
+	if err := vw.close(); err != nil {
+		return -1, err
+	}
+	written := vw.written
+
+	if len(duplicates) > 0 {
+		vw, err := dst.newVolumeWriter(ctx, false, false, onHeader)
+		if err != nil {
+			return -1, err
+		}
+		defer vw.discard()
+
+		for _, entry := range duplicates {
+			if err := ctx.Err(); err != nil {
+				return -1, vw.abort(err)
+			}
+
+			if err := o.compactHeader(ctx, vr, dst, vw, entry, compressionLevel, true); err != nil {
+				if ctx.Err() != nil {
+					return -1, vw.abort(ctx.Err())
+				}
+
+				return -1, vw.abort(err)
+			}
+		}
+
+		if err := vw.close(); err != nil {
+			return -1, err
+		}
+		written += vw.written
+	}
+
+	return read - written, nil
+}
+
+// compactEntry is a header to copy and the header whose content it gets, which differs for hard links whose target has been deleted
+type compactEntry struct {
+	hdr     *config.Header
+	content *config.Header
+}
+
+func (o *Operations) compactHeader(
+	ctx context.Context,
+	vr *volumeReader,
+	dst *Operations,
+	vw *volumeWriter,
+
+	entry *compactEntry,
+	compressionLevel string,
+	deduplicate bool,
+) error {
+	dbhdr := entry.hdr
+
+	hdr, err := getCompactHeader(dbhdr)
+	if err != nil {
+		return err
+	}
+
+	if entry.content != entry.hdr {
+		content, err := getCompactHeader(entry.content)
+		if err != nil {
+			return err
+		}
+
+		hdr.Typeflag = tar.TypeReg
+		hdr.Linkname = ""
+		hdr.Size = content.Size
+		hdr.PAXRecords[records.STFSRecordHash] = content.PAXRecords[records.STFSRecordHash]
+	}
+
+	hash := hdr.PAXRecords[records.STFSRecordHash]
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, records.STFSPrefix) && key != records.STFSRecordSession {
+			delete(hdr.PAXRecords, key)
+		}
+	}
+
+	if hdr.Typeflag != tar.TypeReg || hdr.Size <= 0 {
+		hdr.Size = 0
+
+		if dst.onHeader != nil {
+			dst.onHeader(&config.HeaderEvent{
+				Type:    config.HeaderEventTypeCompact,
+				Indexed: false,
+				Header:  dbhdr,
+			})
+		}
+
+		return vw.write(hdr, nil)
+	}
+
+	var payload *ioext.SpoolWriter
+	referenced := false
+	if deduplicate {
+		hdr.PAXRecords[records.STFSRecordHash] = hash
+		hdr.PAXRecords[records.STFSRecordUncompressedSize] = strconv.FormatInt(hdr.Size, 10)
+
+		if referenced, err = dst.referenceContent(hdr); err != nil {
+			return err
+		}
+	}
+
+	if !referenced {
+		if payload, err = o.compactPayload(ctx, vr, dst, hdr, entry.content, compressionLevel, vw.isRegular); err != nil {
+			return err
+		}
+	}
+
+	if hdr.Name, err = suffix.AddSuffix(hdr.Name, dst.pipes.Compression, dst.pipes.Encryption); err != nil {
+		if payload != nil {
+			_ = payload.Close()
+		}
+
+		return err
+	}
+
+	if dst.onHeader != nil {
+		dst.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeCompact,
+			Indexed: false,
+			Header:  dbhdr,
+		})
+	}
+
+	// The volume writer takes ownership of the payload
+	return vw.write(hdr, payload)
+}
+
+// compactPayload fetches the plaintext content of dbhdr into a temporary file, which preserves sparse files, and encodes it with the pipes of dst
+func (o *Operations) compactPayload(
+	ctx context.Context,
+	vr *volumeReader,
+	dst *Operations,
+
+	hdr *tar.Header,
+	dbhdr *config.Header,
+	compressionLevel string,
+	isRegular bool,
+) (*ioext.SpoolWriter, error) {
+	tmp, err := os.CreateTemp(os.TempDir(), "stfs-compact-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	reader, err := vr.seek(int(dbhdr.Volume))
+	if err != nil {
+		_ = tmp.Close()
+
+		return nil, err
+	}
+
+	if err := recovery.FetchContext(
+		ctx,
+		reader,
+		o.backend.MagneticTapeIO,
+		vr.changeVolume,
+		o.pipes,
+		o.crypto,
+
+		sinks.NewWriterSink(tmp), // Closes tmp once the content has been written
+
+		int(dbhdr.Record),
+		int(dbhdr.Block),
+		tmp.Name(),
+		false,
+		config.ConflictPolicyOverwrite,
+
+		nil,
+		nil,
+		nil,
+		nil,
+	); err != nil {
+		_ = tmp.Close()
+
+		return nil, err
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return dst.encodePayload(ctx, hdr, f, compressionLevel, isRegular, payloadSpoolMemoryLimit, nil)
+}
+
+func getCompactHeader(dbhdr *config.Header) (*tar.Header, error) {
+	hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+	if err != nil {
+		return nil, err
+	}
+
+	hdr.Format = tar.FormatPAX
+	if hdr.PAXRecords == nil {
+		hdr.PAXRecords = map[string]string{}
+	}
+
+	return hdr, nil
+}
+
+// getVolumeSize returns the amount of bytes which are stored on the volume
+func getVolumeSize(reader config.DriveReaderConfig, mt config.MagneticTapeIO, recordSize int) (int64, error) {
+	if reader.DriveIsRegular {
+		return reader.Drive.Seek(0, io.SeekEnd)
+	}
+
+	if err := mt.GoToEndOfTape(reader.Drive.Fd()); err != nil {
+		return -1, err
+	}
+
+	record, err := mt.GetCurrentRecordFromTape(reader.Drive.Fd())
+	if err != nil {
+		return -1, err
+	}
+
+	return record * int64(recordSize) * config.MagneticTapeBlockSize, nil
+}
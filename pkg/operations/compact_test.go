@@ -0,0 +1,204 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var compactTests = []struct {
+	name          string
+	pipes         config.PipeConfig
+	dstPipes      config.PipeConfig
+	runs          []map[string]string
+	links         map[string]string // Hard links to create in the first run, by their target
+	delete        []string
+	want          map[string]string
+	wantReclaimed bool
+}{
+	{
+		"Can compact archive without deleted files",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{},
+		[]string{},
+		map[string]string{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		false,
+	},
+	{
+		"Can reclaim deleted file",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{},
+		[]string{"b.txt"},
+		map[string]string{"a.txt": "a"},
+		true,
+	},
+	{
+		"Can reclaim replaced file",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+			{"b.txt": "b"},
+		},
+		map[string]string{},
+		[]string{},
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		true,
+	},
+	{
+		"Can keep content of deduplicated file whose original is deleted",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000), "b.txt": getTestContent(0, 30000), "c.txt": getTestContent(1, 30000)},
+		},
+		map[string]string{},
+		[]string{"a.txt", "c.txt"},
+		map[string]string{"b.txt": getTestContent(0, 30000)},
+		true,
+	},
+	{
+		"Can keep hard link",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{"b.txt": "a.txt"},
+		[]string{},
+		map[string]string{"a.txt": getTestContent(0, 30000), "b.txt": getTestContent(0, 30000)},
+		false,
+	},
+	{
+		"Can keep content of hard link whose target is deleted",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000), "c.txt": "c"},
+		},
+		map[string]string{"b.txt": "a.txt"},
+		[]string{"a.txt"},
+		map[string]string{"b.txt": getTestContent(0, 30000), "c.txt": "c"},
+		true, // The deleted file and its delete record aren't copied
+	},
+	{
+		"Can keep content of hard links whose target is deleted once",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{"b.txt": "a.txt", "c.txt": "a.txt"},
+		[]string{"a.txt"},
+		map[string]string{"b.txt": getTestContent(0, 30000), "c.txt": getTestContent(0, 30000)},
+		true, // The deleted file and its delete record aren't copied
+	},
+	{
+		"Can reclaim deleted compressed file",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{},
+		[]string{"b.txt"},
+		map[string]string{"a.txt": "a"},
+		true,
+	},
+	{
+		"Can re-encode files with other compression",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		},
+		map[string]string{},
+		[]string{},
+		map[string]string{"a.txt": "a", "b.txt": getTestContent(0, 30000)},
+		true, // The content is compressed
+	},
+}
+
+func TestOperations_Compact(t *testing.T) {
+	for _, tt := range compactTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				if i == 0 {
+					for name, target := range tt.links {
+						if err := os.Link(filepath.Join(src, target), filepath.Join(src, name)); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+
+				if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, name := range tt.delete {
+				if err := to.ops.Delete(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			compacted, err := createOperations(filepath.Join(dir, "compacted"), tt.dstPipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reclaimed, err := to.ops.Compact(compacted.ops, config.CompressionLevelFastestKey)
+			if err != nil {
+				t.Errorf("Compact() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if (reclaimed > 0) != tt.wantReclaimed {
+				t.Errorf("Compact() reclaimed = %v, want reclaimed %v", reclaimed, tt.wantReclaimed)
+			}
+
+			for _, name := range tt.delete {
+				if _, err := compacted.ops.metadata.Metadata.GetHeader(context.Background(), name); err == nil {
+					t.Errorf("Compact() kept deleted file %v", name)
+				}
+			}
+
+			got, err := restoreTestFiles(compacted.ops, filepath.Join(dir, "dst"), tt.want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(tt.want))
+			}
+		})
+	}
+}
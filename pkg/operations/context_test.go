@@ -0,0 +1,244 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+var archiveContextTests = []struct {
+	name      string
+	workers   int
+	files     []string
+	cancel    int      // Index of the file after which the context is cancelled
+	wantFiles []string // Files which are archived before the cancellation; nil if it depends on the workers
+}{
+	{
+		"Can index files before cancellation",
+		1,
+		[]string{"a.txt", "b.txt", "c.txt", "d.txt"},
+		2,
+		[]string{"a.txt", "b.txt"},
+	},
+	{
+		"Can index nothing if cancelled immediately",
+		1,
+		[]string{"a.txt", "b.txt"},
+		0,
+		[]string{},
+	},
+	{
+		"Can index files before cancellation with multiple workers",
+		4,
+		[]string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt", "f.txt", "g.txt", "h.txt"},
+		3,
+		nil,
+	},
+}
+
+func TestOperations_ArchiveContext(t *testing.T) {
+	for _, tt := range archiveContextTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, Workers: tt.workers}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := to.ops.Initialize("/", os.ModePerm, config.CompressionLevelFastestKey); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			i := 0
+			hdrs, err := to.ops.ArchiveContext(ctx, func() (config.FileConfig, error) {
+				if i == tt.cancel {
+					cancel()
+				}
+
+				if i >= len(tt.files) {
+					return config.FileConfig{}, io.EOF
+				}
+
+				name := tt.files[i]
+				i++
+
+				return NewStreamFileConfig(name, 0600, archivedModTime, func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(name)), nil
+				}), nil
+			}, config.CompressionLevelFastestKey, false, false)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("ArchiveContext() error = %v, wantErr %v", err, context.Canceled)
+
+				return
+			}
+
+			archived := []string{}
+			for _, hdr := range hdrs {
+				archived = append(archived, hdr.Name)
+			}
+
+			if tt.wantFiles != nil && !reflect.DeepEqual(archived, tt.wantFiles) {
+				t.Errorf("ArchiveContext() headers = %v, want %v", archived, tt.wantFiles)
+			}
+
+			// The files which are returned are the ones which are indexed
+			if got := getIndexedTestFiles(t, to.ops); !reflect.DeepEqual(got, archived) {
+				t.Errorf("ArchiveContext() indexed = %v, want %v", got, archived)
+			}
+
+			// The tar file can be appended to, so no partial file is left at its end
+			if _, err := to.ops.Archive(func() (config.FileConfig, error) {
+				if i < 0 {
+					return config.FileConfig{}, io.EOF
+				}
+				i = -1
+
+				return NewStreamFileConfig("z.txt", 0600, archivedModTime, func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("z.txt")), nil
+				}), nil
+			}, config.CompressionLevelFastestKey, false, false); err != nil {
+				t.Errorf("Archive() after cancellation error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			want := map[string]string{"z.txt": "z.txt"}
+			for _, name := range archived {
+				want[name] = name
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+var cancelledContextTests = []struct {
+	name      string
+	operation func(ctx context.Context, ops *Operations, dst string) error
+}{
+	{
+		"Can not delete with cancelled context",
+		func(ctx context.Context, ops *Operations, dst string) error {
+			return ops.DeleteContext(ctx, "a.txt")
+		},
+	},
+	{
+		"Can not move with cancelled context",
+		func(ctx context.Context, ops *Operations, dst string) error {
+			return ops.MoveContext(ctx, "a.txt", "c.txt")
+		},
+	},
+	{
+		"Can not restore with cancelled context",
+		func(ctx context.Context, ops *Operations, dst string) error {
+			return ops.RestoreContext(ctx, sinks.NewFilesystemSink(false, nil, nil, false), "a.txt", dst, false, config.ConflictPolicyOverwrite, nil)
+		},
+	},
+	{
+		"Can not verify with cancelled context",
+		func(ctx context.Context, ops *Operations, dst string) error {
+			return ops.VerifyContext(ctx, nil)
+		},
+	},
+}
+
+func TestOperations_CancelledContext(t *testing.T) {
+	for _, tt := range cancelledContextTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			files := map[string]string{"a.txt": "a", "b.txt": "b"}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, files); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			before, err := os.ReadFile(to.drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if err := tt.operation(ctx, to.ops, dst); !errors.Is(err, context.Canceled) {
+				t.Errorf("operation() error = %v, wantErr %v", err, context.Canceled)
+
+				return
+			}
+
+			after, err := os.ReadFile(to.drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(after, before) {
+				t.Errorf("operation() changed the tar file from %v to %v bytes", len(before), len(after))
+			}
+
+			if got := getIndexedTestFiles(t, to.ops); !reflect.DeepEqual(got, []string{"a.txt", "b.txt"}) {
+				t.Errorf("operation() changed the index to %v", got)
+			}
+
+			if got, err := readTestFiles(dst); err != nil || len(got) > 0 {
+				t.Errorf("operation() restored %v, error = %v", got, err)
+			}
+		})
+	}
+}
+
+// getIndexedTestFiles returns the sorted names of the regular files in the index
+func getIndexedTestFiles(t *testing.T, ops *Operations) []string {
+	hdrs, err := ops.metadata.Metadata.GetHeaders(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, hdr := range hdrs {
+		if hdr.Typeflag != tar.TypeDir {
+			names = append(names, hdr.Name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
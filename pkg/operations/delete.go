@@ -8,40 +8,37 @@ import (
 
 	"github.com/pojntfx/stfs/internal/converters"
 	"github.com/pojntfx/stfs/internal/records"
-	"github.com/pojntfx/stfs/internal/tarext"
 	"github.com/pojntfx/stfs/pkg/config"
-	"github.com/pojntfx/stfs/pkg/encryption"
-	"github.com/pojntfx/stfs/pkg/recovery"
-	"github.com/pojntfx/stfs/pkg/signature"
 )
 
 func (o *Operations) Delete(name string) error {
+	return o.DeleteContext(context.Background(), name)
+}
+
+// DeleteContext is like Delete, but can be cancelled with ctx until the deletion headers are written; they are always written completely
+func (o *Operations) DeleteContext(ctx context.Context, name string) error {
 	name = filepath.ToSlash(name)
 
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
 
-	writer, err := o.backend.GetWriter()
-	if err != nil {
-		return err
-	}
-
-	dirty := false
-	tw, cleanup, err := tarext.NewTapeWriter(writer.Drive, writer.DriveIsRegular, o.pipes.RecordSize)
-	if err != nil {
-		return err
-	}
-
-	lastIndexedRecord, lastIndexedBlock, err := o.metadata.Metadata.GetLastIndexedRecordAndBlock(context.Background(), o.pipes.RecordSize)
+	vw, err := o.newVolumeWriter(ctx, false, false, func(hdr *config.Header) {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeDelete,
+			Indexed: true,
+			Header:  hdr,
+		})
+	})
 	if err != nil {
 		return err
 	}
+	defer vw.discard()
 
 	headersToDelete := []*config.Header{}
-	dbhdr, err := o.metadata.Metadata.GetHeader(context.Background(), name)
+	dbhdr, err := o.metadata.Metadata.GetHeader(ctx, name)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			dbhdr, err = o.metadata.Metadata.GetHeaderByLinkname(context.Background(), name)
+			dbhdr, err = o.metadata.Metadata.GetHeaderByLinkname(ctx, name)
 			if err != nil {
 				return err
 			}
@@ -53,7 +50,7 @@ func (o *Operations) Delete(name string) error {
 
 	// If the header refers to a directory, get it's children
 	if dbhdr.Typeflag == tar.TypeDir && dbhdr.Linkname == "" {
-		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(context.Background(), name)
+		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(ctx, name)
 		if err != nil {
 			return err
 		}
@@ -61,8 +58,12 @@ func (o *Operations) Delete(name string) error {
 		headersToDelete = append(headersToDelete, dbhdrs...)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Append deletion hdrs to the tape or tar file
-	hdrs := []tar.Header{}
+	session := NewSession()
 	for _, dbhdr := range headersToDelete {
 		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
 		if err != nil {
@@ -72,8 +73,7 @@ func (o *Operations) Delete(name string) error {
 		hdr.Size = 0 // Don't try to seek after the record
 		hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
 		hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionDelete
-
-		hdrs = append(hdrs, *hdr)
+		hdr.PAXRecords[records.STFSRecordSession] = session
 
 		if o.onHeader != nil {
 			dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
@@ -88,67 +88,10 @@ func (o *Operations) Delete(name string) error {
 			})
 		}
 
-		if err := signature.SignHeader(hdr, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity); err != nil {
+		if err := vw.write(hdr, nil); err != nil {
 			return err
 		}
-
-		if err := encryption.EncryptHeader(hdr, o.pipes.Encryption, o.crypto.Recipient); err != nil {
-			return err
-		}
-
-		if err := tw.WriteHeader(hdr); err != nil {
-			return err
-		}
-
-		dirty = true
-	}
-
-	if err := cleanup(&dirty); err != nil {
-		return err
-	}
-
-	if err := o.backend.CloseWriter(); err != nil {
-		return err
-	}
-
-	reader, err := o.backend.GetReader()
-	if err != nil {
-		return err
 	}
-	defer o.backend.CloseReader()
-
-	return recovery.Index(
-		reader,
-		o.backend.MagneticTapeIO,
-		o.metadata,
-		o.pipes,
-		o.crypto,
-
-		int(lastIndexedRecord),
-		int(lastIndexedBlock),
-		false,
-		false,
-		1, // Ignore the first header, which is the last header which we already indexed
-
-		func(hdr *tar.Header, i int) error {
-			if len(hdrs) <= i {
-				return config.ErrTarHeaderMissing
-			}
-
-			*hdr = hdrs[i]
 
-			return nil
-		},
-		func(hdr *tar.Header, isRegular bool) error {
-			return nil // We sign above, no need to verify
-		},
-
-		func(hdr *config.Header) {
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeDelete,
-				Indexed: true,
-				Header:  hdr,
-			})
-		},
-	)
+	return vw.close()
 }
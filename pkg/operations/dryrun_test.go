@@ -0,0 +1,169 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var dryRunTests = []struct {
+	name      string
+	operation func(ops *Operations) error
+	want      []string // Types of the indexed header events which are reported
+}{
+	{
+		"Can archive without writing",
+		func(ops *Operations) error {
+			_, err := ops.Archive(newDryRunTestSource("c.txt"), config.CompressionLevelFastestKey, false, false)
+
+			return err
+		},
+		[]string{config.HeaderEventTypeArchive},
+	},
+	{
+		"Can overwrite without writing",
+		func(ops *Operations) error {
+			_, err := ops.Archive(newDryRunTestSource("c.txt"), config.CompressionLevelFastestKey, true, false)
+
+			return err
+		},
+		[]string{config.HeaderEventTypeDelete, config.HeaderEventTypeDelete, config.HeaderEventTypeArchive},
+	},
+	{
+		"Can update without writing",
+		func(ops *Operations) error {
+			_, err := ops.Update(newDryRunTestSource("a.txt"), config.CompressionLevelFastestKey, true, false)
+
+			return err
+		},
+		[]string{config.HeaderEventTypeUpdate},
+	},
+	{
+		"Can delete without writing",
+		func(ops *Operations) error {
+			return ops.Delete("a.txt")
+		},
+		[]string{config.HeaderEventTypeDelete},
+	},
+	{
+		"Can move without writing",
+		func(ops *Operations) error {
+			return ops.Move("a.txt", "c.txt")
+		},
+		[]string{config.HeaderEventTypeMove},
+	},
+}
+
+func TestOperations_DryRun(t *testing.T) {
+	for _, tt := range dryRunTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "a", "b.txt": "b"}); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			driveBefore, err := os.ReadFile(to.drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			indexBefore, err := getDryRunTestIndex(to.ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			to.ops.onHeader = func(event *config.HeaderEvent) {
+				if event.Indexed {
+					got = append(got, event.Type)
+				}
+			}
+			to.ops.dryRun = true
+
+			if err := tt.operation(to.ops); err != nil {
+				t.Errorf("operation() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("operation() indexed header events = %v, want %v", got, tt.want)
+			}
+
+			driveAfter, err := os.ReadFile(to.drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(driveAfter, driveBefore) {
+				t.Errorf("operation() changed the tar file from %v to %v bytes", len(driveBefore), len(driveAfter))
+			}
+
+			indexAfter, err := getDryRunTestIndex(to.ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(indexAfter, indexBefore) {
+				t.Errorf("operation() changed the index from %v to %v", indexBefore, indexAfter)
+			}
+
+			if _, err := os.Stat(to.metadata + ".journal"); !os.IsNotExist(err) {
+				t.Errorf("operation() journal error = %v, want no journal", err)
+			}
+		})
+	}
+}
+
+func newDryRunTestSource(name string) func() (config.FileConfig, error) {
+	done := false
+
+	return func() (config.FileConfig, error) {
+		if done {
+			return config.FileConfig{}, io.EOF
+		}
+		done = true
+
+		return NewStreamFileConfig(name, 0600, archivedModTime, func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("new")), nil
+		}), nil
+	}
+}
+
+// getDryRunTestIndex returns the headers in the index and the history of the files which the operations change
+func getDryRunTestIndex(ops *Operations) ([]*config.Header, error) {
+	hdrs, err := ops.metadata.Metadata.GetHeaders(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		versions, err := ops.metadata.Metadata.GetHeaderHistory(context.Background(), name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, version := range versions {
+			hdrs = append(hdrs, version.Header)
+		}
+	}
+
+	return hdrs, nil
+}
@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"context"
+	"io"
+
+	"github.com/pojntfx/stfs/pkg/compression"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+func (o *Operations) Export(
+	w io.Writer,
+
+	from string,
+	format string,
+) error {
+	return o.ExportContext(
+		context.Background(),
+
+		w,
+
+		from,
+		format,
+	)
+}
+
+// ExportContext writes a file or directory as a plain tar, tar.zst or zip stream to w; the content is decrypted, decompressed and verified,
+// and the STFS suffixes and PAX records are left out so that standard tools can read it
+func (o *Operations) ExportContext(
+	ctx context.Context,
+
+	w io.Writer,
+
+	from string,
+	format string,
+) error {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	var (
+		sink       config.RestoreSink
+		compressor io.WriteCloser
+	)
+	switch format {
+	case config.ExportFormatTar:
+		sink = sinks.NewTarSink(w)
+	case config.ExportFormatTarZstd:
+		var err error
+		compressor, err = compression.Compress(w, config.CompressionFormatZStandardKey, config.CompressionLevelBalancedKey, true, o.pipes.RecordSize)
+		if err != nil {
+			return err
+		}
+
+		sink = sinks.NewTarSink(compressor)
+	case config.ExportFormatZip:
+		sink = sinks.NewZipSink(w)
+	default:
+		return config.ErrExportFormatUnknown
+	}
+
+	// Archives have no existing files to conflict with
+	if err := o.restoreMany(ctx, sink, []string{from}, "", false, config.ConflictPolicyOverwrite, nil); err != nil {
+		return err
+	}
+
+	if err := sink.Close(); err != nil {
+		return err
+	}
+
+	if compressor != nil {
+		return compressor.Close()
+	}
+
+	return nil
+}
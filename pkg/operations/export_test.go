@@ -0,0 +1,189 @@
+package operations
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var errSTFSRecordExported = errors.New("STFS record exported")
+
+var exportTests = []struct {
+	name    string
+	pipes   config.PipeConfig
+	from    string
+	format  string
+	want    map[string]string
+	wantErr error
+}{
+	{
+		"Can export directory as tar",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		"d",
+		config.ExportFormatTar,
+		map[string]string{"d/": "", "d/b.txt": "same", "d/c.txt": "c", "d/e/": "", "d/e/f.txt": "f"},
+		nil,
+	},
+	{
+		"Can export file as tar",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		"a.txt",
+		config.ExportFormatTar,
+		map[string]string{"a.txt": "same"},
+		nil,
+	},
+	{
+		"Can export compressed directory as tar.zst",
+		config.PipeConfig{Compression: config.CompressionFormatGZipKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		"d",
+		config.ExportFormatTarZstd,
+		map[string]string{"d/": "", "d/b.txt": "same", "d/c.txt": "c", "d/e/": "", "d/e/f.txt": "f"},
+		nil,
+	},
+	{
+		"Can export compressed directory as zip",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		"d",
+		config.ExportFormatZip,
+		map[string]string{"d/": "", "d/b.txt": "same", "d/c.txt": "c", "d/e/": "", "d/e/f.txt": "f"},
+		nil,
+	},
+	{
+		"Can not export with unknown format",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		"d",
+		"rar",
+		nil,
+		config.ErrExportFormatUnknown,
+	},
+}
+
+func TestOperations_Export(t *testing.T) {
+	for _, tt := range exportTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// b.txt is deduplicated and references the content of a.txt
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "same", "d/b.txt": "same", "d/c.txt": "c", "d/e/f.txt": "f"}); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			out := &bytes.Buffer{}
+			if err := to.ops.Export(out, tt.from, tt.format); err != tt.wantErr {
+				t.Errorf("Export() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tt.format == config.ExportFormatTarZstd && !bytes.HasPrefix(out.Bytes(), zstdMagic) {
+				t.Errorf("Export() archive isn't compressed with zstd")
+			}
+
+			var got map[string]string
+			if tt.format == config.ExportFormatZip {
+				got, err = readZipTestArchive(out.Bytes())
+			} else {
+				got, err = readTarTestArchive(out)
+			}
+			if err != nil {
+				t.Errorf("Export() archive error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Export() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// readTarTestArchive reads the content of the entries of a plain or compressed tar archive, which can't have STFS PAX records
+func readTarTestArchive(r io.Reader) (map[string]string, error) {
+	archive, err := decompressArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	entries := map[string]string{}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for key := range hdr.PAXRecords {
+			if strings.HasPrefix(key, "STFS.") {
+				return nil, errSTFSRecordExported
+			}
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[hdr.Name] = string(content)
+	}
+
+	return entries, nil
+}
+
+// readZipTestArchive reads the content of the entries of a zip archive
+func readZipTestArchive(content []byte) (map[string]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{}
+	for _, file := range zr.File {
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			_ = f.Close()
+
+			return nil, err
+		}
+
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+
+		entries[file.Name] = string(content)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,7 @@
+package operations
+
+// inode identifies a file independently of its path, so that hard links to it can be detected
+type inode struct {
+	dev uint64
+	ino uint64
+}
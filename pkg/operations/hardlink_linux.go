@@ -0,0 +1,25 @@
+//go:build linux
+
+package operations
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// getInode returns the inode of regular files which have more than one hard link
+func getInode(info fs.FileInfo) (inode, bool) {
+	if info == nil || !info.Mode().IsRegular() {
+		return inode{}, false
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return inode{}, false
+	}
+
+	return inode{
+		dev: uint64(stat.Dev),
+		ino: uint64(stat.Ino),
+	}, true
+}
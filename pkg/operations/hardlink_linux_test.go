@@ -0,0 +1,149 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+var restoreHardlinksTests = []struct {
+	name        string
+	files       map[string]string
+	links       map[string]string // Hard links to create and the files they link to
+	from        string
+	wantIndexed map[string]string // Hard links in the index and the files they link to
+	want        map[string]string // Restored files relative to the directory which they are restored to
+	wantLinked  [][]string        // Restored files which are the same file
+}{
+	{
+		"Can restore hard link with the file it links to",
+		map[string]string{"d/a.txt": "a"},
+		map[string]string{"d/b.txt": "d/a.txt"},
+		"d",
+		map[string]string{"d/b.txt": "d/a.txt"},
+		map[string]string{"a.txt": "a", "b.txt": "a"},
+		[][]string{{"a.txt", "b.txt"}},
+	},
+	{
+		"Can restore multiple hard links to the same file",
+		map[string]string{"d/a.txt": "a", "d/c.txt": "c"},
+		map[string]string{"d/b.txt": "d/a.txt", "d/e.txt": "d/a.txt"},
+		"d",
+		map[string]string{"d/b.txt": "d/a.txt", "d/e.txt": "d/a.txt"},
+		map[string]string{"a.txt": "a", "b.txt": "a", "c.txt": "c", "e.txt": "a"},
+		[][]string{{"a.txt", "b.txt", "e.txt"}},
+	},
+	{
+		"Can restore hard link without the file it links to",
+		map[string]string{"d/a.txt": "a"},
+		map[string]string{"d/b.txt": "d/a.txt"},
+		"d/b.txt",
+		map[string]string{"d/b.txt": "d/a.txt"},
+		map[string]string{"b.txt": "a"},
+		[][]string{},
+	},
+	{
+		"Can restore hard link to file in other directory",
+		map[string]string{"d/a.txt": "a"},
+		map[string]string{"e/b.txt": "d/a.txt"},
+		"e",
+		map[string]string{"e/b.txt": "d/a.txt"},
+		map[string]string{"b.txt": "a"},
+		[][]string{},
+	},
+}
+
+func TestOperations_RestoreHardlinks(t *testing.T) {
+	for _, tt := range restoreHardlinksTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, tt.files); err != nil {
+				t.Fatal(err)
+			}
+
+			for name, target := range tt.links {
+				p := filepath.Join(src, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.Link(filepath.Join(src, filepath.FromSlash(target)), p); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			hdrs, err := to.ops.metadata.Metadata.GetHeaders(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			indexed := map[string]string{}
+			for _, hdr := range hdrs {
+				if hdr.Typeflag == tar.TypeLink {
+					indexed[hdr.Name] = hdr.Linkname
+				}
+			}
+
+			if !reflect.DeepEqual(indexed, tt.wantIndexed) {
+				t.Errorf("Archive() indexed hard links = %v, want %v", indexed, tt.wantIndexed)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := to.ops.Restore(sinks.NewFilesystemSink(false, nil, nil, false), tt.from, dst, false, config.ConflictPolicyOverwrite, nil); err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() = %v, want %v", got, tt.want)
+			}
+
+			for _, linked := range tt.wantLinked {
+				first, err := os.Stat(filepath.Join(dst, filepath.FromSlash(linked[0])))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, name := range linked[1:] {
+					info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !os.SameFile(first, info) {
+						t.Errorf("Restore() created %v as a copy of %v, want hard link", name, linked[0])
+					}
+				}
+			}
+		})
+	}
+}
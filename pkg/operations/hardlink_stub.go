@@ -0,0 +1,10 @@
+//go:build !linux
+
+package operations
+
+import "io/fs"
+
+// getInode never detects hard links, as inodes are not available on this system
+func getInode(info fs.FileInfo) (inode, bool) {
+	return inode{}, false
+}
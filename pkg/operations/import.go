@@ -0,0 +1,214 @@
+package operations
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/ulikunitz/xz"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+	// PAX records which are stored in the fields of a header and are written again from them
+	basicPAXRecords = map[string]struct{}{
+		"path":     {},
+		"linkpath": {},
+		"size":     {},
+		"uid":      {},
+		"gid":      {},
+		"uname":    {},
+		"gname":    {},
+		"mtime":    {},
+		"atime":    {},
+		"ctime":    {},
+	}
+)
+
+func (o *Operations) Import(
+	r io.Reader,
+	compressionLevel string,
+	overwrite bool,
+) ([]*tar.Header, error) {
+	return o.ImportContext(context.Background(), r, compressionLevel, overwrite)
+}
+
+// ImportContext archives the entries of a tar archive, which can be compressed with gzip, zstd or xz, with their original headers;
+// the entries are read from r one after another instead of extracting them first. It stops once ctx has been cancelled in the same way as ArchiveContext.
+func (o *Operations) ImportContext(
+	ctx context.Context,
+	r io.Reader,
+	compressionLevel string,
+	overwrite bool,
+) ([]*tar.Header, error) {
+	archive, err := decompressArchive(r)
+	if err != nil {
+		return []*tar.Header{}, err
+	}
+	defer archive.Close()
+
+	return o.ArchiveContext(ctx, NewTarSource(archive), compressionLevel, overwrite, false)
+}
+
+// NewTarSource creates a source for Archive and Update from the entries of a tar archive. Since the archive can only be read
+// sequentially, getting the next entry waits until the content of the previous one has been read and closed if it has been opened;
+// if it hasn't been opened, its content is skipped and it can't be opened anymore.
+func NewTarSource(r io.Reader) func() (config.FileConfig, error) {
+	tr := tar.NewReader(r)
+
+	var previous *tarEntry
+
+	return func() (config.FileConfig, error) {
+		if previous != nil {
+			if previous.pass() {
+				<-previous.done
+			}
+
+			previous = nil
+		}
+
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				return config.FileConfig{}, err
+			}
+
+			// Global headers only apply to the entries after them, which the reader has already done
+			if hdr.Typeflag == tar.TypeXGlobalHeader {
+				continue
+			}
+
+			importHdr := *hdr
+			importHdr.PAXRecords = map[string]string{}
+			for key, value := range hdr.PAXRecords {
+				if _, ok := basicPAXRecords[key]; ok || strings.HasPrefix(key, "GNU.sparse.") {
+					continue
+				}
+
+				importHdr.PAXRecords[key] = value
+			}
+
+			file := config.FileConfig{
+				GetReader: func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader([]byte{})), nil
+				},
+				Info: importHdr.FileInfo(), // Archive uses the header of the FileInfo for the ownership, times and PAX records
+				Path: path.Clean(hdr.Name),
+				Link: hdr.Linkname,
+			}
+
+			if hdr.Typeflag == tar.TypeLink {
+				file.Hardlink = true
+				file.Link = path.Clean(hdr.Linkname)
+			} else if file.Info.Mode().IsRegular() && hdr.Size > 0 {
+				entry := &tarEntry{done: make(chan struct{})}
+				previous = entry
+
+				file.GetReader = func() (io.ReadCloser, error) {
+					if !entry.open() {
+						return nil, config.ErrTarEntryPassed
+					}
+
+					return &tarEntryReader{Reader: tr, entry: entry}, nil
+				}
+			}
+
+			return file, nil
+		}
+	}
+}
+
+// tarEntry tracks whether the content of an entry of a tar archive is being read
+type tarEntry struct {
+	lock   sync.Mutex
+	opened bool
+	passed bool // The archive has been read past the entry, which skips its content
+
+	done chan struct{}
+	once sync.Once
+}
+
+// open marks the entry as opened, unless the archive has been read past it
+func (e *tarEntry) open() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.passed {
+		return false
+	}
+
+	e.opened = true
+
+	return true
+}
+
+// pass marks the entry as passed if it hasn't been opened; otherwise it returns true, and its content has to be read before reading past it
+func (e *tarEntry) pass() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.opened {
+		return true
+	}
+
+	e.passed = true
+
+	return false
+}
+
+// tarEntryReader reads the content of the current entry of a tar archive and signals that it has been read once it is closed
+type tarEntryReader struct {
+	io.Reader
+
+	entry *tarEntry
+}
+
+func (r *tarEntryReader) Close() error {
+	r.entry.once.Do(func() {
+		close(r.entry.done)
+	})
+
+	return nil
+}
+
+// decompressArchive detects the compression of a tar archive by its magic bytes
+func decompressArchive(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(xzMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, xzMagic):
+		xr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return io.NopCloser(xr), nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
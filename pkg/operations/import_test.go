@@ -0,0 +1,317 @@
+package operations
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/ulikunitz/xz"
+)
+
+var importTests = []struct {
+	name     string
+	compress func(w io.Writer) (io.WriteCloser, error)
+}{
+	{
+		"Can import tar",
+		func(w io.Writer) (io.WriteCloser, error) {
+			return nopWriteCloser{w}, nil
+		},
+	},
+	{
+		"Can import tar.gz",
+		func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	},
+	{
+		"Can import tar.zst",
+		func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+	{
+		"Can import tar.xz",
+		func(w io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(w)
+		},
+	},
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// writeTestArchive writes a tar archive with a directory, files, a symlink and a hard link
+func writeTestArchive(w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	for _, entry := range []struct {
+		hdr     *tar.Header
+		content string
+	}{
+		{&tar.Header{Typeflag: tar.TypeXGlobalHeader, Name: "global", PAXRecords: map[string]string{"comment": "imported"}, Format: tar.FormatPAX}, ""},
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "d/", Mode: 0755, ModTime: archivedModTime}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "d/a.txt", Mode: 0644, ModTime: archivedModTime}, "a"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "./b.txt", Mode: 0600, ModTime: archivedModTime}, getTestContent(0, 30000)},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "e.txt", Mode: 0600, ModTime: archivedModTime}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "c", Linkname: "b.txt", Mode: 0777, ModTime: archivedModTime}, ""},
+		{&tar.Header{Typeflag: tar.TypeLink, Name: "d/h.txt", Linkname: "./b.txt", ModTime: archivedModTime}, ""},
+	} {
+		entry.hdr.Size = int64(len(entry.content))
+		if err := tw.WriteHeader(entry.hdr); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(tw, entry.content); err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+func TestOperations_Import(t *testing.T) {
+	for _, tt := range importTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			archive := &bytes.Buffer{}
+			compressor, err := tt.compress(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := writeTestArchive(compressor); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := compressor.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hdrs, err := to.ops.Import(archive, config.CompressionLevelFastestKey, true)
+			if err != nil {
+				t.Errorf("Import() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			names := []string{}
+			for _, hdr := range hdrs {
+				names = append(names, hdr.Name)
+			}
+
+			// Compressed files get the suffix of the compression format
+			if want := []string{"d", "d/a.txt.zst", "b.txt.zst", "e.txt", "c", "d/h.txt"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("Import() names = %v, want %v", names, want)
+			}
+
+			want := map[string]string{"d/a.txt": "a", "b.txt": getTestContent(0, 30000), "e.txt": "", "d/h.txt": getTestContent(0, 30000)}
+
+			dst := filepath.Join(dir, "dst")
+			got, err := restoreTestFiles(to.ops, dst, want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(want))
+			}
+
+			if _, err := restoreTestFiles(to.ops, dst, map[string]string{"c": ""}); err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if link, err := os.Readlink(filepath.Join(dst, "c")); err != nil || link != "b.txt" {
+				t.Errorf("Restore() symlink = %v, error = %v, want %v", link, err, "b.txt")
+			}
+		})
+	}
+}
+
+func TestOperations_ExportImport(t *testing.T) {
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "src")
+	if err := writeTestFiles(src, map[string]string{"a.txt": "a", "d/b.txt": getTestContent(0, 30000), "d/c.txt": "c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	from, err := createOperations(filepath.Join(dir, "from"), config.PipeConfig{Compression: config.CompressionFormatGZipKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := from.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Zip archives can't be imported
+	for _, format := range []string{config.ExportFormatTar, config.ExportFormatTarZstd} {
+		t.Run(format, func(t *testing.T) {
+			archive := &bytes.Buffer{}
+			if err := from.ops.Export(archive, "d", format); err != nil {
+				t.Errorf("Export() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			to, err := createOperations(filepath.Join(dir, "to-"+format), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Import(archive, config.CompressionLevelFastestKey, true); err != nil {
+				t.Errorf("Import() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			want := map[string]string{"d/b.txt": getTestContent(0, 30000), "d/c.txt": "c"}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst-"+format), want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(want))
+			}
+		})
+	}
+}
+
+var newTarSourceTests = []struct {
+	name string
+	open []string // Entries to open and read, the others are skipped
+	want map[string]string
+}{
+	{
+		"Can read all entries",
+		[]string{"d/a.txt", "b.txt"},
+		map[string]string{"d/a.txt": "a", "b.txt": getTestContent(0, 30000)},
+	},
+	{
+		"Can skip entry without opening it",
+		[]string{"b.txt"},
+		map[string]string{"b.txt": getTestContent(0, 30000)},
+	},
+	{
+		"Can skip all entries without opening them",
+		[]string{},
+		map[string]string{},
+	},
+}
+
+func TestNewTarSource(t *testing.T) {
+	for _, tt := range newTarSourceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := &bytes.Buffer{}
+			if err := writeTestArchive(archive); err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			skipped := []config.FileConfig{}
+			errs := make(chan error)
+			go func() {
+				getSrc := NewTarSource(archive)
+				for {
+					file, err := getSrc()
+					if err == io.EOF {
+						errs <- nil
+
+						return
+					}
+
+					if err != nil {
+						errs <- err
+
+						return
+					}
+
+					if file.GetReader == nil {
+						continue
+					}
+
+					if !contains(tt.open, file.Path) {
+						skipped = append(skipped, file)
+
+						continue
+					}
+
+					f, err := file.GetReader()
+					if err != nil {
+						errs <- err
+
+						return
+					}
+
+					content, err := io.ReadAll(f)
+					if err != nil {
+						errs <- err
+
+						return
+					}
+
+					if err := f.Close(); err != nil {
+						errs <- err
+
+						return
+					}
+
+					got[file.Path] = string(content)
+				}
+			}()
+
+			select {
+			case err := <-errs:
+				if err != nil {
+					t.Errorf("NewTarSource() error = %v, wantErr %v", err, false)
+
+					return
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("NewTarSource() is waiting for an entry which hasn't been opened")
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewTarSource() got different content for %v files, want %v files", len(got), len(tt.want))
+			}
+
+			for _, file := range skipped {
+				if file.Info.Size() == 0 {
+					continue // Entries without content can always be opened
+				}
+
+				if _, err := file.GetReader(); err != config.ErrTarEntryPassed {
+					t.Errorf("GetReader() of skipped entry %v error = %v, want %v", file.Path, err, config.ErrTarEntryPassed)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/suffix"
+	"github.com/pojntfx/stfs/internal/xattrext"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// UpdateIncremental only archives the files from getSrc which are new or have changed since they were indexed
+// and deletes the indexed files below root which have disappeared. If baseline is set, files are compared
+// against that session instead, so everything which changed since the baseline is archived (differential mode).
+// isExcluded, which may be nil, reports the files getSrc skips on purpose, see NewWalkFilter; they are kept in the index instead of being deleted.
+func (o *Operations) UpdateIncremental(
+	root string,
+	getSrc func() (config.FileConfig, error),
+	isExcluded func(name string, isDir bool) (bool, error),
+	compressionLevel string,
+	compareHash bool,
+	baseline string,
+) ([]*tar.Header, error) {
+	return o.UpdateIncrementalContext(context.Background(), root, getSrc, isExcluded, compressionLevel, compareHash, baseline)
+}
+
+// UpdateIncrementalContext is like UpdateIncremental, but stops once ctx has been cancelled; the files which have been archived until then are indexed and returned together with the error of ctx.
+// Files which have disappeared are only deleted if all files from getSrc have been compared.
+func (o *Operations) UpdateIncrementalContext(
+	ctx context.Context,
+	root string,
+	getSrc func() (config.FileConfig, error),
+	isExcluded func(name string, isDir bool) (bool, error),
+	compressionLevel string,
+	compareHash bool,
+	baseline string,
+) ([]*tar.Header, error) {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	baselineSession := int64(0)
+	if baseline != "" {
+		var err error
+		baselineSession, err = strconv.ParseInt(baseline, 10, 64)
+		if err != nil {
+			return []*tar.Header{}, config.ErrSessionInvalid
+		}
+	}
+
+	indexed := 0
+	eventTypes := []string{}
+	vw, err := o.newVolumeWriter(ctx, false, false, func(hdr *config.Header) {
+		eventType := config.HeaderEventTypeUpdate
+		if indexed < len(eventTypes) {
+			eventType = eventTypes[indexed]
+		}
+		indexed++
+
+		o.onHeader(&config.HeaderEvent{
+			Type:    eventType,
+			Indexed: true,
+			Header:  hdr,
+		})
+	})
+	if err != nil {
+		return []*tar.Header{}, err
+	}
+	defer vw.discard()
+
+	var payload *ioext.SpoolWriter
+	defer func() {
+		if payload != nil {
+			_ = payload.Close()
+		}
+	}()
+
+	tracker := progress.NewTracker(config.ProgressEventTypeUpdate, 0, 0, o.onProgress)
+	defer tracker.Finish()
+
+	session := NewSession()
+	seen := map[string]struct{}{}
+	hdrs := []*tar.Header{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		file, err := getSrc()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		seen[path.Clean(file.Path)] = struct{}{}
+		tracker.Start(file.Path)
+
+		hdr, err := tar.FileInfoHeader(file.Info, file.Link)
+		if err != nil {
+			// Skip sockets
+			if strings.Contains(err.Error(), errSocketsNotSupported.Error()) {
+				continue
+			}
+
+			return hdrs, vw.abort(err)
+		}
+
+		hdr.Name = file.Path
+		hdr.Format = tar.FormatPAX
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = map[string]string{}
+		}
+		hdr.PAXRecords[records.STFSRecordSession] = session
+		xattrext.AddToPAXRecords(hdr.PAXRecords, file.Xattrs)
+
+		eventType := config.HeaderEventTypeArchive
+		dbhdr, err := o.metadata.Metadata.GetHeader(ctx, file.Path)
+		if err != nil && err != sql.ErrNoRows {
+			return hdrs, vw.abort(err)
+		}
+
+		if err == nil {
+			changed, err := hasChanged(ctx, dbhdr, hdr, file, compareHash, baselineSession)
+			if err != nil {
+				if ctx.Err() != nil {
+					return hdrs, vw.abort(ctx.Err())
+				}
+
+				return hdrs, vw.abort(err)
+			}
+
+			if !changed {
+				tracker.Done()
+
+				continue
+			}
+
+			eventType = config.HeaderEventTypeUpdate
+			hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
+			hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionUpdate
+			hdr.PAXRecords[records.STFSRecordReplacesContent] = records.STFSRecordReplacesContentTrue
+		}
+
+		if file.Info.Mode().IsRegular() && (file.Info.Size() > 0 || file.Stream) {
+			f, err := openFile(file)
+			if err != nil {
+				return hdrs, vw.abort(err)
+			}
+
+			payload, err = o.encodePayload(ctx, hdr, f, compressionLevel, vw.isRegular, payloadSpoolMemoryLimit, tracker)
+			if err != nil {
+				_ = f.Close()
+
+				if ctx.Err() != nil {
+					return hdrs, vw.abort(ctx.Err())
+				}
+
+				return hdrs, vw.abort(err)
+			}
+
+			if err := f.Close(); err != nil {
+				return hdrs, vw.abort(err)
+			}
+
+			// Reference identical content which has already been archived
+			payload, err = o.deduplicatePayload(hdr, payload)
+			if err != nil {
+				return hdrs, vw.abort(err)
+			}
+
+			if payload != nil {
+				tracker.AddWritten(payload.Size())
+			}
+
+			hdr.Name, err = suffix.AddSuffix(hdr.Name, o.pipes.Compression, o.pipes.Encryption)
+			if err != nil {
+				return hdrs, vw.abort(err)
+			}
+		}
+
+		if err := o.emitIncrementalHeader(hdr, eventType); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		eventTypes = append(eventTypes, eventType)
+
+		// The volume writer takes ownership of the payload
+		err = vw.write(hdr, payload)
+		payload = nil
+		if err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		hdrs = append(hdrs, hdr)
+
+		tracker.Done()
+	}
+
+	// Delete the files which have disappeared from the source
+	if err := ctx.Err(); err != nil {
+		return hdrs, vw.abort(err)
+	}
+
+	indexedHdrs, err := o.metadata.Metadata.GetHeaderChildren(ctx, root)
+	if err != nil {
+		return hdrs, vw.abort(err)
+	}
+
+	rootHdr, err := o.metadata.Metadata.GetHeader(ctx, root)
+	if err != nil && err != sql.ErrNoRows {
+		return hdrs, vw.abort(err)
+	}
+
+	if err == nil {
+		indexedHdrs = append(indexedHdrs, rootHdr)
+	}
+
+	for _, dbhdr := range indexedHdrs {
+		if _, ok := seen[path.Clean(dbhdr.Name)]; ok {
+			continue
+		}
+
+		if isExcluded != nil {
+			excluded, err := isExcluded(dbhdr.Name, dbhdr.Typeflag == tar.TypeDir)
+			if err != nil {
+				return hdrs, vw.abort(err)
+			}
+
+			if excluded {
+				continue
+			}
+		}
+
+		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+		if err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		hdr.Size = 0 // Don't try to seek after the record
+		hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
+		hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionDelete
+		hdr.PAXRecords[records.STFSRecordSession] = session
+
+		if err := o.emitIncrementalHeader(hdr, config.HeaderEventTypeDelete); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		eventTypes = append(eventTypes, config.HeaderEventTypeDelete)
+
+		if err := vw.write(hdr, nil); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
+		hdrs = append(hdrs, hdr)
+	}
+
+	return hdrs, vw.close()
+}
+
+func (o *Operations) emitIncrementalHeader(hdr *tar.Header, eventType string) error {
+	if o.onHeader == nil {
+		return nil
+	}
+
+	dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+	if err != nil {
+		return err
+	}
+
+	o.onHeader(&config.HeaderEvent{
+		Type:    eventType,
+		Indexed: false,
+		Header:  converters.DBHeaderToConfigHeader(dbhdr),
+	})
+
+	return nil
+}
+
+// isModTimeEqual compares the modification time of an indexed header with the one of a file; headers are written in the PAX format,
+// which keeps the nanoseconds, so the times are compared exactly
+func isModTimeEqual(indexed time.Time, current time.Time) bool {
+	return indexed.Equal(current)
+}
+
+func hasChanged(
+	ctx context.Context,
+	dbhdr *config.Header,
+	hdr *tar.Header,
+	file config.FileConfig,
+	compareHash bool,
+	baselineSession int64,
+) (bool, error) {
+	// In differential mode, everything that has been archived after the baseline has changed relative to it
+	if baselineSession > 0 {
+		session, err := getSession(dbhdr)
+		if err != nil {
+			return false, err
+		}
+
+		if session > baselineSession {
+			return true, nil
+		}
+	}
+
+	if dbhdr.Typeflag != int64(hdr.Typeflag) ||
+		dbhdr.Linkname != hdr.Linkname ||
+		dbhdr.Mode != hdr.Mode ||
+		!isModTimeEqual(dbhdr.Modtime, hdr.ModTime) {
+		return true, nil
+	}
+
+	indexedHdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+	if err != nil {
+		return false, err
+	}
+
+	indexedXattrs := xattrext.GetFromPAXRecords(indexedHdr.PAXRecords)
+	if len(indexedXattrs) != len(file.Xattrs) {
+		return true, nil
+	}
+
+	for name, value := range file.Xattrs {
+		if indexedValue, ok := indexedXattrs[name]; !ok || indexedValue != value {
+			return true, nil
+		}
+	}
+
+	if !file.Info.Mode().IsRegular() {
+		return false, nil
+	}
+
+	// The size of streams is only known after reading them
+	if file.Stream || dbhdr.Size != hdr.Size {
+		return true, nil
+	}
+
+	// Empty files have no content to compare
+	if !compareHash || hdr.Size == 0 {
+		return false, nil
+	}
+
+	indexedHash, ok := indexedHdr.PAXRecords[records.STFSRecordHash]
+	if !ok {
+		return true, nil
+	}
+
+	f, err := openFile(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, &ioext.ContextReader{Context: ctx, Reader: f}); err != nil {
+		return false, err
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)) != indexedHash, nil
+}
@@ -0,0 +1,187 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/filter"
+)
+
+var updateIncrementalTests = []struct {
+	name         string
+	files        map[string]string // Files to archive before the update
+	changes      map[string]string // Files to create or change before the update
+	remove       []string          // Files to remove before the update
+	excludes     []string          // Patterns to exclude in the update
+	wantArchived []string          // Regular files which are archived by the update
+	wantDeleted  []string          // Files and directories which are deleted by the update
+}{
+	{
+		"Can skip unchanged files",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{},
+		[]string{},
+		[]string{},
+		[]string{},
+		[]string{},
+	},
+	{
+		"Can archive new and changed files",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{"a.txt": "aa", "c.txt": "c"},
+		[]string{},
+		[]string{},
+		[]string{"src/a.txt", "src/c.txt"},
+		[]string{},
+	},
+	{
+		"Can delete disappeared file",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{},
+		[]string{"b.txt"},
+		[]string{},
+		[]string{},
+		[]string{"src/b.txt"},
+	},
+	{
+		"Can delete disappeared file despite other excludes",
+		map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+		map[string]string{},
+		[]string{"b.txt"},
+		[]string{"c.txt"},
+		[]string{},
+		[]string{"src/b.txt"},
+	},
+	{
+		"Can keep file which is excluded now",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{},
+		[]string{},
+		[]string{"b.txt"},
+		[]string{},
+		[]string{},
+	},
+	{
+		"Can keep directory which is excluded now",
+		map[string]string{"a.txt": "a", "d/e.txt": "e"},
+		map[string]string{},
+		[]string{},
+		[]string{"d/"},
+		[]string{},
+		[]string{},
+	},
+	{
+		"Can keep file which is excluded by ignore file now",
+		map[string]string{"a.txt": "a", "d/e.txt": "e"},
+		map[string]string{"d/" + filter.IgnoreFileName: "e.txt\n"},
+		[]string{},
+		[]string{},
+		[]string{"src/d/" + filter.IgnoreFileName},
+		[]string{},
+	},
+}
+
+func TestOperations_UpdateIncremental(t *testing.T) {
+	for _, tt := range updateIncrementalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, tt.files); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(NewWalkSource(dir, "src", nil), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := writeTestFiles(src, tt.changes); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tt.remove {
+				if err := os.Remove(filepath.Join(src, filepath.FromSlash(name))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rules, err := filter.NewRules([]string{}, tt.excludes, []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hdrs, err := to.ops.UpdateIncrementalContext(
+				context.Background(),
+				"src",
+				NewWalkSource(dir, "src", rules),
+				NewWalkFilter(dir, "src", rules),
+				config.CompressionLevelFastestKey,
+				false,
+				"",
+			)
+			if err != nil {
+				t.Errorf("UpdateIncrementalContext() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			archived := []string{}
+			deleted := []string{}
+			for _, hdr := range hdrs {
+				if hdr.PAXRecords[records.STFSRecordAction] == records.STFSRecordActionDelete {
+					deleted = append(deleted, hdr.Name)
+				} else if hdr.Typeflag == tar.TypeReg {
+					archived = append(archived, hdr.Name)
+				}
+			}
+			sort.Strings(archived)
+			sort.Strings(deleted)
+
+			if !reflect.DeepEqual(archived, tt.wantArchived) {
+				t.Errorf("UpdateIncrementalContext() archived = %v, want %v", archived, tt.wantArchived)
+			}
+
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("UpdateIncrementalContext() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+
+			for _, name := range tt.remove {
+				if _, err := to.ops.metadata.Metadata.GetHeader(context.Background(), "src/"+name); err == nil {
+					t.Errorf("UpdateIncrementalContext() kept deleted file %v in the index", name)
+				}
+			}
+
+			for name := range tt.files {
+				if contains(tt.remove, name) {
+					continue
+				}
+
+				if _, err := to.ops.metadata.Metadata.GetHeader(context.Background(), "src/"+name); err != nil {
+					t.Errorf("UpdateIncrementalContext() removed file %v from the index: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,6 +2,7 @@ package operations
 
 import (
 	"archive/tar"
+	"context"
 	"io"
 	"os"
 	"os/user"
@@ -16,6 +17,16 @@ func (o *Operations) Initialize(
 	name string,
 	perm os.FileMode,
 	compressionLevel string,
+) error {
+	return o.InitializeContext(context.Background(), name, perm, compressionLevel)
+}
+
+// InitializeContext is like Initialize, but doesn't initialize the tape or tar file if ctx has been cancelled
+func (o *Operations) InitializeContext(
+	ctx context.Context,
+	name string,
+	perm os.FileMode,
+	compressionLevel string,
 ) error {
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
@@ -65,6 +76,7 @@ func (o *Operations) Initialize(
 
 	done := false
 	if _, err := o.archive(
+		ctx,
 		func() (config.FileConfig, error) {
 			// Exit after the first write
 			if done {
@@ -82,6 +94,7 @@ func (o *Operations) Initialize(
 		compressionLevel,
 		true,
 		true,
+		"",
 	); err != nil {
 		return err
 	}
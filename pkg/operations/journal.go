@@ -0,0 +1,158 @@
+package operations
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/recovery"
+)
+
+// recoverJournal indexes the headers of an unfinished session, i.e. if the process died after writing them but before indexing them,
+// so that the index matches the tape or tar file again before anything is appended to it
+func (o *Operations) recoverJournal() error {
+	if o.metadata.Journal == nil {
+		return nil
+	}
+
+	session, err := o.metadata.Journal.Get()
+	if err != nil {
+		return err
+	}
+
+	if session == nil {
+		return nil
+	}
+
+	hdrs := []*tar.Header{}
+	for _, dbhdr := range session.Headers {
+		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+		if err != nil {
+			return err
+		}
+
+		hdrs = append(hdrs, hdr)
+	}
+
+	if o.backend.ChangeVolume != nil {
+		if err := o.backend.ChangeVolume(int(session.Volume)); err != nil {
+			return err
+		}
+	} else if session.Volume != 0 {
+		return config.ErrVolumeChangeUnsupported
+	}
+
+	reader, err := o.backend.GetReader()
+	if err != nil {
+		return err
+	}
+	defer o.backend.CloseReader()
+
+	// Cut off the file which was being written when the process died so that the next write continues after the last complete one
+	if reader.DriveIsRegular && o.pipes.ParityShards <= 0 && o.backend.TruncateVolume != nil {
+		end, err := getCompleteEnd(reader, o.pipes.RecordSize, session.Record, session.Block)
+		if err != nil {
+			return err
+		}
+
+		if err := o.backend.TruncateVolume(end); err != nil {
+			return err
+		}
+	}
+
+	// Only the headers which have reached the tape or tar file are indexed
+	if err := recovery.Index(
+		reader,
+		o.backend.MagneticTapeIO,
+		o.metadata,
+		o.pipes,
+		o.crypto,
+
+		int(session.Record),
+		int(session.Block),
+		session.Overwrite,
+		session.Initializing,
+		int(session.Offset),
+
+		func(hdr *tar.Header, i int) error {
+			if len(hdrs) <= i {
+				return config.ErrTarHeaderMissing
+			}
+
+			*hdr = *hdrs[i]
+
+			return nil
+		},
+		func(hdr *tar.Header, isRegular bool) error {
+			return nil // The headers have been signed before writing them, no need to verify
+		},
+
+		func(hdr *config.Header) {
+			if o.onHeader != nil {
+				o.onHeader(&config.HeaderEvent{
+					Type:    config.HeaderEventTypeRecover,
+					Indexed: true,
+					Header:  hdr,
+				})
+			}
+		},
+		nil,
+		nil,
+	); err != nil {
+		return err
+	}
+
+	return o.metadata.Journal.Commit()
+}
+
+// getCompleteEnd returns the position after the last complete file in a tar file, starting at record and block
+func getCompleteEnd(reader config.DriveReaderConfig, recordSize int, record, block int64) (int64, error) {
+	end, err := reader.Drive.Seek((int64(recordSize)*record+block)*config.MagneticTapeBlockSize, io.SeekStart)
+	if err != nil {
+		return -1, err
+	}
+
+	tr := tar.NewReader(reader.Drive)
+	for {
+		prev, err := reader.Drive.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1, err
+		}
+
+		if _, err := tr.Next(); err != nil {
+			if err != io.EOF {
+				break
+			}
+
+			// Skip over the trailers between sessions
+			curr, err := reader.Drive.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return -1, err
+			}
+
+			if curr == prev {
+				break
+			}
+
+			tr = tar.NewReader(reader.Drive)
+
+			continue
+		}
+
+		if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+			break
+		}
+
+		curr, err := reader.Drive.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1, err
+		}
+
+		// Files are padded to the next block
+		end = ((curr + config.MagneticTapeBlockSize - 1) / config.MagneticTapeBlockSize) * config.MagneticTapeBlockSize
+	}
+
+	return end, nil
+}
@@ -0,0 +1,262 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var errSourceFailed = errors.New("source failed")
+
+var recoverJournalTests = []struct {
+	name     string
+	crashed  map[string]string // Written by a process which died before indexing them
+	archived map[string]string // Archived by the next process
+	want     map[string]string
+}{
+	{
+		"Can recover without unfinished write",
+		map[string]string{},
+		map[string]string{"c.txt": "c"},
+		map[string]string{"c.txt": "c"},
+	},
+	{
+		"Can recover headers of unfinished write",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{"c.txt": "c"},
+		map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+	},
+	{
+		"Can recover headers of unfinished write which is replaced",
+		map[string]string{"a.txt": "a", "b.txt": "b"},
+		map[string]string{"b.txt": "new"},
+		map[string]string{"a.txt": "a", "b.txt": "new"},
+	},
+}
+
+func TestOperations_RecoverJournal(t *testing.T) {
+	for _, tt := range recoverJournalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(tt.crashed) > 0 {
+				vw, err := to.ops.newVolumeWriter(context.Background(), true, false, func(hdr *config.Header) {})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				names := []string{}
+				for name := range tt.crashed {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				session := NewSession()
+				for _, name := range names {
+					hdr := &tar.Header{
+						Typeflag:   tar.TypeReg,
+						Name:       name,
+						Mode:       0600,
+						ModTime:    archivedModTime,
+						Format:     tar.FormatPAX,
+						PAXRecords: map[string]string{records.STFSRecordSession: session},
+					}
+
+					payload, err := to.ops.encodePayload(context.Background(), hdr, strings.NewReader(tt.crashed[name]), config.CompressionLevelFastestKey, vw.isRegular, payloadSpoolMemoryLimit, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if err := vw.write(hdr, payload); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				// Die without writing the trailer and indexing the headers
+				if err := to.ops.backend.CloseWriter(); err != nil {
+					t.Fatal(err)
+				}
+				vw.discard()
+
+				if _, err := os.Stat(to.metadata + ".journal"); err != nil {
+					t.Errorf("newVolumeWriter() journal error = %v, want it to be kept", err)
+				}
+			}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, tt.archived); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, len(tt.crashed) == 0, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if _, err := os.Stat(to.metadata + ".journal"); !os.IsNotExist(err) {
+				t.Errorf("Archive() journal error = %v, want it to be committed", err)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), tt.want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var archiveErrorTests = []struct {
+	name      string
+	operation string // Archive, Update or UpdateIncremental
+	files     []string
+	fail      int // Index of the file at which the source fails
+	want      map[string]string
+}{
+	{
+		"Can index files before failing source",
+		"Archive",
+		[]string{"a.txt", "b.txt", "c.txt"},
+		2,
+		map[string]string{"a.txt": "a.txt", "b.txt": "b.txt"},
+	},
+	{
+		"Can index nothing if source fails immediately",
+		"Archive",
+		[]string{"a.txt"},
+		0,
+		map[string]string{},
+	},
+	{
+		"Can index updated files before failing source",
+		"Update",
+		[]string{"a.txt", "b.txt", "c.txt"},
+		2,
+		map[string]string{"a.txt": "a.txt", "b.txt": "b.txt"},
+	},
+	{
+		"Can index incrementally updated files before failing source",
+		"UpdateIncremental",
+		[]string{"a.txt", "b.txt", "c.txt"},
+		2,
+		map[string]string{"a.txt": "a.txt", "b.txt": "b.txt"},
+	},
+	{
+		"Can index nothing if source of incremental update fails immediately",
+		"UpdateIncremental",
+		[]string{"a.txt"},
+		0,
+		map[string]string{},
+	},
+}
+
+func TestOperations_ArchiveError(t *testing.T) {
+	for _, tt := range archiveErrorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i := 0
+			getSrc := func() (config.FileConfig, error) {
+				if i == tt.fail {
+					return config.FileConfig{}, errSourceFailed
+				}
+
+				if i >= len(tt.files) {
+					return config.FileConfig{}, io.EOF
+				}
+
+				name := tt.files[i]
+				i++
+
+				return NewStreamFileConfig(name, 0600, archivedModTime, func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(name)), nil
+				}), nil
+			}
+
+			var hdrs []*tar.Header
+			switch tt.operation {
+			case "Update":
+				// Only files which have been archived before can be updated
+				j := 0
+				if _, err := to.ops.Archive(func() (config.FileConfig, error) {
+					if j >= len(tt.files) {
+						return config.FileConfig{}, io.EOF
+					}
+
+					name := tt.files[j]
+					j++
+
+					return NewStreamFileConfig(name, 0600, archivedModTime, func() (io.ReadCloser, error) {
+						return io.NopCloser(strings.NewReader("old")), nil
+					}), nil
+				}, config.CompressionLevelFastestKey, true, false); err != nil {
+					t.Fatal(err)
+				}
+
+				hdrs, err = to.ops.Update(getSrc, config.CompressionLevelFastestKey, true, false)
+			case "UpdateIncremental":
+				// Initialize the archive like the CLI does, so that there is something to update
+				if err := to.ops.Initialize("/", os.ModePerm, config.CompressionLevelFastestKey); err != nil {
+					t.Fatal(err)
+				}
+
+				hdrs, err = to.ops.UpdateIncremental(".", getSrc, nil, config.CompressionLevelFastestKey, false, "")
+			default:
+				hdrs, err = to.ops.Archive(getSrc, config.CompressionLevelFastestKey, true, false)
+			}
+
+			if err != errSourceFailed {
+				t.Errorf("%v() error = %v, want %v", tt.operation, err, errSourceFailed)
+
+				return
+			}
+
+			// The headers which have been written are returned together with the error
+			if len(hdrs) != len(tt.want) {
+				t.Errorf("%v() headers = %v, want %v", tt.operation, len(hdrs), len(tt.want))
+			}
+
+			// The journal is committed once the files which have been written are indexed
+			if _, err := os.Stat(to.metadata + ".journal"); !os.IsNotExist(err) {
+				t.Errorf("%v() journal error = %v, want it to be committed", tt.operation, err)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), tt.want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -10,14 +10,15 @@ import (
 
 	"github.com/pojntfx/stfs/internal/converters"
 	"github.com/pojntfx/stfs/internal/records"
-	"github.com/pojntfx/stfs/internal/tarext"
 	"github.com/pojntfx/stfs/pkg/config"
-	"github.com/pojntfx/stfs/pkg/encryption"
-	"github.com/pojntfx/stfs/pkg/recovery"
-	"github.com/pojntfx/stfs/pkg/signature"
 )
 
 func (o *Operations) Move(from string, to string) error {
+	return o.MoveContext(context.Background(), from, to)
+}
+
+// MoveContext is like Move, but can be cancelled with ctx until the move headers are written; they are always written completely
+func (o *Operations) MoveContext(ctx context.Context, from string, to string) error {
 	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
 
 	// Ignore no-op move operation
@@ -28,27 +29,23 @@ func (o *Operations) Move(from string, to string) error {
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
 
-	writer, err := o.backend.GetWriter()
-	if err != nil {
-		return err
-	}
-
-	dirty := false
-	tw, cleanup, err := tarext.NewTapeWriter(writer.Drive, writer.DriveIsRegular, o.pipes.RecordSize)
-	if err != nil {
-		return err
-	}
-
-	lastIndexedRecord, lastIndexedBlock, err := o.metadata.Metadata.GetLastIndexedRecordAndBlock(context.Background(), o.pipes.RecordSize)
+	vw, err := o.newVolumeWriter(ctx, false, false, func(hdr *config.Header) {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeMove,
+			Indexed: true,
+			Header:  hdr,
+		})
+	})
 	if err != nil {
 		return err
 	}
+	defer vw.discard()
 
 	headersToMove := []*config.Header{}
-	dbhdr, err := o.metadata.Metadata.GetHeader(context.Background(), from)
+	dbhdr, err := o.metadata.Metadata.GetHeader(ctx, from)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			dbhdr, err = o.metadata.Metadata.GetHeaderByLinkname(context.Background(), from)
+			dbhdr, err = o.metadata.Metadata.GetHeaderByLinkname(ctx, from)
 			if err != nil {
 				return err
 			}
@@ -70,7 +67,7 @@ func (o *Operations) Move(from string, to string) error {
 
 	// If the header refers to a directory, get it's children
 	if dbhdr.Typeflag == tar.TypeDir {
-		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(context.Background(), from)
+		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(ctx, from)
 		if err != nil {
 			return err
 		}
@@ -78,8 +75,12 @@ func (o *Operations) Move(from string, to string) error {
 		headersToMove = append(headersToMove, dbhdrs...)
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Append move headers to the tape or tar file
-	hdrs := []tar.Header{}
+	session := NewSession()
 	for _, dbhdr := range headersToMove {
 		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
 		if err != nil {
@@ -91,8 +92,7 @@ func (o *Operations) Move(from string, to string) error {
 		hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
 		hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionUpdate
 		hdr.PAXRecords[records.STFSRecordReplacesName] = dbhdr.Name
-
-		hdrs = append(hdrs, *hdr)
+		hdr.PAXRecords[records.STFSRecordSession] = session
 
 		if o.onHeader != nil {
 			dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
@@ -107,67 +107,10 @@ func (o *Operations) Move(from string, to string) error {
 			})
 		}
 
-		if err := signature.SignHeader(hdr, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity); err != nil {
+		if err := vw.write(hdr, nil); err != nil {
 			return err
 		}
-
-		if err := encryption.EncryptHeader(hdr, o.pipes.Encryption, o.crypto.Recipient); err != nil {
-			return err
-		}
-
-		if err := tw.WriteHeader(hdr); err != nil {
-			return err
-		}
-
-		dirty = true
-	}
-
-	if err := cleanup(&dirty); err != nil {
-		return err
-	}
-
-	if err := o.backend.CloseWriter(); err != nil {
-		return err
-	}
-
-	reader, err := o.backend.GetReader()
-	if err != nil {
-		return err
 	}
-	defer o.backend.CloseReader()
-
-	return recovery.Index(
-		reader,
-		o.backend.MagneticTapeIO,
-		o.metadata,
-		o.pipes,
-		o.crypto,
-
-		int(lastIndexedRecord),
-		int(lastIndexedBlock),
-		false,
-		false,
-		1, // Ignore the first header, which is the last header which we already indexed
-
-		func(hdr *tar.Header, i int) error {
-			if len(hdrs) <= i {
-				return config.ErrTarHeaderMissing
-			}
-
-			*hdr = hdrs[i]
 
-			return nil
-		},
-		func(hdr *tar.Header, isRegular bool) error {
-			return nil // We sign above, no need to verify
-		},
-
-		func(hdr *config.Header) {
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeMove,
-				Indexed: true,
-				Header:  hdr,
-			})
-		},
-	)
+	return vw.close()
 }
@@ -13,7 +13,10 @@ type Operations struct {
 	pipes  config.PipeConfig
 	crypto config.CryptoConfig
 
-	onHeader func(event *config.HeaderEvent)
+	onHeader   func(event *config.HeaderEvent)
+	onProgress func(event *config.ProgressEvent)
+
+	dryRun bool
 
 	diskOperationLock sync.Mutex
 }
@@ -26,6 +29,9 @@ func NewOperations(
 	crypto config.CryptoConfig,
 
 	onHeader func(event *config.HeaderEvent),
+	onProgress func(event *config.ProgressEvent),
+
+	dryRun bool, // Report the headers which would be written and indexed instead of writing and indexing them
 ) *Operations {
 	return &Operations{
 		backend:  backend,
@@ -34,7 +40,10 @@ func NewOperations(
 		pipes:  pipes,
 		crypto: crypto,
 
-		onHeader: onHeader,
+		onHeader:   onHeader,
+		onProgress: onProgress,
+
+		dryRun: dryRun,
 	}
 }
 
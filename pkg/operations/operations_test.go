@@ -0,0 +1,167 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/mtio"
+	"github.com/pojntfx/stfs/pkg/persisters"
+	"github.com/pojntfx/stfs/pkg/sinks"
+	"github.com/pojntfx/stfs/pkg/tape"
+)
+
+const (
+	recordSize = 20
+)
+
+type testOperations struct {
+	ops *Operations
+
+	drive    string
+	metadata string
+	tm       *tape.TapeManager
+}
+
+func createOperations(dir string, pipes config.PipeConfig, onProgress func(event *config.ProgressEvent)) (*testOperations, error) {
+	drive := filepath.Join(dir, "drive.tar")
+	metadata := filepath.Join(dir, "metadata.sqlite")
+
+	mt := mtio.MagneticTapeIO{}
+	tm := tape.NewTapeManager(
+		drive,
+		mt,
+		recordSize,
+		false,
+	)
+
+	metadataPersister := persisters.NewMetadataPersister(metadata)
+	if err := metadataPersister.Open(); err != nil {
+		return nil, err
+	}
+
+	pipes.RecordSize = recordSize
+
+	return &testOperations{
+		ops: NewOperations(
+			config.BackendConfig{
+				GetWriter:   tm.GetWriter,
+				CloseWriter: tm.Close,
+
+				GetReader:   tm.GetReader,
+				CloseReader: tm.Close,
+
+				ChangeVolume:   tm.ChangeVolume,
+				TruncateVolume: tm.TruncateVolume,
+
+				MagneticTapeIO: mt,
+			},
+			config.MetadataConfig{
+				Metadata: metadataPersister,
+				Journal:  persisters.NewJournalPersister(metadata + ".journal"),
+			},
+
+			pipes,
+			config.CryptoConfig{},
+
+			func(event *config.HeaderEvent) {},
+			onProgress,
+
+			false,
+		),
+
+		drive:    drive,
+		metadata: metadata,
+		tm:       tm,
+	}, nil
+}
+
+// newTestSource walks root like NewWalkSource, but archives the files with their paths relative to root
+func newTestSource(root string) func() (config.FileConfig, error) {
+	walk := NewWalkSource(root, ".", nil)
+
+	return func() (config.FileConfig, error) {
+		for {
+			file, err := walk()
+			if err != nil || file.Path != "." {
+				return file, err
+			}
+		}
+	}
+}
+
+// writeTestFiles creates the files with their content in dir
+func writeTestFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readTestFiles reads the content of the files in dir
+func readTestFiles(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	if err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(rel)] = string(content)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// restoreTestFiles restores the files to their paths in dst and reads them
+func restoreTestFiles(ops *Operations, dst string, files map[string]string) (map[string]string, error) {
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	for name := range files {
+		// Files are restored into the directory which they are restored to
+		to := filepath.Join(dst, filepath.Dir(filepath.FromSlash(name)))
+		if err := os.MkdirAll(to, os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		if err := ops.Restore(sinks.NewFilesystemSink(false, nil, nil, false), name, to, false, config.ConflictPolicyOverwrite, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return readTestFiles(dst)
+}
@@ -0,0 +1,212 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/sparse"
+	"github.com/pojntfx/stfs/pkg/compression"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/encryption"
+	"github.com/pojntfx/stfs/pkg/signature"
+)
+
+const (
+	payloadSpoolMemoryLimit = 32 * 1024 * 1024 // Larger payloads are spooled to a temporary file
+)
+
+func (o *Operations) encodePayload(
+	ctx context.Context,
+	hdr *tar.Header,
+	src io.Reader,
+	compressionLevel string,
+	isRegular bool,
+	memoryLimit int,
+	tracker *progress.Tracker,
+) (*ioext.SpoolWriter, error) {
+	// Sign, compress and encrypt in a single pass; the spool gives us the size for the header
+	hasher := sha256.New()
+
+	// Only store the data regions of sparse files; the holes are hashed as zeros
+	size := int64(-1)
+	regions := []sparse.Region{}
+	if f, ok := src.(*os.File); ok {
+		stat, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+
+		if stat.Mode().IsRegular() {
+			regions, err = sparse.GetDataRegions(f, stat.Size())
+			if err != nil {
+				return nil, err
+			}
+
+			if sparse.IsSparse(regions, stat.Size()) {
+				size = stat.Size()
+				src = sparse.NewReader(f, regions, size, hasher)
+			}
+		}
+	}
+
+	counter := &ioext.CounterReader{Reader: io.TeeReader(tracker.Reader(&ioext.ContextReader{Context: ctx, Reader: src}), hasher)}
+	payload := ioext.NewSpoolWriter(memoryLimit, "")
+
+	encryptor, err := encryption.Encrypt(payload, o.pipes.Encryption, o.crypto.Recipient)
+	if err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	compressor, err := compression.Compress(
+		encryptor,
+		o.pipes.Compression,
+		compressionLevel,
+		isRegular,
+		o.pipes.RecordSize,
+	)
+	if err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	signer, sign, err := signature.Sign(counter, isRegular, o.pipes.Signature, o.crypto.Identity)
+	if err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if err := copyWithRecordSize(compressor, signer, isRegular, o.pipes.RecordSize); err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if err := compressor.Flush(); err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if err := compressor.Close(); err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if err := encryptor.Close(); err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	sig, err := sign()
+	if err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if hdr.PAXRecords == nil {
+		hdr.PAXRecords = map[string]string{}
+	}
+	hdr.PAXRecords[records.STFSRecordUncompressedSize] = strconv.Itoa(counter.BytesRead)
+	if size >= 0 {
+		hdr.PAXRecords[records.STFSRecordUncompressedSize] = strconv.FormatInt(size, 10)
+		hdr.PAXRecords[records.STFSRecordSparseMap] = sparse.EncodeMap(regions)
+	}
+	hdr.PAXRecords[records.STFSRecordHash] = hex.EncodeToString(hasher.Sum(nil))
+	if sig != "" {
+		hdr.PAXRecords[records.STFSRecordSignature] = sig
+	}
+	hdr.Size = payload.Size()
+
+	return payload, nil
+}
+
+func (o *Operations) deduplicatePayload(hdr *tar.Header, payload *ioext.SpoolWriter) (*ioext.SpoolWriter, error) {
+	referenced, err := o.referenceContent(hdr)
+	if err != nil {
+		_ = payload.Close()
+
+		return nil, err
+	}
+
+	if !referenced {
+		return payload, nil
+	}
+
+	return nil, payload.Close()
+}
+
+// referenceContent makes hdr reference already archived content with the same hash if there is any
+func (o *Operations) referenceContent(hdr *tar.Header) (bool, error) {
+	dbhdr, err := o.metadata.Metadata.GetHeaderByHash(context.Background(), hdr.PAXRecords[records.STFSRecordHash])
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	existingHdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+	if err != nil {
+		return false, err
+	}
+
+	// Reference the volume, record and block with the content instead of another reference
+	volume, record, block := strconv.Itoa(int(dbhdr.Volume)), strconv.Itoa(int(dbhdr.Record)), strconv.Itoa(int(dbhdr.Block))
+	if referencedRecord, ok := existingHdr.PAXRecords[records.STFSRecordReferencesRecord]; ok {
+		volume, record, block = "0", referencedRecord, existingHdr.PAXRecords[records.STFSRecordReferencesBlock]
+		if referencedVolume, ok := existingHdr.PAXRecords[records.STFSRecordReferencesVolume]; ok {
+			volume = referencedVolume
+		}
+	}
+
+	setReference(hdr, volume, record, block)
+
+	return true, nil
+}
+
+// setReference makes hdr reference the content of the header at the volume, record and block instead of storing it
+func setReference(hdr *tar.Header, volume, record, block string) {
+	// The referenced payload has its own sparse map
+	delete(hdr.PAXRecords, records.STFSRecordSparseMap)
+
+	hdr.PAXRecords[records.STFSRecordReferencesVolume] = volume
+	hdr.PAXRecords[records.STFSRecordReferencesRecord] = record
+	hdr.PAXRecords[records.STFSRecordReferencesBlock] = block
+	hdr.Size = 0
+}
+
+func copyWithRecordSize(dst io.Writer, src io.Reader, isRegular bool, recordSize int) error {
+	if isRegular {
+		if _, err := io.Copy(dst, src); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	// Hide io.WriterTo, which would write the payload without the buffer and therefore in one record
+	buf := make([]byte, config.MagneticTapeBlockSize*recordSize)
+	if _, err := io.CopyBuffer(dst, struct{ io.Reader }{src}, buf); err != nil {
+		return err
+	}
+
+	return nil
+}
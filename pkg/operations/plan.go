@@ -0,0 +1,311 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+const (
+	maxPassGap            = 64 * 1024 * 1024 // Files which are closer to each other than this are read in one pass, since reading over the gap is faster than repositioning a tape
+	estimatedHeaderBlocks = 3                // PAX header, PAX records and the header itself
+)
+
+// PlanRestore returns the order in which RestoreMany would restore the files and directories and how far the tape would move for it, without reading the tape or tar file
+func (o *Operations) PlanRestore(ctx context.Context, from []string, to string, flatten bool) (*config.RestorePlan, error) {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	return o.planRestore(ctx, from, to, flatten)
+}
+
+func (o *Operations) planRestore(ctx context.Context, from []string, to string, flatten bool) (*config.RestorePlan, error) {
+	to = filepath.ToSlash(to)
+
+	plan := &config.RestorePlan{
+		Passes: []*config.RestorePass{},
+		Links:  []*config.RestorePlanEntry{},
+	}
+
+	// Collect the entries in the order of the index, which is the order in which they would be restored without a plan
+	entries := []*config.RestorePlanEntry{}
+	hardlinks := []*config.RestorePlanEntry{}
+	restored := map[string]*config.RestorePlanEntry{}
+	seen := map[string]struct{}{}
+	for _, src := range from {
+		src = filepath.ToSlash(src)
+
+		dbhdrs, err := o.getHeadersToRestore(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dbhdr := range dbhdrs {
+			// Paths can be given several times or be nested in each other
+			if _, ok := seen[dbhdr.Name]; ok {
+				continue
+			}
+			seen[dbhdr.Name] = struct{}{}
+
+			plan.Files++
+			if dbhdr.Typeflag == tar.TypeReg {
+				plan.Bytes += dbhdr.Size
+			}
+
+			entry := &config.RestorePlanEntry{
+				Header:  dbhdr,
+				Content: dbhdr,
+				To:      getRestorePath(dbhdr, src, to, flatten),
+			}
+
+			if dbhdr.Typeflag == tar.TypeLink {
+				hardlinks = append(hardlinks, entry)
+
+				continue
+			}
+
+			entries = append(entries, entry)
+			restored[dbhdr.Name] = entry
+		}
+	}
+
+	for _, entry := range hardlinks {
+		if target, ok := restored[entry.Header.Linkname]; ok {
+			entry.Content = target.Content
+			entry.Linkname = target.To
+
+			plan.Links = append(plan.Links, entry)
+
+			continue
+		}
+
+		// The file which the hard link points to isn't restored, so restore its content instead
+		target, err := o.metadata.Metadata.ResolveHardlink(ctx, entry.Header.Linkname)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Content = target
+		entries = append(entries, entry)
+	}
+
+	unplanned := newTapeHead(o.pipes.RecordSize, &plan.UnplannedMovement)
+	for _, entry := range entries {
+		if err := unplanned.fetch(entry, true); err != nil {
+			return nil, err
+		}
+	}
+
+	// Passes read the headers in order, so deduplicated files are sorted by their own header and the content they reference is seeked to, see tapeHead.fetch
+	sorted := append([]*config.RestorePlanEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Content, sorted[j].Content
+		if a.Volume != b.Volume {
+			return a.Volume < b.Volume
+		}
+
+		if a.Record != b.Record {
+			return a.Record < b.Record
+		}
+
+		return a.Block < b.Block
+	})
+
+	var (
+		pass    *config.RestorePass
+		prev    *config.RestorePlanEntry
+		prevEnd int64
+	)
+	for _, entry := range sorted {
+		// Content which is restored twice, i.e. for two hard links to a file which isn't restored, is only read once
+		if prev != nil && isSamePosition(prev.Content, entry.Content) {
+			if entry.Header.Typeflag == tar.TypeLink {
+				entry.Linkname = prev.To
+			}
+
+			plan.Links = append(plan.Links, entry)
+
+			continue
+		}
+		prev = entry
+
+		start := getBlock(entry.Content, o.pipes.RecordSize)
+		if pass == nil || pass.Volume != entry.Content.Volume || start-prevEnd > maxPassGap/config.MagneticTapeBlockSize {
+			pass = &config.RestorePass{
+				Volume:      entry.Content.Volume,
+				StartRecord: entry.Content.Record,
+				Entries:     []*config.RestorePlanEntry{},
+			}
+			plan.Passes = append(plan.Passes, pass)
+
+			prevEnd = start
+		}
+
+		pass.Entries = append(pass.Entries, entry)
+
+		if end := start + getEstimatedBlocks(entry.Content); end > prevEnd {
+			prevEnd = end
+		}
+		pass.EndRecord = divCeil(prevEnd, int64(o.pipes.RecordSize))
+	}
+
+	planned := newTapeHead(o.pipes.RecordSize, &plan.Movement)
+	for _, pass := range plan.Passes {
+		planned.seek(pass.Volume, pass.StartRecord)
+
+		for _, entry := range pass.Entries {
+			if err := planned.fetch(entry, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// tapeHead follows the position of the tape to estimate how far it moves
+type tapeHead struct {
+	recordSize int
+	movement   *config.TapeMovement
+
+	loaded bool
+	volume int64
+	record int64
+}
+
+func newTapeHead(recordSize int, movement *config.TapeMovement) *tapeHead {
+	return &tapeHead{
+		recordSize: recordSize,
+		movement:   movement,
+	}
+}
+
+func (h *tapeHead) seek(volume, record int64) {
+	if !h.loaded || h.volume != volume {
+		if h.loaded {
+			h.movement.VolumeChanges++
+		}
+
+		h.loaded = true
+		h.volume = volume
+		h.record = 0
+	}
+
+	if h.record == record {
+		return
+	}
+
+	h.movement.Seeks++
+	if record > h.record {
+		h.movement.SeekRecords += record - h.record
+	} else {
+		h.movement.SeekRecords += h.record - record
+	}
+
+	h.record = record
+}
+
+// readTo reads forward until record, which is how the files between the entries of a pass are skipped
+func (h *tapeHead) readTo(record int64) {
+	if record > h.record {
+		h.movement.ReadRecords += record - h.record
+
+		h.record = record
+	}
+}
+
+// fetch moves over the content of an entry; without a plan, every entry is seeked to
+func (h *tapeHead) fetch(entry *config.RestorePlanEntry, seek bool) error {
+	hdr := entry.Content
+	if seek {
+		h.seek(hdr.Volume, hdr.Record)
+	}
+
+	volume, record, block, ok, err := getReference(hdr)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		h.readTo(divCeil(getBlock(hdr, h.recordSize)+getEstimatedBlocks(hdr), int64(h.recordSize)))
+
+		return nil
+	}
+
+	// Deduplicated content is read from the file it references
+	h.readTo(divCeil(getBlock(hdr, h.recordSize)+estimatedHeaderBlocks, int64(h.recordSize)))
+	after := h.record
+
+	h.seek(volume, record)
+	h.readTo(divCeil(record*int64(h.recordSize)+block+estimatedHeaderBlocks+divCeil(hdr.Size, config.MagneticTapeBlockSize), int64(h.recordSize)))
+
+	if !seek {
+		h.seek(hdr.Volume, after)
+	}
+
+	return nil
+}
+
+// getReference returns the position of the deduplicated content which hdr references, if it references any
+func getReference(hdr *config.Header) (int64, int64, int64, bool, error) {
+	if hdr.Paxrecords == "" {
+		return -1, -1, -1, false, nil
+	}
+
+	tarHdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(hdr))
+	if err != nil {
+		return -1, -1, -1, false, err
+	}
+
+	referencedRecord, ok := tarHdr.PAXRecords[records.STFSRecordReferencesRecord]
+	if !ok {
+		return -1, -1, -1, false, nil
+	}
+
+	record, err := strconv.ParseInt(referencedRecord, 10, 64)
+	if err != nil {
+		return -1, -1, -1, false, err
+	}
+
+	block, err := strconv.ParseInt(tarHdr.PAXRecords[records.STFSRecordReferencesBlock], 10, 64)
+	if err != nil {
+		return -1, -1, -1, false, err
+	}
+
+	volume := hdr.Volume
+	if referencedVolume, ok := tarHdr.PAXRecords[records.STFSRecordReferencesVolume]; ok {
+		volume, err = strconv.ParseInt(referencedVolume, 10, 64)
+		if err != nil {
+			return -1, -1, -1, false, err
+		}
+	}
+
+	return volume, record, block, true, nil
+}
+
+func isSamePosition(a, b *config.Header) bool {
+	return a.Volume == b.Volume && a.Record == b.Record && a.Block == b.Block
+}
+
+func getBlock(hdr *config.Header, recordSize int) int64 {
+	return hdr.Record*int64(recordSize) + hdr.Block
+}
+
+func divCeil(n, d int64) int64 {
+	return (n + d - 1) / d
+}
+
+// getEstimatedBlocks returns the amount of blocks of a file on the tape or tar file; the uncompressed size is used since the compressed one isn't indexed
+func getEstimatedBlocks(hdr *config.Header) int64 {
+	if _, _, _, ok, err := getReference(hdr); err == nil && ok {
+		return estimatedHeaderBlocks
+	}
+
+	return estimatedHeaderBlocks + divCeil(hdr.Size, config.MagneticTapeBlockSize)
+}
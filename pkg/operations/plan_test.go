@@ -0,0 +1,339 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/recovery"
+	"github.com/pojntfx/stfs/pkg/sinks"
+	"github.com/pojntfx/stfs/pkg/tape"
+)
+
+var planRestoreTests = []struct {
+	name      string
+	runs      []map[string]string
+	from      []string
+	wantOrder []string
+	want      map[string]string
+}{
+	{
+		"Can order files by their position",
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+		},
+		[]string{"c.txt", "a.txt", "b.txt"},
+		[]string{"a.txt", "b.txt", "c.txt"},
+		map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+	},
+	{
+		"Can order replaced file after files of previous run",
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+			{"a.txt": "new"},
+		},
+		[]string{"a.txt", "b.txt", "c.txt"},
+		[]string{"b.txt", "c.txt", "a.txt"},
+		map[string]string{"a.txt": "new", "b.txt": "b", "c.txt": "c"},
+	},
+	{
+		"Can restore path which is given several times once",
+		[]map[string]string{
+			{"a.txt": "a", "b.txt": "b"},
+		},
+		[]string{"b.txt", "b.txt"},
+		[]string{"b.txt"},
+		map[string]string{"b.txt": "b"},
+	},
+	{
+		"Can restore directory and file in it once",
+		[]map[string]string{
+			{"a.txt": "a", "d/b.txt": "b", "d/c.txt": "c"},
+		},
+		[]string{"d/c.txt", "d"},
+		[]string{"d", "d/b.txt", "d/c.txt"},
+		map[string]string{"b.txt": "b", "c.txt": "c"}, // Paths are restored relative to the path which is given
+	},
+}
+
+func TestOperations_PlanRestore(t *testing.T) {
+	for _, tt := range planRestoreTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dst := filepath.Join(dir, "dst")
+
+			plan, err := to.ops.PlanRestore(context.Background(), tt.from, dst, false)
+			if err != nil {
+				t.Errorf("PlanRestore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			order := []string{}
+			for _, pass := range plan.Passes {
+				for _, entry := range pass.Entries {
+					order = append(order, entry.Header.Name)
+				}
+			}
+
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("PlanRestore() order = %v, want %v", order, tt.wantOrder)
+			}
+
+			if len(plan.Passes) != 1 {
+				t.Errorf("PlanRestore() passes = %v, want %v", len(plan.Passes), 1)
+			}
+
+			if plan.Files != int64(len(tt.wantOrder)) {
+				t.Errorf("PlanRestore() files = %v, want %v", plan.Files, len(tt.wantOrder))
+			}
+
+			if plan.Movement.Seeks > plan.UnplannedMovement.Seeks {
+				t.Errorf("PlanRestore() seeks = %v, want at most %v", plan.Movement.Seeks, plan.UnplannedMovement.Seeks)
+			}
+
+			if err := to.ops.RestoreMany(sinks.NewFilesystemSink(false, nil, nil, false), tt.from, dst, false, config.ConflictPolicyOverwrite, nil); err != nil {
+				t.Errorf("RestoreMany() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestoreMany() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var fetchPassTests = []struct {
+	name    string
+	change  func(entries []*config.RestorePlanEntry) []*config.RestorePlanEntry
+	want    map[string]string
+	wantErr error
+}{
+	{
+		"Can fetch entries in one pass",
+		func(entries []*config.RestorePlanEntry) []*config.RestorePlanEntry {
+			return entries
+		},
+		map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"},
+		nil,
+	},
+	{
+		"Can fetch entries with gap",
+		func(entries []*config.RestorePlanEntry) []*config.RestorePlanEntry {
+			return []*config.RestorePlanEntry{entries[0], entries[2]}
+		},
+		map[string]string{"a.txt": "a", "c.txt": "c"},
+		nil,
+	},
+	{
+		"Can not fetch entries which aren't sorted by their position",
+		func(entries []*config.RestorePlanEntry) []*config.RestorePlanEntry {
+			return []*config.RestorePlanEntry{entries[2], entries[0]}
+		},
+		map[string]string{"c.txt": "c"},
+		config.ErrTarHeaderMissing,
+	},
+	{
+		"Can not fetch entry which isn't at the start of a header",
+		func(entries []*config.RestorePlanEntry) []*config.RestorePlanEntry {
+			content := *entries[1].Content
+			content.Block++
+
+			return []*config.RestorePlanEntry{entries[0], {Header: entries[1].Header, Content: &content, To: entries[1].To}}
+		},
+		map[string]string{"a.txt": "a"},
+		config.ErrTarHeaderMissing,
+	},
+}
+
+func TestFetchPassContext(t *testing.T) {
+	for _, tt := range fetchPassTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"}); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+
+			plan, err := to.ops.PlanRestore(context.Background(), []string{"a.txt", "b.txt", "c.txt"}, dst, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := to.tm.GetReader()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer to.tm.Close()
+
+			err = recovery.FetchPassContext(
+				context.Background(),
+				reader,
+				to.ops.backend.MagneticTapeIO,
+				nil,
+				to.ops.pipes,
+				to.ops.crypto,
+
+				sinks.NewFilesystemSink(false, nil, nil, false),
+
+				tt.change(plan.Passes[0].Entries),
+				config.ConflictPolicyOverwrite,
+
+				nil,
+				nil,
+				nil,
+				nil,
+			)
+			if err != tt.wantErr {
+				t.Errorf("FetchPassContext() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FetchPassContext() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var restoreManyVolumesTests = []struct {
+	name     string
+	capacity int64
+	runs     []map[string]string
+	from     []string
+	want     map[string]string
+}{
+	{
+		"Can restore files on several volumes",
+		3 * 512 * recordSize,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000)},
+			{"b.txt": getTestContent(1, 40000)},
+		},
+		[]string{"a.txt", "b.txt"},
+		map[string]string{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 40000)},
+	},
+	{
+		"Can restore file after deduplicated file which references previous volume",
+		3 * 512 * recordSize,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000)},
+			{"b.txt": getTestContent(0, 40000), "c.txt": getTestContent(1, 100)},
+		},
+		[]string{"b.txt", "c.txt"},
+		map[string]string{"b.txt": getTestContent(0, 40000), "c.txt": getTestContent(1, 100)},
+	},
+	{
+		"Can restore deduplicated files which reference previous volume",
+		3 * 512 * recordSize,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000)},
+			{"b.txt": getTestContent(0, 40000), "c.txt": getTestContent(1, 100), "d.txt": getTestContent(0, 40000)},
+		},
+		[]string{"a.txt", "b.txt", "c.txt", "d.txt"},
+		map[string]string{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(0, 40000), "c.txt": getTestContent(1, 100), "d.txt": getTestContent(0, 40000)},
+	},
+}
+
+func TestOperations_RestoreManyVolumes(t *testing.T) {
+	for _, tt := range restoreManyVolumesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			to.ops.backend.GetWriter = func() (config.DriveWriterConfig, error) {
+				writer, err := to.tm.GetWriter()
+				if err != nil {
+					return config.DriveWriterConfig{}, err
+				}
+
+				written := int64(0)
+				if info, err := os.Stat(tape.GetVolumePath(to.drive, writer.Volume)); err == nil {
+					written = info.Size()
+				}
+
+				writer.Drive = &fullWriter{w: writer.Drive, remaining: tt.capacity - written}
+
+				return writer, nil
+			}
+
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dst := filepath.Join(dir, "dst")
+
+			if err := to.ops.RestoreMany(sinks.NewFilesystemSink(false, nil, nil, false), tt.from, dst, false, config.ConflictPolicyOverwrite, nil); err != nil {
+				t.Errorf("RestoreMany() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestoreMany() got %v files, want %v files with equal content", len(got), len(tt.want))
+			}
+		})
+	}
+}
@@ -4,103 +4,353 @@ import (
 	"archive/tar"
 	"context"
 	"database/sql"
-	"io"
-	"io/fs"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/progress"
 	"github.com/pojntfx/stfs/pkg/config"
 	"github.com/pojntfx/stfs/pkg/recovery"
 )
 
 func (o *Operations) Restore(
-	getDst func(path string, mode fs.FileMode) (io.WriteCloser, error),
-	mkdirAll func(path string, mode fs.FileMode) error,
+	sink config.RestoreSink,
 
 	from string,
 	to string,
 	flatten bool,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
+) error {
+	return o.RestoreContext(
+		context.Background(),
+
+		sink,
+
+		from,
+		to,
+		flatten,
+		conflictPolicy,
+
+		onConflict,
+	)
+}
+
+// RestoreContext is like Restore, but stops once ctx has been cancelled; the files which have been restored until then are kept
+func (o *Operations) RestoreContext(
+	ctx context.Context,
+
+	sink config.RestoreSink,
+
+	from string,
+	to string,
+	flatten bool,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
+) error {
+	return o.RestoreManyContext(
+		ctx,
+
+		sink,
+
+		[]string{from},
+		to,
+		flatten,
+		conflictPolicy,
+
+		onConflict,
+	)
+}
+
+// RestoreMany is like Restore, but restores several files and directories in the order of their position on the tape or tar file, see PlanRestore
+func (o *Operations) RestoreMany(
+	sink config.RestoreSink,
+
+	from []string,
+	to string,
+	flatten bool,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
 ) error {
-	from, to = filepath.ToSlash(from), filepath.ToSlash(to)
+	return o.RestoreManyContext(
+		context.Background(),
+
+		sink,
+
+		from,
+		to,
+		flatten,
+		conflictPolicy,
+
+		onConflict,
+	)
+}
 
+// RestoreManyContext is like RestoreMany, but stops once ctx has been cancelled; the files which have been restored until then are kept.
+// Files which already exist are handled according to the conflict policy, see recovery.ResolveConflict
+func (o *Operations) RestoreManyContext(
+	ctx context.Context,
+
+	sink config.RestoreSink,
+
+	from []string,
+	to string,
+	flatten bool,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
+) error {
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
 
-	headersToRestore := []*config.Header{}
+	return o.restoreMany(ctx, sink, from, to, flatten, conflictPolicy, onConflict)
+}
+
+func (o *Operations) restoreMany(
+	ctx context.Context,
+
+	sink config.RestoreSink,
+
+	from []string,
+	to string,
+	flatten bool,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
+) error {
+	plan, err := o.planRestore(ctx, from, to, flatten)
+	if err != nil {
+		return err
+	}
+
+	tracker := progress.NewTracker(config.ProgressEventTypeRestore, plan.Files, plan.Bytes, o.onProgress)
+	defer tracker.Finish()
+
+	vr, err := o.newVolumeReader()
+	if err != nil {
+		return err
+	}
+	defer vr.close()
+
+	// Hard links have to point to the new path of files which have been renamed
+	renamed := map[string]string{}
+	handleConflict := func(event *config.ConflictEvent) {
+		if event.Type == config.ConflictEventTypeRename {
+			renamed[event.Path] = event.To
+		}
+
+		if onConflict != nil {
+			onConflict(event)
+		}
+	}
+
+	for _, pass := range plan.Passes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reader, err := vr.seek(int(pass.Volume))
+		if err != nil {
+			return err
+		}
+
+		if err := recovery.FetchPassContext(
+			ctx,
+			reader,
+			o.backend.MagneticTapeIO,
+			vr.changeVolume,
+			o.pipes,
+			o.crypto,
+
+			sink,
+
+			pass.Entries,
+			conflictPolicy,
+
+			func(entry *config.RestorePlanEntry) func(event *config.ProgressEvent) {
+				o.onRestoreHeader(entry.Header)
+
+				tracker.Start(entry.Header.Name)
+
+				return tracker.Forward()
+			},
+			func(entry *config.RestorePlanEntry) {
+				tracker.Done()
+			},
+			nil,
+			handleConflict,
+		); err != nil {
+			return err
+		}
+	}
+
+	// Hard links are restored after the files they link to, so that they can be linked instead of copied
+	for _, entry := range plan.Links {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tracker.Start(entry.Header.Name)
+
+		if entry.Linkname == "" {
+			if err := o.fetch(ctx, vr, sink, entry.Header, entry.Content, entry.To, conflictPolicy, handleConflict, tracker.Forward()); err != nil {
+				return err
+			}
+
+			tracker.Done()
+
+			continue
+		}
+
+		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(entry.Header))
+		if err != nil {
+			return err
+		}
+
+		dst, restore, err := recovery.ResolveConflict(sink, conflictPolicy, hdr, entry.To, handleConflict)
+		if err != nil {
+			return err
+		}
+
+		if !restore {
+			o.onRestoreHeader(entry.Header)
+
+			tracker.Done()
+
+			continue
+		}
+
+		linkname := entry.Linkname
+		if target, ok := renamed[linkname]; ok {
+			linkname = target
+		}
+
+		// Sinks which can't link get the content of the file instead
+		if err := sink.CreateHardlink(dst, linkname, hdr); err != nil {
+			if err != config.ErrHardlinksUnsupported {
+				return err
+			}
+
+			// The conflict has already been resolved
+			if err := o.fetch(ctx, vr, sink, entry.Header, entry.Content, dst, config.ConflictPolicyOverwrite, nil, tracker.Forward()); err != nil {
+				return err
+			}
+
+			tracker.Done()
+
+			continue
+		}
+
+		o.onRestoreHeader(entry.Header)
+
+		tracker.Done()
+	}
+
+	return nil
+}
+
+// getHeadersToRestore returns the header of from and, if it is a directory, the headers of its children
+func (o *Operations) getHeadersToRestore(ctx context.Context, from string) ([]*config.Header, error) {
 	src := strings.TrimSuffix(from, "/")
-	dbhdr, err := o.metadata.Metadata.GetHeader(context.Background(), src)
+	dbhdr, err := o.metadata.Metadata.GetHeader(ctx, src)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			src = src + "/"
 
-			dbhdr, err = o.metadata.Metadata.GetHeader(context.Background(), src)
+			dbhdr, err = o.metadata.Metadata.GetHeader(ctx, src)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		} else {
-			return err
+			return nil, err
 		}
 	}
-	headersToRestore = append(headersToRestore, dbhdr)
+	headersToRestore := []*config.Header{dbhdr}
 
 	// If the header refers to a directory, get it's children
 	if dbhdr.Typeflag == tar.TypeDir {
-		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(context.Background(), src)
+		dbhdrs, err := o.metadata.Metadata.GetHeaderChildren(ctx, src)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		headersToRestore = append(headersToRestore, dbhdrs...)
 	}
 
-	reader, err := o.backend.GetReader()
+	return headersToRestore, nil
+}
+
+func (o *Operations) onRestoreHeader(dbhdr *config.Header) {
+	if o.onHeader != nil {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeRestore,
+			Indexed: true,
+			Header:  dbhdr,
+		})
+	}
+}
+
+func (o *Operations) fetch(
+	ctx context.Context,
+	vr *volumeReader,
+
+	sink config.RestoreSink,
+
+	dbhdr *config.Header,
+	contentHdr *config.Header,
+	dst string,
+	conflictPolicy string,
+
+	onConflict func(event *config.ConflictEvent),
+	onProgress func(event *config.ProgressEvent),
+) error {
+	o.onRestoreHeader(dbhdr)
+
+	reader, err := vr.seek(int(contentHdr.Volume))
 	if err != nil {
 		return err
 	}
-	defer o.backend.CloseReader()
-
-	for _, dbhdr := range headersToRestore {
-		if o.onHeader != nil {
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeRestore,
-				Indexed: true,
-				Header:  dbhdr,
-			})
-		}
 
-		dst := dbhdr.Name
-		if to != "" {
-			if flatten {
-				dst = to
-			} else {
-				dst = filepath.Join(to, strings.TrimPrefix(dst, from))
+	return recovery.FetchContext(
+		ctx,
+		reader,
+		o.backend.MagneticTapeIO,
+		vr.changeVolume,
+		o.pipes,
+		o.crypto,
 
-				if strings.TrimSuffix(dst, "/") == strings.TrimSuffix(to, "/") {
-					dst = filepath.Join(dst, path.Base(dbhdr.Name)) // Append the name so we don't overwrite
-				}
-			}
-		}
+		sink,
 
-		if err := recovery.Fetch(
-			reader,
-			o.backend.MagneticTapeIO,
-			o.pipes,
-			o.crypto,
+		int(contentHdr.Record),
+		int(contentHdr.Block),
+		dst,
+		false,
+		conflictPolicy,
 
-			getDst,
-			mkdirAll,
+		nil,
+		nil,
+		onConflict,
+		onProgress,
+	)
+}
 
-			int(dbhdr.Record),
-			int(dbhdr.Block),
-			dst,
-			false,
+func getRestorePath(dbhdr *config.Header, from string, to string, flatten bool) string {
+	dst := dbhdr.Name
+	if to != "" {
+		if flatten {
+			dst = to
+		} else {
+			dst = filepath.Join(to, strings.TrimPrefix(dst, from))
 
-			nil,
-		); err != nil {
-			return err
+			if strings.TrimSuffix(dst, "/") == strings.TrimSuffix(to, "/") {
+				dst = filepath.Join(dst, path.Base(dbhdr.Name)) // Append the name so we don't overwrite
+			}
 		}
 	}
 
-	return nil
+	return dst
 }
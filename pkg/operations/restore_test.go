@@ -0,0 +1,605 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+var (
+	archivedModTime = time.Date(2021, 11, 21, 0, 0, 0, 0, time.UTC)
+)
+
+var restoreConflictTests = []struct {
+	name            string
+	policy          string
+	existing        map[string]string
+	existingModTime time.Time
+	want            map[string]string
+	wantEvents      []string
+	wantErr         bool
+}{
+	{
+		"Can restore without existing files",
+		config.ConflictPolicySkipExisting,
+		map[string]string{},
+		archivedModTime,
+		map[string]string{"a.txt": "archived"},
+		[]string{},
+		false,
+	},
+	{
+		"Can overwrite existing file",
+		config.ConflictPolicyOverwrite,
+		map[string]string{"a.txt": "existing"},
+		archivedModTime,
+		map[string]string{"a.txt": "archived"},
+		[]string{config.ConflictEventTypeOverwrite},
+		false,
+	},
+	{
+		"Can skip existing file",
+		config.ConflictPolicySkipExisting,
+		map[string]string{"a.txt": "existing"},
+		archivedModTime.Add(-time.Hour),
+		map[string]string{"a.txt": "existing"},
+		[]string{config.ConflictEventTypeSkip},
+		false,
+	},
+	{
+		"Can keep newer existing file",
+		config.ConflictPolicyKeepNewer,
+		map[string]string{"a.txt": "existing"},
+		archivedModTime.Add(time.Hour),
+		map[string]string{"a.txt": "existing"},
+		[]string{config.ConflictEventTypeSkipNewer},
+		false,
+	},
+	{
+		"Can replace older existing file",
+		config.ConflictPolicyKeepNewer,
+		map[string]string{"a.txt": "existing"},
+		archivedModTime.Add(-time.Hour),
+		map[string]string{"a.txt": "archived"},
+		[]string{config.ConflictEventTypeOverwrite},
+		false,
+	},
+	{
+		"Can rename restored file",
+		config.ConflictPolicyRename,
+		map[string]string{"a.txt": "existing", "a.txt.1": "existing"},
+		archivedModTime,
+		map[string]string{"a.txt": "existing", "a.txt.1": "existing", "a.txt.2": "archived"},
+		[]string{config.ConflictEventTypeRename},
+		false,
+	},
+	{
+		"Can not restore with unknown policy",
+		"merge",
+		map[string]string{"a.txt": "existing"},
+		archivedModTime,
+		map[string]string{"a.txt": "existing"},
+		[]string{},
+		true,
+	},
+}
+
+func TestOperations_RestoreConflict(t *testing.T) {
+	for _, tt := range restoreConflictTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "archived"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Chtimes(filepath.Join(src, "a.txt"), archivedModTime, archivedModTime); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := writeTestFiles(dst, tt.existing); err != nil {
+				t.Fatal(err)
+			}
+
+			for name := range tt.existing {
+				if err := os.Chtimes(filepath.Join(dst, name), tt.existingModTime, tt.existingModTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			events := []string{}
+			err = to.ops.Restore(
+				sinks.NewFilesystemSink(false, nil, nil, false),
+				"a.txt",
+				dst,
+				false,
+				tt.policy,
+				func(event *config.ConflictEvent) {
+					events = append(events, event.Type)
+				},
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Restore() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() got = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("Restore() events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+var restoreParityTests = []struct {
+	name    string
+	damaged []int64 // Records of the tar file to overwrite with garbage
+	wantErr bool
+}{
+	{
+		"Can restore intact archive",
+		[]int64{},
+		false,
+	},
+	{
+		"Can restore archive with damaged record",
+		[]int64{2},
+		false,
+	},
+	{
+		"Can restore archive with damaged header record",
+		[]int64{0},
+		false,
+	},
+	{
+		"Can not restore archive with too many damaged records",
+		[]int64{1, 2, 3},
+		true,
+	},
+}
+
+func TestOperations_RestoreParity(t *testing.T) {
+	for _, tt := range restoreParityTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			files := map[string]string{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, files); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.OpenFile(to.drive, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			garbage := bytes.Repeat([]byte{0xff}, config.MagneticTapeBlockSize*recordSize)
+			for _, record := range tt.damaged {
+				if _, err := f.WriteAt(garbage, record*int64(len(garbage))); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), files)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Restore() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, files) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(files))
+			}
+		})
+	}
+}
+
+var errTapeSeek = errors.New("tape drives can't seek")
+
+// testTape stores records like a tape drive, which writes a record for every write and reads whole records; nil records are file marks
+type testTape struct {
+	records [][]byte
+	pos     int
+	dirty   bool
+}
+
+func (t *testTape) Write(p []byte) (int, error) {
+	t.records = append(t.records[:t.pos], append([]byte{}, p...))
+	t.pos++
+	t.dirty = true
+
+	return len(p), nil
+}
+
+func (t *testTape) Read(p []byte) (int, error) {
+	if t.pos >= len(t.records) {
+		return 0, io.EOF
+	}
+
+	record := t.records[t.pos]
+	if len(p) < len(record) {
+		return 0, syscall.ENOMEM
+	}
+	t.pos++
+
+	if record == nil {
+		return 0, io.EOF
+	}
+
+	return copy(p, record), nil
+}
+
+func (t *testTape) Seek(offset int64, whence int) (int64, error) {
+	return -1, errTapeSeek
+}
+
+func (t *testTape) Fd() uintptr {
+	return 0
+}
+
+// closeWriter writes a file mark after the records which have been written, like closing a tape drive
+func (t *testTape) closeWriter() error {
+	if t.dirty {
+		t.records = append(t.records[:t.pos], nil)
+		t.pos++
+		t.dirty = false
+	}
+
+	return nil
+}
+
+func (t *testTape) GetCurrentRecordFromTape(fd uintptr) (int64, error) {
+	return int64(t.pos), nil
+}
+
+func (t *testTape) GoToEndOfTape(fd uintptr) error {
+	t.pos = len(t.records)
+
+	return nil
+}
+
+func (t *testTape) GoToNextFileOnTape(fd uintptr) error {
+	for t.pos < len(t.records) {
+		t.pos++
+
+		if t.records[t.pos-1] == nil {
+			return nil
+		}
+	}
+
+	return io.EOF
+}
+
+func (t *testTape) EjectTape(fd uintptr) error {
+	return nil
+}
+
+func (t *testTape) SeekToRecordOnTape(fd uintptr, record int32) error {
+	t.pos = int(record)
+
+	return nil
+}
+
+var restoreTapeTests = []struct {
+	name    string
+	pipes   config.PipeConfig
+	runs    []map[string]string
+	damaged []int // Records of the tape to overwrite with garbage
+}{
+	{
+		"Can restore from tape",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)},
+			{"c.txt": getTestContent(2, 100)},
+		},
+		[]int{},
+	},
+	{
+		"Can restore from tape with parity",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)},
+			{"c.txt": getTestContent(2, 100)},
+		},
+		[]int{},
+	},
+	{
+		"Can restore from tape with parity and damaged records",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)},
+			{"c.txt": getTestContent(2, 100)},
+		},
+		[]int{0, 2, 3 * (4 + 2 + 1)}, // The second run starts after the two groups and the file mark of the first one, at the next multiple of the group size
+	},
+	{
+		"Can restore compressed files which have been spooled to disk from tape",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey, MemoryBudget: 1},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100)},
+			{"c.txt": getTestContent(2, 100)},
+		},
+		[]int{},
+	},
+	{
+		"Can restore deduplicated files from tape",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100), "d.txt": getTestContent(0, 40000)},
+			{"c.txt": getTestContent(2, 100), "e.txt": getTestContent(0, 40000), "f.txt": getTestContent(0, 40000)},
+		},
+		[]int{},
+	},
+	{
+		"Can restore deduplicated files from tape with parity",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: 4, ParityShards: 2},
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000), "b.txt": getTestContent(1, 100), "d.txt": getTestContent(0, 40000)},
+			{"c.txt": getTestContent(2, 100), "e.txt": getTestContent(3, 40000), "f.txt": getTestContent(3, 40000)},
+		},
+		[]int{},
+	},
+}
+
+func TestOperations_RestoreTape(t *testing.T) {
+	for _, tt := range restoreTapeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tape := &testTape{}
+			to.ops.backend = config.BackendConfig{
+				GetWriter: func() (config.DriveWriterConfig, error) {
+					if err := tape.GoToEndOfTape(tape.Fd()); err != nil {
+						return config.DriveWriterConfig{}, err
+					}
+
+					return config.DriveWriterConfig{Drive: tape, DriveIsRegular: false}, nil
+				},
+				CloseWriter: tape.closeWriter,
+
+				GetReader: func() (config.DriveReaderConfig, error) {
+					return config.DriveReaderConfig{Drive: tape, DriveIsRegular: false}, nil
+				},
+				CloseReader: func() error {
+					return nil
+				},
+
+				MagneticTapeIO: tape,
+			}
+
+			want := map[string]string{}
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false); err != nil {
+					t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				for name, content := range files {
+					want[name] = content
+				}
+			}
+
+			garbage := bytes.Repeat([]byte{0xff}, config.MagneticTapeBlockSize*recordSize)
+			for _, record := range tt.damaged {
+				if tape.records[record] == nil {
+					t.Fatalf("record %v is a file mark", record)
+				}
+
+				tape.records[record] = garbage
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() got different content for %v files, want %v files", len(got), len(want))
+			}
+
+			from := []string{}
+			for name := range want {
+				from = append(from, name)
+			}
+
+			dst := filepath.Join(dir, "many")
+			if err := to.ops.RestoreMany(sinks.NewFilesystemSink(false, nil, nil, false), from, dst, false, config.ConflictPolicyOverwrite, nil); err != nil {
+				t.Errorf("RestoreMany() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err = readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("RestoreMany() got different content for %v files, want %v files", len(got), len(want))
+			}
+		})
+	}
+}
+
+var restoreAtTests = []struct {
+	name    string
+	file    string
+	at      int // Index of the archive run to restore the snapshot of, or -1 to restore the latest headers
+	want    map[string]string
+	wantErr bool
+}{
+	{
+		"Can restore file as it was before it has been changed",
+		"a.txt",
+		0,
+		map[string]string{"a.txt": "old"},
+		false,
+	},
+	{
+		"Can restore latest version of changed file",
+		"a.txt",
+		-1,
+		map[string]string{"a.txt": "new"},
+		false,
+	},
+	{
+		"Can restore file as it was before it has been deleted",
+		"b.txt",
+		0,
+		map[string]string{"b.txt": "old"},
+		false,
+	},
+	{
+		"Can not restore deleted file without snapshot",
+		"b.txt",
+		-1,
+		map[string]string{},
+		true,
+	},
+	{
+		"Can not restore file which has been archived after the snapshot",
+		"c.txt",
+		0,
+		map[string]string{},
+		true,
+	},
+}
+
+func TestOperations_RestoreAt(t *testing.T) {
+	for _, tt := range restoreAtTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sessions := []int64{}
+			for i, files := range []map[string]string{
+				{"a.txt": "old", "b.txt": "old"},
+				{"a.txt": "new", "c.txt": "new"},
+			} {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				hdrs, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				session, err := strconv.ParseInt(hdrs[0].PAXRecords[records.STFSRecordSession], 10, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sessions = append(sessions, session)
+			}
+
+			if err := to.ops.Delete("b.txt"); err != nil {
+				t.Fatal(err)
+			}
+
+			at := int64(0)
+			if tt.at >= 0 {
+				at = sessions[tt.at]
+			}
+
+			// This is what `restore --at` does
+			if err := to.ops.metadata.Metadata.SetSnapshot(context.Background(), at); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := to.ops.Restore(sinks.NewFilesystemSink(false, nil, nil, false), tt.file, dst, false, config.ConflictPolicyOverwrite, nil); (err != nil) != tt.wantErr {
+				t.Errorf("Restore() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			got, err := readTestFiles(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
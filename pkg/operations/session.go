@@ -0,0 +1,29 @@
+package operations
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// NewSession returns the ID of a new session, i.e. to archive files with ArchiveSession
+func NewSession() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func getSession(dbhdr *config.Header) (int64, error) {
+	hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+	if err != nil {
+		return -1, err
+	}
+
+	session, ok := hdr.PAXRecords[records.STFSRecordSession]
+	if !ok {
+		return 0, nil // Headers without a session have been written before sessions were recorded
+	}
+
+	return strconv.ParseInt(session, 10, 64)
+}
@@ -0,0 +1,185 @@
+package operations
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+const (
+	sparseSize = 8 * 1024 * 1024
+)
+
+var sparseData = []byte("sparse data region")
+
+var restoreSparseTests = []struct {
+	name       string
+	pipes      config.PipeConfig
+	onProgress func(event *config.ProgressEvent)
+	compact    bool
+}{
+	{
+		"Can restore sparse file",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		nil,
+		false,
+	},
+	{
+		"Can restore sparse file with progress",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		func(event *config.ProgressEvent) {},
+		false,
+	},
+	{
+		"Can restore compressed sparse file with progress",
+		config.PipeConfig{Compression: config.CompressionFormatZStandardKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		func(event *config.ProgressEvent) {},
+		false,
+	},
+	{
+		"Can restore sparse file after compacting with progress",
+		config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey},
+		func(event *config.ProgressEvent) {},
+		true,
+	},
+}
+
+func TestOperations_RestoreSparse(t *testing.T) {
+	for _, tt := range restoreSparseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := os.MkdirAll(src, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Create(filepath.Join(src, "sparse"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := f.Truncate(sparseSize); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, offset := range []int64{0, sparseSize / 2} {
+				if _, err := f.WriteAt(sparseData, offset); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if allocated, err := getAllocatedSize(filepath.Join(src, "sparse")); err != nil {
+				t.Fatal(err)
+			} else if allocated >= sparseSize {
+				t.Skip("file system doesn't support sparse files")
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), tt.pipes, tt.onProgress)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Errorf("Archive() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if tt.compact {
+				compacted, err := createOperations(filepath.Join(dir, "compacted"), tt.pipes, tt.onProgress)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := to.ops.Compact(compacted.ops, config.CompressionLevelFastestKey); err != nil {
+					t.Errorf("Compact() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				to = compacted
+			}
+
+			dbhdr, err := to.ops.metadata.Metadata.GetHeader(context.Background(), "sparse")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hdr, err := getCompactHeader(dbhdr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := hdr.PAXRecords[records.STFSRecordSparseMap]; !ok {
+				t.Errorf("Archive() header of %v has no %v record", hdr.Name, records.STFSRecordSparseMap)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := to.ops.RestoreContext(
+				context.Background(),
+				sinks.NewFilesystemSink(false, nil, nil, false),
+				"sparse",
+				dst,
+				false,
+				config.ConflictPolicyOverwrite,
+				nil,
+			); err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(dst, "sparse"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make([]byte, sparseSize)
+			copy(want, sparseData)
+			copy(want[sparseSize/2:], sparseData)
+
+			if !bytes.Equal(content, want) {
+				t.Errorf("Restore() content of %v differs from the archived file", filepath.Join(dst, "sparse"))
+			}
+
+			allocated, err := getAllocatedSize(filepath.Join(dst, "sparse"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allocated >= sparseSize/2 {
+				t.Errorf("Restore() allocated = %v, want less than %v", allocated, sparseSize/2)
+			}
+		})
+	}
+}
+
+func getAllocatedSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size(), nil
+	}
+
+	return stat.Blocks * 512, nil
+}
@@ -0,0 +1,68 @@
+package operations
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+type streamFileInfo struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (f *streamFileInfo) Name() string {
+	return f.name
+}
+
+func (f *streamFileInfo) Size() int64 {
+	return -1 // Unknown until the stream has been read
+}
+
+func (f *streamFileInfo) Mode() fs.FileMode {
+	return f.mode
+}
+
+func (f *streamFileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *streamFileInfo) IsDir() bool {
+	return false
+}
+
+func (f *streamFileInfo) Sys() interface{} {
+	return nil
+}
+
+// NewStreamFileConfig creates a source for Archive and Update from a reader of unknown length, such as a pipe or stdin
+func NewStreamFileConfig(
+	name string,
+	mode fs.FileMode,
+	modTime time.Time,
+	getReader func() (io.ReadCloser, error),
+) config.FileConfig {
+	return config.FileConfig{
+		GetReader: getReader,
+		Info: &streamFileInfo{
+			name:    path.Base(name),
+			mode:    mode.Perm(),
+			modTime: modTime,
+		},
+		Path:   name,
+		Stream: true,
+	}
+}
+
+// openFile opens the content of a file with GetReader if it is set, and with GetFile otherwise
+func openFile(file config.FileConfig) (io.ReadCloser, error) {
+	if file.GetReader != nil {
+		return file.GetReader()
+	}
+
+	return file.GetFile()
+}
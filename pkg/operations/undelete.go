@@ -0,0 +1,144 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"database/sql"
+	"path"
+	"path/filepath"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/pathext"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// Undelete brings back the file or directory which has been deleted most recently with the given name, including its deleted children and parent directories.
+// If a file or directory with the name exists, config.ErrUndeleteOccupied is returned.
+func (o *Operations) Undelete(name string) error {
+	return o.UndeleteContext(context.Background(), name)
+}
+
+// UndeleteContext is like Undelete, but can be cancelled with ctx until the undeletion headers are written; they are always written completely
+func (o *Operations) UndeleteContext(ctx context.Context, name string) error {
+	name = filepath.ToSlash(name)
+
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	if _, err := o.metadata.Metadata.GetHeader(ctx, name); err == nil {
+		return config.ErrUndeleteOccupied
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	dbhdr, err := o.metadata.Metadata.GetDeletedHeader(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// Bring back the parent directories first so that the file or directory can be reached again
+	headersToUndelete := []*config.Header{}
+	for parent := path.Dir(path.Clean(dbhdr.Name)); !pathext.IsRoot(parent, false); parent = path.Dir(parent) {
+		parentHdr, err := o.getDeletedParent(ctx, parent)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				break
+			}
+
+			return err
+		}
+
+		if parentHdr == nil {
+			break
+		}
+
+		headersToUndelete = append([]*config.Header{parentHdr}, headersToUndelete...)
+	}
+	headersToUndelete = append(headersToUndelete, dbhdr)
+
+	// If the header refers to a directory, get it's children; children which have been replaced in the meantime are kept
+	if dbhdr.Typeflag == tar.TypeDir && dbhdr.Linkname == "" {
+		dbhdrs, err := o.metadata.Metadata.GetDeletedHeaderChildren(ctx, dbhdr.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range dbhdrs {
+			if _, err := o.metadata.Metadata.GetHeader(ctx, child.Name); err == nil {
+				continue
+			} else if err != sql.ErrNoRows {
+				return err
+			}
+
+			headersToUndelete = append(headersToUndelete, child)
+		}
+	}
+
+	vw, err := o.newVolumeWriter(ctx, false, false, func(hdr *config.Header) {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeUndelete,
+			Indexed: true,
+			Header:  hdr,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	defer vw.discard()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Append undeletion hdrs to the tape or tar file
+	session := NewSession()
+	for _, dbhdr := range headersToUndelete {
+		hdr, err := converters.DBHeaderToTarHeader(converters.ConfigHeaderToDBHeader(dbhdr))
+		if err != nil {
+			return err
+		}
+
+		hdr.Size = 0 // Don't try to seek after the record
+		hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
+		hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionUndelete
+		hdr.PAXRecords[records.STFSRecordSession] = session
+
+		if o.onHeader != nil {
+			dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+			if err != nil {
+				return err
+			}
+
+			o.onHeader(&config.HeaderEvent{
+				Type:    config.HeaderEventTypeUndelete,
+				Indexed: false,
+				Header:  converters.DBHeaderToConfigHeader(dbhdr),
+			})
+		}
+
+		if err := vw.write(hdr, nil); err != nil {
+			return err
+		}
+	}
+
+	return vw.close()
+}
+
+// getDeletedParent returns the deleted header of the parent directory or nil if the parent directory exists
+func (o *Operations) getDeletedParent(ctx context.Context, parent string) (*config.Header, error) {
+	for _, name := range []string{parent, parent + "/"} {
+		if _, err := o.metadata.Metadata.GetHeader(ctx, name); err == nil {
+			return nil, nil
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	dbhdr, err := o.metadata.Metadata.GetDeletedHeader(ctx, parent)
+	if err == sql.ErrNoRows {
+		return o.metadata.Metadata.GetDeletedHeader(ctx, parent+"/")
+	}
+
+	return dbhdr, err
+}
@@ -0,0 +1,131 @@
+package operations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var undeleteTests = []struct {
+	name        string
+	deletes     []string // Files and directories to delete before the undeletion
+	undelete    string
+	wantErr     error
+	wantIndexed []string          // Files and directories in the index after the undeletion
+	want        map[string]string // Files which can be restored after the undeletion
+}{
+	{
+		"Can undelete file",
+		[]string{"src/a.txt"},
+		"src/a.txt",
+		nil,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt", "src/d/f.txt"},
+		map[string]string{"src/a.txt": "a"},
+	},
+	{
+		"Can undelete directory with its children",
+		[]string{"src/d"},
+		"src/d",
+		nil,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt", "src/d/f.txt"},
+		map[string]string{"src/d/e.txt": "e", "src/d/f.txt": "f"},
+	},
+	{
+		"Can undelete file with its deleted parent directory",
+		[]string{"src/d"},
+		"src/d/e.txt",
+		nil,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt"},
+		map[string]string{"src/d/e.txt": "e"},
+	},
+	{
+		"Can undelete file which has been deleted before its directory",
+		[]string{"src/d/e.txt", "src/d"},
+		"src/d",
+		nil,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt", "src/d/f.txt"},
+		map[string]string{"src/d/e.txt": "e", "src/d/f.txt": "f"},
+	},
+	{
+		"Can not undelete existing file",
+		[]string{},
+		"src/a.txt",
+		config.ErrUndeleteOccupied,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt", "src/d/f.txt"},
+		map[string]string{},
+	},
+	{
+		"Can not undelete file which has never been archived",
+		[]string{},
+		"src/b.txt",
+		sql.ErrNoRows,
+		[]string{"src", "src/a.txt", "src/d", "src/d/e.txt", "src/d/f.txt"},
+		map[string]string{},
+	},
+}
+
+func TestOperations_Undelete(t *testing.T) {
+	for _, tt := range undeleteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "a", "d/e.txt": "e", "d/f.txt": "f"}); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(NewWalkSource(dir, "src", nil), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tt.deletes {
+				if err := to.ops.Delete(name); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := to.ops.Undelete(tt.undelete); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Undelete() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			hdrs, err := to.ops.metadata.Metadata.GetHeaders(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			indexed := []string{}
+			for _, hdr := range hdrs {
+				indexed = append(indexed, hdr.Name)
+			}
+			sort.Strings(indexed)
+
+			if !reflect.DeepEqual(indexed, tt.wantIndexed) {
+				t.Errorf("Undelete() indexed = %v, want %v", indexed, tt.wantIndexed)
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), tt.want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
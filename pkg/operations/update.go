@@ -4,19 +4,15 @@ import (
 	"archive/tar"
 	"context"
 	"io"
-	"strconv"
 	"strings"
 
 	"github.com/pojntfx/stfs/internal/converters"
 	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
 	"github.com/pojntfx/stfs/internal/records"
 	"github.com/pojntfx/stfs/internal/suffix"
-	"github.com/pojntfx/stfs/internal/tarext"
-	"github.com/pojntfx/stfs/pkg/compression"
+	"github.com/pojntfx/stfs/internal/xattrext"
 	"github.com/pojntfx/stfs/pkg/config"
-	"github.com/pojntfx/stfs/pkg/encryption"
-	"github.com/pojntfx/stfs/pkg/recovery"
-	"github.com/pojntfx/stfs/pkg/signature"
 )
 
 func (o *Operations) Update(
@@ -24,37 +20,61 @@ func (o *Operations) Update(
 	compressionLevel string,
 	replace bool,
 	skipSizeCheck bool,
+) ([]*tar.Header, error) {
+	return o.UpdateContext(context.Background(), getSrc, compressionLevel, replace, skipSizeCheck)
+}
+
+// UpdateContext is like Update, but stops once ctx has been cancelled; the files which have been updated until then are indexed and returned together with the error of ctx
+func (o *Operations) UpdateContext(
+	ctx context.Context,
+	getSrc func() (config.FileConfig, error),
+	compressionLevel string,
+	replace bool,
+	skipSizeCheck bool,
 ) ([]*tar.Header, error) {
 	o.diskOperationLock.Lock()
 	defer o.diskOperationLock.Unlock()
 
-	writer, err := o.backend.GetWriter()
+	vw, err := o.newVolumeWriter(ctx, false, false, func(hdr *config.Header) {
+		o.onHeader(&config.HeaderEvent{
+			Type:    config.HeaderEventTypeUpdate,
+			Indexed: true,
+			Header:  hdr,
+		})
+	})
 	if err != nil {
 		return []*tar.Header{}, err
 	}
+	defer vw.discard()
 
-	dirty := false
-	tw, cleanup, err := tarext.NewTapeWriter(writer.Drive, writer.DriveIsRegular, o.pipes.RecordSize)
-	if err != nil {
-		return []*tar.Header{}, err
-	}
+	var payload *ioext.SpoolWriter
+	defer func() {
+		if payload != nil {
+			_ = payload.Close()
+		}
+	}()
 
-	lastIndexedRecord, lastIndexedBlock, err := o.metadata.Metadata.GetLastIndexedRecordAndBlock(context.Background(), o.pipes.RecordSize)
-	if err != nil {
-		return []*tar.Header{}, err
-	}
+	tracker := progress.NewTracker(config.ProgressEventTypeUpdate, 0, 0, o.onProgress)
+	defer tracker.Finish()
 
+	session := NewSession()
 	hdrs := []*tar.Header{}
 	for {
+		if err := ctx.Err(); err != nil {
+			return hdrs, vw.abort(err)
+		}
+
 		file, err := getSrc()
 		if err == io.EOF {
 			break
 		}
 
 		if err != nil {
-			return []*tar.Header{}, err
+			return hdrs, vw.abort(err)
 		}
 
+		tracker.Start(file.Path)
+
 		hdr, err := tar.FileInfoHeader(file.Info, file.Link)
 		if err != nil {
 			// Skip sockets
@@ -62,7 +82,7 @@ func (o *Operations) Update(
 				continue
 			}
 
-			return []*tar.Header{}, err
+			return hdrs, vw.abort(err)
 		}
 
 		hdr.Name = file.Path
@@ -72,80 +92,43 @@ func (o *Operations) Update(
 		}
 		hdr.PAXRecords[records.STFSRecordVersion] = records.STFSRecordVersion1
 		hdr.PAXRecords[records.STFSRecordAction] = records.STFSRecordActionUpdate
+		hdr.PAXRecords[records.STFSRecordSession] = session
+		xattrext.AddToPAXRecords(hdr.PAXRecords, file.Xattrs)
 
-		var f io.ReadSeekCloser
-		if file.Info.Mode().IsRegular() && replace && (file.Info.Size() > 0 || skipSizeCheck) {
-			// Get the compressed size for the header
-			fileSizeCounter := &ioext.CounterWriter{
-				Writer: io.Discard,
-			}
-
-			encryptor, err := encryption.Encrypt(fileSizeCounter, o.pipes.Encryption, o.crypto.Recipient)
-			if err != nil {
-				return []*tar.Header{}, err
-			}
-
-			compressor, err := compression.Compress(
-				encryptor,
-				o.pipes.Compression,
-				compressionLevel,
-				writer.DriveIsRegular,
-				o.pipes.RecordSize,
-			)
-			if err != nil {
-				return []*tar.Header{}, err
-			}
-
-			f, err = file.GetFile()
+		if file.Info.Mode().IsRegular() && replace && (file.Info.Size() > 0 || skipSizeCheck || file.Stream) {
+			f, err := openFile(file)
 			if err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 
-			signer, sign, err := signature.Sign(f, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity)
+			payload, err = o.encodePayload(ctx, hdr, f, compressionLevel, vw.isRegular, payloadSpoolMemoryLimit, tracker)
 			if err != nil {
-				return []*tar.Header{}, err
-			}
+				_ = f.Close()
 
-			if writer.DriveIsRegular {
-				if _, err := io.Copy(compressor, signer); err != nil {
-					return []*tar.Header{}, err
-				}
-			} else {
-				buf := make([]byte, config.MagneticTapeBlockSize*o.pipes.RecordSize)
-				if _, err := io.CopyBuffer(compressor, signer, buf); err != nil {
-					return []*tar.Header{}, err
+				if ctx.Err() != nil {
+					return hdrs, vw.abort(ctx.Err())
 				}
-			}
-
-			if err := compressor.Flush(); err != nil {
-				return []*tar.Header{}, err
-			}
 
-			if err := compressor.Close(); err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 
-			if err := encryptor.Close(); err != nil {
-				return []*tar.Header{}, err
+			if err := f.Close(); err != nil {
+				return hdrs, vw.abort(err)
 			}
 
-			if hdr.PAXRecords == nil {
-				hdr.PAXRecords = map[string]string{}
-			}
-			hdr.PAXRecords[records.STFSRecordUncompressedSize] = strconv.Itoa(int(hdr.Size))
-			signature, err := sign()
+			// Reference identical content which has already been archived
+			payload, err = o.deduplicatePayload(hdr, payload)
 			if err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 
-			if signature != "" {
-				hdr.PAXRecords[records.STFSRecordSignature] = signature
+			if payload != nil {
+				tracker.AddWritten(payload.Size())
 			}
-			hdr.Size = int64(fileSizeCounter.BytesRead)
 
 			hdr.Name, err = suffix.AddSuffix(hdr.Name, o.pipes.Compression, o.pipes.Encryption)
 			if err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 		}
 
@@ -155,7 +138,7 @@ func (o *Operations) Update(
 			if o.onHeader != nil {
 				dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
 				if err != nil {
-					return []*tar.Header{}, err
+					return hdrs, vw.abort(err)
 				}
 
 				o.onHeader(&config.HeaderEvent{
@@ -166,79 +149,15 @@ func (o *Operations) Update(
 			}
 
 			hdrToAppend := *hdr
-			hdrs = append(hdrs, &hdrToAppend)
-
-			if err := signature.SignHeader(hdr, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if err := encryption.EncryptHeader(hdr, o.pipes.Encryption, o.crypto.Recipient); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if err := tw.WriteHeader(hdr); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			dirty = true
 
-			if !file.Info.Mode().IsRegular() || (!skipSizeCheck && file.Info.Size() <= 0) {
-				if f != nil {
-					if err := f.Close(); err != nil {
-						return []*tar.Header{}, err
-					}
-				}
-
-				continue
-			}
-
-			// Compress and write the file
-			encryptor, err := encryption.Encrypt(tw, o.pipes.Encryption, o.crypto.Recipient)
+			// The volume writer takes ownership of the payload
+			err = vw.write(hdr, payload)
+			payload = nil
 			if err != nil {
-				return []*tar.Header{}, err
-			}
-
-			compressor, err := compression.Compress(
-				encryptor,
-				o.pipes.Compression,
-				compressionLevel,
-				writer.DriveIsRegular,
-				o.pipes.RecordSize,
-			)
-			if err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if _, err := f.Seek(0, io.SeekStart); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if writer.DriveIsRegular {
-				if _, err := io.Copy(compressor, f); err != nil {
-					return []*tar.Header{}, err
-				}
-			} else {
-				buf := make([]byte, config.MagneticTapeBlockSize*o.pipes.RecordSize)
-				if _, err := io.CopyBuffer(compressor, f, buf); err != nil {
-					return []*tar.Header{}, err
-				}
-			}
-
-			if err := compressor.Flush(); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if err := compressor.Close(); err != nil {
-				return []*tar.Header{}, err
-			}
-
-			if err := encryptor.Close(); err != nil {
-				return []*tar.Header{}, err
+				return hdrs, vw.abort(err)
 			}
 
-			if err := f.Close(); err != nil {
-				return []*tar.Header{}, err
-			}
+			hdrs = append(hdrs, &hdrToAppend)
 		} else {
 			hdr.PAXRecords[records.STFSRecordReplacesContent] = records.STFSRecordReplacesContentFalse
 			hdr.Size = 0 // Don't try to seek after the record
@@ -246,7 +165,7 @@ func (o *Operations) Update(
 			if o.onHeader != nil {
 				dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
 				if err != nil {
-					return []*tar.Header{}, err
+					return hdrs, vw.abort(err)
 				}
 
 				o.onHeader(&config.HeaderEvent{
@@ -257,70 +176,16 @@ func (o *Operations) Update(
 			}
 
 			hdrToAppend := *hdr
-			hdrs = append(hdrs, &hdrToAppend)
-
-			if err := signature.SignHeader(hdr, writer.DriveIsRegular, o.pipes.Signature, o.crypto.Identity); err != nil {
-				return []*tar.Header{}, err
-			}
 
-			if err := encryption.EncryptHeader(hdr, o.pipes.Encryption, o.crypto.Recipient); err != nil {
-				return []*tar.Header{}, err
+			if err := vw.write(hdr, nil); err != nil {
+				return hdrs, vw.abort(err)
 			}
 
-			if err := tw.WriteHeader(hdr); err != nil {
-				return []*tar.Header{}, err
-			}
+			hdrs = append(hdrs, &hdrToAppend)
 		}
 
-		dirty = true
+		tracker.Done()
 	}
 
-	if err := cleanup(&dirty); err != nil {
-		return []*tar.Header{}, err
-	}
-
-	if err := o.backend.CloseWriter(); err != nil {
-		return []*tar.Header{}, err
-	}
-
-	reader, err := o.backend.GetReader()
-	if err != nil {
-		return []*tar.Header{}, err
-	}
-	defer o.backend.CloseReader()
-
-	return hdrs, recovery.Index(
-		reader,
-		o.backend.MagneticTapeIO,
-		o.metadata,
-		o.pipes,
-		o.crypto,
-
-		int(lastIndexedRecord),
-		int(lastIndexedBlock),
-		false,
-		false,
-		1, // Ignore the first header, which is the last header which we already indexed
-
-		func(hdr *tar.Header, i int) error {
-			if len(hdrs) <= i {
-				return config.ErrTarHeaderMissing
-			}
-
-			*hdr = *hdrs[i]
-
-			return nil
-		},
-		func(hdr *tar.Header, isRegular bool) error {
-			return nil // We sign above, no need to verify
-		},
-
-		func(hdr *config.Header) {
-			o.onHeader(&config.HeaderEvent{
-				Type:    config.HeaderEventTypeUpdate,
-				Indexed: true,
-				Header:  hdr,
-			})
-		},
-	)
+	return hdrs, vw.close()
 }
@@ -0,0 +1,148 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"sort"
+
+	"github.com/pojntfx/stfs/internal/progress"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/recovery"
+)
+
+// Verify reads the content of all indexed files and compares its hash with the one which has been recorded when archiving it.
+// Every file is reported to onVerify; if any file doesn't match or can't be read, config.ErrIntegrityCheckFailed is returned.
+func (o *Operations) Verify(onVerify func(event *config.VerifyEvent)) error {
+	return o.VerifyContext(context.Background(), onVerify)
+}
+
+// VerifyContext is like Verify, but stops once ctx has been cancelled
+func (o *Operations) VerifyContext(ctx context.Context, onVerify func(event *config.VerifyEvent)) error {
+	o.diskOperationLock.Lock()
+	defer o.diskOperationLock.Unlock()
+
+	dbhdrs, err := o.metadata.Metadata.GetHeaders(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Read the files in the order in which they are on the tape or tar file
+	sort.Slice(dbhdrs, func(i, j int) bool {
+		if dbhdrs[i].Volume != dbhdrs[j].Volume {
+			return dbhdrs[i].Volume < dbhdrs[j].Volume
+		}
+
+		if dbhdrs[i].Record != dbhdrs[j].Record {
+			return dbhdrs[i].Record < dbhdrs[j].Record
+		}
+
+		return dbhdrs[i].Block < dbhdrs[j].Block
+	})
+
+	filesTotal, bytesTotal := int64(0), int64(0)
+	for _, dbhdr := range dbhdrs {
+		if dbhdr.Typeflag == tar.TypeReg || dbhdr.Typeflag == tar.TypeLink {
+			filesTotal++
+			bytesTotal += dbhdr.Size
+		}
+	}
+
+	tracker := progress.NewTracker(config.ProgressEventTypeVerify, filesTotal, bytesTotal, o.onProgress)
+	defer tracker.Finish()
+
+	vr, err := o.newVolumeReader()
+	if err != nil {
+		return err
+	}
+	defer vr.close()
+
+	failed := false
+	for _, dbhdr := range dbhdrs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if dbhdr.Typeflag != tar.TypeReg && dbhdr.Typeflag != tar.TypeLink {
+			continue
+		}
+
+		tracker.Start(dbhdr.Name)
+
+		event := &config.VerifyEvent{
+			Header: dbhdr,
+		}
+
+		// Hard links are verified using the content of the file they link to
+		contentHdr := dbhdr
+		if dbhdr.Typeflag == tar.TypeLink {
+			contentHdr, event.Error = o.metadata.Metadata.ResolveHardlink(ctx, dbhdr.Linkname)
+		}
+
+		if event.Error == nil {
+			// Empty files have no content to verify
+			if contentHdr.Size <= 0 {
+				tracker.Done()
+
+				continue
+			}
+
+			event.Expected, event.Actual, event.Error = o.verify(ctx, vr, contentHdr, func(correction *config.CorrectionEvent) {
+				event.Corrected += correction.Corrected
+			}, tracker.Forward())
+		}
+
+		// Files which haven't been verified completely aren't reported
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		switch {
+		case event.Error != nil:
+			event.Type = config.VerifyEventTypeError
+		case event.Expected == "":
+			event.Type = config.VerifyEventTypeMissing
+		case event.Expected != event.Actual:
+			event.Type = config.VerifyEventTypeMismatch
+		default:
+			event.Type = config.VerifyEventTypeOK
+		}
+
+		if event.Type == config.VerifyEventTypeError || event.Type == config.VerifyEventTypeMismatch {
+			failed = true
+		}
+
+		if onVerify != nil {
+			onVerify(event)
+		}
+
+		tracker.Done()
+	}
+
+	if failed {
+		return config.ErrIntegrityCheckFailed
+	}
+
+	return nil
+}
+
+func (o *Operations) verify(ctx context.Context, vr *volumeReader, contentHdr *config.Header, onCorrection func(event *config.CorrectionEvent), onProgress func(event *config.ProgressEvent)) (string, string, error) {
+	reader, err := vr.seek(int(contentHdr.Volume))
+	if err != nil {
+		return "", "", err
+	}
+
+	return recovery.VerifyContext(
+		ctx,
+		reader,
+		o.backend.MagneticTapeIO,
+		vr.changeVolume,
+		o.pipes,
+		o.crypto,
+
+		int(contentHdr.Record),
+		int(contentHdr.Block),
+
+		onCorrection,
+		onProgress,
+	)
+}
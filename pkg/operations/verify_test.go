@@ -0,0 +1,143 @@
+package operations
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var verifyTests = []struct {
+	name          string
+	dataShards    int
+	parityShards  int
+	damaged       []int64           // Records of the tar file to overwrite with garbage
+	corrupt       string            // File to change a byte of the content of
+	want          map[string]string // Verify event types of the files; the other files aren't checked
+	wantCorrected bool
+	wantErr       error
+}{
+	{
+		"Can verify intact archive",
+		0,
+		0,
+		[]int64{},
+		"",
+		map[string]string{"a.txt": config.VerifyEventTypeOK, "b.txt": config.VerifyEventTypeOK},
+		false,
+		nil,
+	},
+	{
+		"Can detect corrupted content",
+		0,
+		0,
+		[]int64{},
+		"b.txt",
+		map[string]string{"a.txt": config.VerifyEventTypeOK, "b.txt": config.VerifyEventTypeMismatch},
+		false,
+		config.ErrIntegrityCheckFailed,
+	},
+	{
+		"Can verify archive with damaged record using parity",
+		4,
+		2,
+		[]int64{2},
+		"",
+		map[string]string{"a.txt": config.VerifyEventTypeOK, "b.txt": config.VerifyEventTypeOK},
+		true,
+		nil,
+	},
+	{
+		"Can detect archive with too many damaged records",
+		4,
+		2,
+		[]int64{1, 2, 3},
+		"",
+		map[string]string{"a.txt": config.VerifyEventTypeError},
+		false,
+		config.ErrIntegrityCheckFailed,
+	},
+}
+
+func TestOperations_Verify(t *testing.T) {
+	for _, tt := range verifyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			// Empty files have no content to verify
+			files := map[string]string{"a.txt": getTestContent(0, 40000), "b.txt": strings.Repeat("b", 100), "c.txt": ""}
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, files); err != nil {
+				t.Fatal(err)
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey, DataShards: tt.dataShards, ParityShards: tt.parityShards}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			drive, err := os.ReadFile(to.drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			garbage := bytes.Repeat([]byte{0xff}, config.MagneticTapeBlockSize*recordSize)
+			for _, record := range tt.damaged {
+				copy(drive[record*int64(len(garbage)):], garbage)
+			}
+
+			if tt.corrupt != "" {
+				i := bytes.Index(drive, []byte(files[tt.corrupt]))
+				if i < 0 {
+					t.Fatalf("content of %v not found in tar file", tt.corrupt)
+				}
+
+				drive[i+len(files[tt.corrupt])/2] ^= 0xff
+			}
+
+			if err := os.WriteFile(to.drive, drive, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			corrected := 0
+			err = to.ops.Verify(func(event *config.VerifyEvent) {
+				got[event.Header.Name] = event.Type
+				corrected += event.Corrected
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if _, ok := got["c.txt"]; ok {
+				t.Errorf("Verify() reported empty file")
+			}
+
+			for name := range got {
+				if _, ok := tt.want[name]; !ok {
+					delete(got, name)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+
+			if (corrected > 0) != tt.wantCorrected {
+				t.Errorf("Verify() corrected %v records, want corrected %v", corrected, tt.wantCorrected)
+			}
+		})
+	}
+}
@@ -0,0 +1,653 @@
+package operations
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"syscall"
+
+	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/tarext"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/encryption"
+	"github.com/pojntfx/stfs/pkg/parity"
+	"github.com/pojntfx/stfs/pkg/recovery"
+	"github.com/pojntfx/stfs/pkg/signature"
+)
+
+type volumeEntry struct {
+	hdr     *tar.Header // Plaintext header
+	payload *ioext.SpoolWriter
+	written int64 // Bytes of the payload which are on previous volumes
+
+	// Positions in the current volume; -1 if not (yet) written
+	payloadStart int64
+	end          int64
+}
+
+// volumePosition is the position of a header in the form the index uses
+type volumePosition struct {
+	volume int64
+	record int64
+	block  int64
+}
+
+// volumeWriter writes headers and payloads to the tape or tar file, continuing on the next volume if the current one is full
+type volumeWriter struct {
+	o            *Operations
+	initializing bool
+	onHeader     func(hdr *config.Header)
+
+	volume    int
+	record    int64
+	block     int64
+	offset    int
+	purge     bool
+	isRegular bool
+
+	drive    *ioext.CounterWriter // Bytes which have reached the drive
+	physical *ioext.CounterWriter // Bytes which have reached the drive, including parity records
+	written  int64                // Bytes which have reached the previous volumes, including parity records
+	stream   *ioext.CounterWriter // Bytes which have been written to the archive, including buffered ones
+	tw       *tar.Writer
+	cleanup  func(dirty *bool) error
+	dirty    bool
+
+	pending []*volumeEntry
+	hdrs    []*tar.Header // Plaintext headers on the current volume, used for indexing
+
+	start     int64                          // Offset in the data of the current volume at which the archive starts; -1 if unknown
+	positions map[*tar.Header]volumePosition // Positions of the headers with payloads which have been written
+}
+
+func (o *Operations) newVolumeWriter(ctx context.Context, overwrite bool, initializing bool, onHeader func(hdr *config.Header)) (*volumeWriter, error) {
+	w := &volumeWriter{
+		o:            o,
+		initializing: initializing,
+		onHeader:     onHeader,
+		purge:        overwrite,
+		positions:    map[*tar.Header]volumePosition{},
+	}
+
+	// Index the headers which a previous write hasn't indexed before appending after them
+	if !o.dryRun {
+		if err := o.recoverJournal(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !overwrite {
+		volume, err := o.metadata.Metadata.GetLastIndexedVolume(ctx)
+		if err != nil {
+			return nil, err
+		}
+		w.volume = int(volume)
+
+		w.record, w.block, err = o.metadata.Metadata.GetLastIndexedRecordAndBlock(ctx, o.pipes.RecordSize)
+		if err != nil {
+			return nil, err
+		}
+
+		w.offset = 1 // Ignore the first header, which is the last header which we already indexed
+	}
+
+	if err := w.begin(); err != nil {
+		return nil, err
+	}
+
+	// Continue on the last volume; dry runs don't touch the tape or tar file
+	if o.backend.ChangeVolume == nil {
+		if w.volume != 0 {
+			return nil, config.ErrVolumeChangeUnsupported
+		}
+	} else if !o.dryRun {
+		if err := o.backend.ChangeVolume(w.volume); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *volumeWriter) open() error {
+	var writer config.DriveWriterConfig
+	var err error
+	if w.o.dryRun {
+		// Dry runs write to a sink so that the sizes match the ones of an actual run
+		writer = config.DriveWriterConfig{
+			Drive:          io.Discard,
+			DriveIsRegular: true,
+			Volume:         w.volume,
+		}
+	} else {
+		writer, err = w.o.backend.GetWriter()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.isRegular = writer.DriveIsRegular
+	w.dirty = false
+
+	w.physical = &ioext.CounterWriter{Writer: writer.Drive}
+
+	// Find the record at which the archive starts, so that the positions of the headers are known before they are indexed
+	w.start = -1
+	record := int64(-1)
+	if w.o.dryRun {
+		// Dry runs don't know the tape or tar file
+	} else if writer.DriveIsRegular {
+		if s, ok := writer.Drive.(io.Seeker); ok {
+			// Tar files are appended to without padding them to whole records
+			w.start, err = s.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+
+			record = w.start / int64(config.MagneticTapeBlockSize*w.o.pipes.RecordSize)
+		}
+	} else if f, ok := writer.Drive.(interface{ Fd() uintptr }); ok && w.o.backend.MagneticTapeIO != nil {
+		record, err = w.o.backend.MagneticTapeIO.GetCurrentRecordFromTape(f.Fd())
+		if err != nil {
+			return err
+		}
+
+		w.start = record * int64(config.MagneticTapeBlockSize*w.o.pipes.RecordSize)
+	}
+
+	// Parity records are written below the counter so that it only counts the data
+	var drive io.Writer = w.physical
+	var pw *parity.Writer
+	if w.o.pipes.ParityShards > 0 {
+		// Groups on tapes start at multiples of the group size, which depends on the record at which the tape is
+		if record < 0 {
+			record = 0
+		}
+
+		if w.start >= 0 {
+			w.start = parity.DataOffset(record, w.o.pipes.RecordSize, w.o.pipes.DataShards, w.o.pipes.ParityShards)
+		}
+
+		pw, err = parity.NewWriter(w.physical, writer.DriveIsRegular, record, w.o.pipes.RecordSize, w.o.pipes.DataShards, w.o.pipes.ParityShards)
+		if err != nil {
+			return err
+		}
+
+		drive = pw
+	}
+
+	w.drive = &ioext.CounterWriter{Writer: drive}
+
+	var cleanup func(dirty *bool) error
+	w.tw, w.stream, cleanup, err = tarext.NewTapeWriter(w.drive, writer.DriveIsRegular, w.o.pipes.RecordSize)
+	w.cleanup = func(dirty *bool) error {
+		if err := cleanup(dirty); err != nil {
+			return err
+		}
+
+		if pw != nil {
+			return pw.Close()
+		}
+
+		return nil
+	}
+
+	return err
+}
+
+// write signs and encrypts a copy of hdr and writes it, followed by the already compressed and encrypted payload if there is one
+func (w *volumeWriter) write(hdr *tar.Header, payload *ioext.SpoolWriter) error {
+	entry := &volumeEntry{
+		hdr:     hdr,
+		payload: payload,
+	}
+
+	w.pending = append(w.pending, entry)
+	w.hdrs = append(w.hdrs, hdr)
+
+	if err := w.journal(hdr); err != nil {
+		return err
+	}
+
+	if err := w.writeEntry(entry); err != nil {
+		return w.nextVolume(err)
+	}
+
+	w.release()
+
+	return nil
+}
+
+func (w *volumeWriter) writeEntry(entry *volumeEntry) error {
+	entry.payloadStart = -1
+	entry.end = -1
+
+	signedHdr := *entry.header()
+
+	if err := signature.SignHeader(&signedHdr, w.isRegular, w.o.pipes.Signature, w.o.crypto.Identity); err != nil {
+		return err
+	}
+
+	if err := encryption.EncryptHeader(&signedHdr, w.o.pipes.Encryption, w.o.crypto.Recipient); err != nil {
+		return err
+	}
+
+	// Headers start at the block after the padding of the previous payload
+	if w.start >= 0 && entry.written == 0 && entry.payload != nil {
+		blocks := (int64(w.stream.BytesRead) + config.MagneticTapeBlockSize - 1) / config.MagneticTapeBlockSize
+		offset := w.start/config.MagneticTapeBlockSize + blocks
+
+		w.positions[entry.hdr] = volumePosition{
+			volume: int64(w.volume),
+			record: offset / int64(w.o.pipes.RecordSize),
+			block:  offset % int64(w.o.pipes.RecordSize),
+		}
+	}
+
+	if err := w.tw.WriteHeader(&signedHdr); err != nil {
+		return err
+	}
+
+	w.dirty = true
+	entry.payloadStart = int64(w.stream.BytesRead)
+
+	if entry.payload != nil {
+		src, err := entry.payload.Reader()
+		if err != nil {
+			return err
+		}
+
+		// Skip the part of the payload which is on previous volumes
+		if _, err := io.CopyN(io.Discard, src, entry.written); err != nil {
+			return err
+		}
+
+		if err := copyWithRecordSize(w.tw, src, w.isRegular, w.o.pipes.RecordSize); err != nil {
+			return err
+		}
+	}
+
+	entry.end = int64(w.stream.BytesRead)
+
+	return nil
+}
+
+// position returns where hdr, which has been written with a payload, starts on the tape or tar file. It isn't known
+// for archives with an unknown start, i.e. in dry runs, or while the header is buffered and could still move to the next volume.
+func (w *volumeWriter) position(hdr *tar.Header) (volumePosition, bool) {
+	for _, entry := range w.pending {
+		if entry.hdr == hdr && (entry.payloadStart < 0 || entry.payloadStart > int64(w.drive.BytesRead)) {
+			return volumePosition{}, false
+		}
+	}
+
+	position, ok := w.positions[hdr]
+
+	return position, ok
+}
+
+// header returns the header to write for the entry, which is a continuation header if parts of the payload are on previous volumes
+func (e *volumeEntry) header() *tar.Header {
+	if e.written == 0 {
+		return e.hdr
+	}
+
+	hdr := *e.hdr
+	hdr.PAXRecords = map[string]string{}
+	for key, value := range e.hdr.PAXRecords {
+		hdr.PAXRecords[key] = value
+	}
+
+	hdr.PAXRecords[records.STFSRecordContinuationOffset] = strconv.FormatInt(e.written, 10)
+	hdr.Size = e.payload.Size() - e.written
+
+	return &hdr
+}
+
+// release closes the payloads which have completely reached the drive
+func (w *volumeWriter) release() {
+	committed := int64(w.drive.BytesRead)
+
+	pending := []*volumeEntry{}
+	for _, entry := range w.pending {
+		if entry.end >= 0 && entry.end <= committed {
+			if entry.payload != nil {
+				_ = entry.payload.Close()
+			}
+
+			continue
+		}
+
+		pending = append(pending, entry)
+	}
+
+	w.pending = pending
+}
+
+// discard closes all pending payloads and the journal; if the write hasn't been closed, the journal is kept so that the next write indexes what has been written
+func (w *volumeWriter) discard() {
+	for _, entry := range w.pending {
+		if entry.payload != nil {
+			_ = entry.payload.Close()
+		}
+	}
+
+	w.pending = []*volumeEntry{}
+
+	if w.o.metadata.Journal != nil {
+		_ = w.o.metadata.Journal.Close()
+	}
+}
+
+func (w *volumeWriter) nextVolume(err error) error {
+	if !errors.Is(err, syscall.ENOSPC) || w.o.backend.ChangeVolume == nil {
+		return err
+	}
+
+	// Close the full volume and index the headers which have reached it
+	committed := int64(w.drive.BytesRead)
+	w.written += int64(w.physical.BytesRead)
+	if err := w.o.backend.CloseWriter(); err != nil && !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+
+	if err := w.index(); err != nil {
+		return err
+	}
+
+	pending := []*volumeEntry{}
+	for _, entry := range w.pending {
+		if entry.payloadStart >= 0 && entry.payloadStart <= committed {
+			remaining := int64(0)
+			if entry.payload != nil {
+				remaining = entry.payload.Size() - entry.written
+			}
+
+			// The entry is complete; the padding after it isn't needed
+			if entry.payloadStart+remaining <= committed {
+				if entry.payload != nil {
+					_ = entry.payload.Close()
+				}
+
+				continue
+			}
+
+			// The header has reached the drive, so continue the payload on the next volume
+			entry.written += committed - entry.payloadStart
+		}
+
+		entry.payloadStart = -1
+		entry.end = -1
+
+		pending = append(pending, entry)
+	}
+
+	w.volume++
+	if err := w.o.backend.ChangeVolume(w.volume); err != nil {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.record = 0
+	w.block = 0
+	w.offset = 0
+	w.pending = pending
+	w.hdrs = []*tar.Header{}
+
+	for _, entry := range pending {
+		w.hdrs = append(w.hdrs, entry.header())
+	}
+
+	if err := w.begin(); err != nil {
+		return err
+	}
+
+	for _, entry := range pending {
+		if err := w.writeEntry(entry); err != nil {
+			return w.nextVolume(err)
+		}
+	}
+
+	w.release()
+
+	return nil
+}
+
+// close writes the trailer and indexes the current volume
+func (w *volumeWriter) close() error {
+	if err := w.cleanup(&w.dirty); err != nil {
+		if err := w.nextVolume(err); err != nil {
+			return err
+		}
+
+		return w.close()
+	}
+
+	w.discard()
+	w.written += int64(w.physical.BytesRead)
+
+	if w.o.dryRun {
+		return w.preview()
+	}
+
+	if err := w.o.backend.CloseWriter(); err != nil {
+		return err
+	}
+
+	if err := w.index(); err != nil {
+		return err
+	}
+
+	if w.o.metadata.Journal == nil {
+		return nil
+	}
+
+	return w.o.metadata.Journal.Commit()
+}
+
+// begin records the position from which the current volume is written, and the headers which are written to it again, in the journal
+func (w *volumeWriter) begin() error {
+	if w.o.metadata.Journal == nil || w.o.dryRun {
+		return nil
+	}
+
+	hdrs := []*config.Header{}
+	for _, hdr := range w.hdrs {
+		dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+		if err != nil {
+			return err
+		}
+
+		hdrs = append(hdrs, converters.DBHeaderToConfigHeader(dbhdr))
+	}
+
+	return w.o.metadata.Journal.Begin(&config.JournalSession{
+		Volume:       int64(w.volume),
+		Record:       w.record,
+		Block:        w.block,
+		Offset:       int64(w.offset),
+		Overwrite:    w.purge,
+		Initializing: w.initializing,
+
+		Headers: hdrs,
+	})
+}
+
+// journal records hdr before it is written so that it can be indexed if the process dies before indexing it
+func (w *volumeWriter) journal(hdr *tar.Header) error {
+	if w.o.metadata.Journal == nil || w.o.dryRun {
+		return nil
+	}
+
+	dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+	if err != nil {
+		return err
+	}
+
+	return w.o.metadata.Journal.Append(converters.DBHeaderToConfigHeader(dbhdr))
+}
+
+// preview reports the index rows which would change instead of indexing the headers
+func (w *volumeWriter) preview() error {
+	if w.o.onHeader == nil {
+		return nil
+	}
+
+	// All headers are removed from the index when overwriting
+	if w.purge {
+		w.purge = false
+
+		dbhdrs, err := w.o.metadata.Metadata.GetHeaders(context.Background())
+		if err != nil {
+			return err
+		}
+
+		for _, dbhdr := range dbhdrs {
+			w.o.onHeader(&config.HeaderEvent{
+				Type:    config.HeaderEventTypeDelete,
+				Indexed: true,
+				Header:  dbhdr,
+			})
+		}
+	}
+
+	for _, hdr := range w.hdrs {
+		dbhdr, err := converters.TarHeaderToDBHeader(-1, -1, -1, -1, hdr)
+		if err != nil {
+			return err
+		}
+		dbhdr.Volume = int64(w.volume)
+
+		w.onHeader(converters.DBHeaderToConfigHeader(dbhdr))
+	}
+
+	return nil
+}
+
+// abort writes the trailer and indexes the headers which have already been written before returning err,
+// so that the index matches what has reached the tape or tar file if an operation fails or is cancelled;
+// if that fails too, both errors are returned
+func (w *volumeWriter) abort(err error) error {
+	if closeErr := w.close(); closeErr != nil {
+		return errors.Join(err, closeErr)
+	}
+
+	return err
+}
+
+// index isn't cancellable so that the index can't fall behind the tape or tar file
+func (w *volumeWriter) index() error {
+	reader, err := w.o.backend.GetReader()
+	if err != nil {
+		return err
+	}
+	defer w.o.backend.CloseReader()
+
+	purge := w.purge
+	w.purge = false
+
+	hdrs := w.hdrs
+	return recovery.Index(
+		reader,
+		w.o.backend.MagneticTapeIO,
+		w.o.metadata,
+		w.o.pipes,
+		w.o.crypto,
+
+		int(w.record),
+		int(w.block),
+		purge,
+		w.initializing,
+		w.offset,
+
+		func(hdr *tar.Header, i int) error {
+			if len(hdrs) <= i {
+				return config.ErrTarHeaderMissing
+			}
+
+			*hdr = *hdrs[i]
+
+			return nil
+		},
+		func(hdr *tar.Header, isRegular bool) error {
+			return nil // We sign before writing, no need to verify
+		},
+
+		w.onHeader,
+		nil,
+		nil,
+	)
+}
+
+// volumeReader reads from the tape or tar file, switching to other volumes on demand
+type volumeReader struct {
+	o      *Operations
+	reader config.DriveReaderConfig
+	open   bool
+}
+
+func (o *Operations) newVolumeReader() (*volumeReader, error) {
+	reader, err := o.backend.GetReader()
+	if err != nil {
+		return nil, err
+	}
+
+	return &volumeReader{
+		o:      o,
+		reader: reader,
+		open:   true,
+	}, nil
+}
+
+// changeVolume asks for the volume and opens it
+func (r *volumeReader) changeVolume(volume int) (config.DriveReaderConfig, error) {
+	if r.o.backend.ChangeVolume == nil {
+		return config.DriveReaderConfig{}, config.ErrVolumeChangeUnsupported
+	}
+
+	r.open = false
+	if err := r.o.backend.CloseReader(); err != nil {
+		return config.DriveReaderConfig{}, err
+	}
+
+	if err := r.o.backend.ChangeVolume(volume); err != nil {
+		return config.DriveReaderConfig{}, err
+	}
+
+	reader, err := r.o.backend.GetReader()
+	if err != nil {
+		return config.DriveReaderConfig{}, err
+	}
+	r.open = true
+	r.reader = reader
+
+	return reader, nil
+}
+
+// seek switches to the volume if the reader isn't already on it
+func (r *volumeReader) seek(volume int) (config.DriveReaderConfig, error) {
+	if volume == r.reader.Volume {
+		return r.reader, nil
+	}
+
+	return r.changeVolume(volume)
+}
+
+func (r *volumeReader) close() {
+	if r.open {
+		_ = r.o.backend.CloseReader()
+	}
+
+	r.open = false
+}
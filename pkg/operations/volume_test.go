@@ -0,0 +1,201 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/tape"
+)
+
+// fullWriter fails with ENOSPC once it has written the capacity of a volume, like a tape drive at the end of the tape
+type fullWriter struct {
+	w         io.Writer
+	remaining int64
+}
+
+func (w *fullWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) <= w.remaining {
+		n, err := w.w.Write(p)
+		w.remaining -= int64(n)
+
+		return n, err
+	}
+
+	n, err := w.w.Write(p[:w.remaining])
+	w.remaining -= int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	return n, syscall.ENOSPC
+}
+
+// getTestContent creates content of size which doesn't repeat within a record
+func getTestContent(seed int, size int) string {
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte((i*7 + seed*13 + i/251) % 256)
+	}
+
+	return string(content)
+}
+
+var archiveVolumesTests = []struct {
+	name         string
+	capacity     int64
+	changeVolume bool
+	runs         []map[string]string
+	wantVolumes  int
+	wantErr      bool
+}{
+	{
+		"Can archive to one volume",
+		10 * 512 * recordSize,
+		true,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 1000), "b.txt": getTestContent(1, 2000)},
+		},
+		1,
+		false,
+	},
+	{
+		"Can continue file on next volume",
+		2 * 512 * recordSize,
+		true,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000)},
+		},
+		2,
+		false,
+	},
+	{
+		"Can continue files on next volumes with capacity which isn't a multiple of the record size",
+		25000,
+		true,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 20000), "b.txt": getTestContent(1, 30000), "c.txt": getTestContent(2, 100)},
+		},
+		3,
+		false,
+	},
+	{
+		"Can continue on last volume of previous run",
+		3 * 512 * recordSize,
+		true,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 40000)},
+			{"b.txt": getTestContent(1, 40000)},
+		},
+		3,
+		false,
+	},
+	{
+		"Can not continue without volume changes",
+		2 * 512 * recordSize,
+		false,
+		[]map[string]string{
+			{"a.txt": getTestContent(0, 30000)},
+		},
+		1,
+		true,
+	},
+}
+
+func TestOperations_ArchiveVolumes(t *testing.T) {
+	for _, tt := range archiveVolumesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			to.ops.backend.GetWriter = func() (config.DriveWriterConfig, error) {
+				writer, err := to.tm.GetWriter()
+				if err != nil {
+					return config.DriveWriterConfig{}, err
+				}
+
+				// Limit the volume to its capacity, including what previous runs have written to it
+				written := int64(0)
+				if info, err := os.Stat(tape.GetVolumePath(to.drive, writer.Volume)); err == nil {
+					written = info.Size()
+				}
+
+				writer.Drive = &fullWriter{w: writer.Drive, remaining: tt.capacity - written}
+
+				return writer, nil
+			}
+
+			if !tt.changeVolume {
+				to.ops.backend.ChangeVolume = nil
+			}
+
+			want := map[string]string{}
+			for i, files := range tt.runs {
+				src := filepath.Join(dir, "src"+strconv.Itoa(i))
+				if err := writeTestFiles(src, files); err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, i == 0, false); (err != nil) != tt.wantErr {
+					t.Errorf("Archive() error = %v, wantErr %v", err, tt.wantErr)
+
+					return
+				} else if err != nil {
+					if !errors.Is(err, syscall.ENOSPC) {
+						t.Errorf("Archive() error = %v, want %v", err, syscall.ENOSPC)
+					}
+
+					return
+				}
+
+				for name, content := range files {
+					want[name] = content
+				}
+			}
+
+			volume, err := to.ops.metadata.Metadata.GetLastIndexedVolume(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if int(volume) != tt.wantVolumes-1 {
+				t.Errorf("Archive() last volume = %v, want %v", volume, tt.wantVolumes-1)
+			}
+
+			for i := 0; i < tt.wantVolumes; i++ {
+				info, err := os.Stat(tape.GetVolumePath(to.drive, i))
+				if err != nil {
+					t.Errorf("Archive() volume %v error = %v, wantErr %v", i, err, false)
+
+					continue
+				}
+
+				if info.Size() > tt.capacity {
+					t.Errorf("Archive() volume %v size = %v, want at most %v", i, info.Size(), tt.capacity)
+				}
+			}
+
+			got, err := restoreTestFiles(to.ops, filepath.Join(dir, "dst"), want)
+			if err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Restore() got %v files, want %v files with equal content", len(got), len(want))
+			}
+		})
+	}
+}
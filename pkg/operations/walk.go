@@ -0,0 +1,158 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/xattrext"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/filter"
+)
+
+type walkEntry struct {
+	path  string
+	rel   string
+	rules *filter.Rules
+}
+
+// NewWalkSource creates a source for Archive and Update which walks the file or directory at root in lexical order.
+// A relative root is resolved against workdir, or the working directory if workdir is empty, and the files are named by their path from there.
+// Files excluded by rules, including the ones in ignore files, are skipped; if rules is nil, all files are included.
+func NewWalkSource(workdir string, root string, rules *filter.Rules) func() (config.FileConfig, error) {
+	stack := []walkEntry{{path: root, rel: ".", rules: rules}}
+
+	return func() (config.FileConfig, error) {
+		for len(stack) > 0 {
+			entry := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			p := entry.path
+			if workdir != "" && !filepath.IsAbs(p) {
+				p = filepath.Join(workdir, p)
+			}
+
+			info, err := os.Lstat(p)
+			if err != nil {
+				return config.FileConfig{}, err
+			}
+
+			if entry.rules != nil && entry.rules.Excluded(filepath.ToSlash(entry.rel), info.IsDir()) {
+				continue
+			}
+
+			if info.IsDir() {
+				children := entry.rules
+				if children != nil {
+					if children, err = children.Load(p, filepath.ToSlash(entry.rel)); err != nil {
+						return config.FileConfig{}, err
+					}
+				}
+
+				names, err := readDirNames(p)
+				if err != nil {
+					return config.FileConfig{}, err
+				}
+
+				for i := len(names) - 1; i >= 0; i-- {
+					stack = append(stack, walkEntry{
+						path:  filepath.Join(entry.path, names[i]),
+						rel:   filepath.Join(entry.rel, names[i]),
+						rules: children,
+					})
+				}
+			}
+
+			return getFileConfig(p, entry.path, info)
+		}
+
+		return config.FileConfig{}, io.EOF
+	}
+}
+
+// NewWalkFilter creates a function which checks whether NewWalkSource with the same arguments would skip the file or directory
+// at name, which is below root, because it or one of its parent directories is excluded by rules or the ignore files on the way to it.
+// It also works for files which don't exist anymore.
+func NewWalkFilter(workdir string, root string, rules *filter.Rules) func(name string, isDir bool) (bool, error) {
+	return func(name string, isDir bool) (bool, error) {
+		if rules == nil {
+			return false, nil
+		}
+
+		rel, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(name))
+		if err != nil {
+			return false, err
+		}
+
+		if rel == "." {
+			return false, nil
+		}
+
+		dir := root
+		if workdir != "" && !filepath.IsAbs(dir) {
+			dir = filepath.Join(workdir, dir)
+		}
+
+		current := rules
+		dirRel := "."
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for i, part := range parts {
+			if current, err = current.Load(dir, filepath.ToSlash(dirRel)); err != nil {
+				return false, err
+			}
+
+			dir = filepath.Join(dir, part)
+			dirRel = filepath.Join(dirRel, part)
+
+			if current.Excluded(filepath.ToSlash(dirRel), i < len(parts)-1 || isDir) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+}
+
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return []string{}, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return []string{}, err
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func getFileConfig(path string, name string, info os.FileInfo) (config.FileConfig, error) {
+	var err error
+	link := ""
+	xattrs := map[string]string{}
+	if info.Mode()&os.ModeSymlink == os.ModeSymlink {
+		if link, err = os.Readlink(path); err != nil {
+			return config.FileConfig{}, err
+		}
+	} else {
+		if xattrs, err = xattrext.Get(path); err != nil {
+			return config.FileConfig{}, err
+		}
+	}
+
+	return config.FileConfig{
+		GetFile: func() (io.ReadSeekCloser, error) {
+			return os.Open(path)
+		},
+		Info:   info,
+		Path:   filepath.ToSlash(name),
+		Link:   filepath.ToSlash(link),
+		Xattrs: xattrs,
+	}, nil
+}
@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/filter"
+)
+
+var walkSourceTests = []struct {
+	name    string
+	workdir bool
+	root    string
+	ignore  string // Content of the ignore file in the root, which is only used if it is set
+	want    map[string]string
+}{
+	{
+		"Can walk absolute directory",
+		false,
+		"src",
+		"",
+		map[string]string{"src": "", "src/a.txt": "a", "src/b/c.txt": "c", "src/b": ""},
+	},
+	{
+		"Can walk directory relative to workdir",
+		true,
+		"src",
+		"",
+		map[string]string{"src": "", "src/a.txt": "a", "src/b/c.txt": "c", "src/b": ""},
+	},
+	{
+		"Can walk file relative to workdir",
+		true,
+		"src/b/c.txt",
+		"",
+		map[string]string{"src/b/c.txt": "c"},
+	},
+	{
+		"Can walk directory with ignore file",
+		true,
+		"src",
+		"b/\n",
+		map[string]string{"src": "", "src/a.txt": "a", "src/" + filter.IgnoreFileName: "b/\n"}, // The ignore file itself is archived
+	},
+}
+
+func TestNewWalkSource(t *testing.T) {
+	for _, tt := range walkSourceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := writeTestFiles(filepath.Join(dir, "src"), map[string]string{"a.txt": "a", "b/c.txt": "c"}); err != nil {
+				t.Fatal(err)
+			}
+
+			workdir, root := "", filepath.Join(dir, filepath.FromSlash(tt.root))
+			if tt.workdir {
+				workdir, root = dir, tt.root
+			}
+
+			var rules *filter.Rules
+			if tt.ignore != "" {
+				if err := writeTestFiles(filepath.Join(dir, "src"), map[string]string{filter.IgnoreFileName: tt.ignore}); err != nil {
+					t.Fatal(err)
+				}
+
+				var err error
+				if rules, err = filter.NewRules([]string{}, []string{}, []string{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			getSrc := NewWalkSource(workdir, root, rules)
+
+			got := map[string]string{}
+			for {
+				file, err := getSrc()
+				if err == io.EOF {
+					break
+				}
+
+				if err != nil {
+					t.Errorf("NewWalkSource() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				content := []byte{}
+				if file.Info.Mode().IsRegular() {
+					f, err := file.GetFile()
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if content, err = io.ReadAll(f); err != nil {
+						t.Fatal(err)
+					}
+
+					if err := f.Close(); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				name, err := filepath.Rel(dir, filepath.Join(workdir, filepath.FromSlash(file.Path)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got[filepath.ToSlash(name)] = string(content)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewWalkSource() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/xattrext"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/sinks"
+)
+
+// capNetBindService is a `security.capability` value which grants CAP_NET_BIND_SERVICE, like `setcap cap_net_bind_service=p`
+const capNetBindService = "\x01\x00\x00\x02\x00\x04\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
+
+// aclReadUser is a `system.posix_acl_access` value which grants read access to the user with ID 1000, like `setfacl -m u:1000:r` on a file with mode 0640
+const aclReadUser = "\x02\x00\x00\x00" + // Version
+	"\x01\x00\x06\x00\xff\xff\xff\xff" + // Owner
+	"\x02\x00\x04\x00\xe8\x03\x00\x00" + // User 1000
+	"\x04\x00\x04\x00\xff\xff\xff\xff" + // Group
+	"\x10\x00\x04\x00\xff\xff\xff\xff" + // Mask
+	"\x20\x00\x00\x00\xff\xff\xff\xff" // Others
+
+var restoreXattrsTests = []struct {
+	name       string
+	mode       os.FileMode
+	xattrs     map[string]string
+	privileged bool
+	want       map[string]string
+}{
+	{
+		"Can restore user attribute",
+		0600,
+		map[string]string{"user.comment": "archived"},
+		false,
+		map[string]string{"user.comment": "archived"},
+	},
+	{
+		"Can restore user attribute of read-only file",
+		0400,
+		map[string]string{"user.comment": "archived"},
+		false,
+		map[string]string{"user.comment": "archived"},
+	},
+	{
+		"Can skip capability without privileges",
+		0755,
+		map[string]string{"user.comment": "archived", "security.capability": capNetBindService},
+		false,
+		map[string]string{"user.comment": "archived"},
+	},
+	{
+		"Can restore capability with privileges",
+		0755,
+		map[string]string{"security.capability": capNetBindService},
+		true,
+		map[string]string{"security.capability": capNetBindService},
+	},
+	{
+		"Can skip ACL without privileges",
+		0640,
+		map[string]string{"system.posix_acl_access": aclReadUser},
+		false,
+		map[string]string{},
+	},
+	{
+		"Can restore ACL with privileges",
+		0640,
+		map[string]string{"system.posix_acl_access": aclReadUser},
+		true,
+		map[string]string{"system.posix_acl_access": aclReadUser},
+	},
+}
+
+func TestOperations_RestoreXattrs(t *testing.T) {
+	for _, tt := range restoreXattrsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.privileged && os.Geteuid() != 0 {
+				t.Skip("setting capabilities requires root")
+			}
+
+			dir := t.TempDir()
+
+			src := filepath.Join(dir, "src")
+			if err := writeTestFiles(src, map[string]string{"a.txt": "a"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := xattrext.Set(filepath.Join(src, "a.txt"), tt.xattrs, true); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.Chmod(filepath.Join(src, "a.txt"), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, err := xattrext.Get(filepath.Join(src, "a.txt")); err != nil || len(got) == 0 {
+				t.Skip("file system doesn't support extended attributes")
+			}
+
+			to, err := createOperations(filepath.Join(dir, "archive"), config.PipeConfig{Compression: config.NoneKey, Encryption: config.NoneKey, Signature: config.NoneKey}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := to.ops.Archive(newTestSource(src), config.CompressionLevelFastestKey, true, false); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+				t.Fatal(err)
+			}
+
+			sink := sinks.NewFilesystemSink(false, nil, nil, tt.privileged)
+			if err := to.ops.Restore(sink, "a.txt", dst, false, config.ConflictPolicyOverwrite, nil); err != nil {
+				t.Errorf("Restore() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			got, err := xattrext.Get(filepath.Join(dst, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Restore() xattrs = %q, want %q", got, tt.want)
+			}
+
+			info, err := os.Stat(filepath.Join(dst, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode() != tt.mode {
+				t.Errorf("Restore() mode = %v, want %v", info.Mode(), tt.mode)
+			}
+		})
+	}
+}
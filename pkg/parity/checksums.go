@@ -0,0 +1,57 @@
+package parity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var checksumMagic = []byte("STFSPAR1")
+
+// encodeChecksums creates the last record of a group, which contains the checksums of its data and parity records.
+// The table is repeated to fill the record so that it survives partial damage.
+func encodeChecksums(shards [][]byte, dataShards int, parityShards int, recordSize int) []byte {
+	table := &bytes.Buffer{}
+	table.Write(checksumMagic)
+	_ = binary.Write(table, binary.BigEndian, uint16(dataShards))
+	_ = binary.Write(table, binary.BigEndian, uint16(parityShards))
+
+	for _, shard := range shards {
+		_ = binary.Write(table, binary.BigEndian, crc32.ChecksumIEEE(shard))
+	}
+
+	_ = binary.Write(table, binary.BigEndian, crc32.ChecksumIEEE(table.Bytes()))
+
+	record := make([]byte, recordSize)
+	for i := 0; i+table.Len() <= len(record); i += table.Len() {
+		copy(record[i:], table.Bytes())
+	}
+
+	return record
+}
+
+// decodeChecksums returns the checksums from the first intact copy of the table in record
+func decodeChecksums(record []byte, dataShards int, parityShards int) ([]uint32, bool) {
+	shards := dataShards + parityShards
+	length := len(checksumMagic) + 4 + (shards * 4) + 4
+
+	for i := 0; i+length <= len(record); i += length {
+		table := record[i : i+length]
+
+		if !bytes.Equal(table[:len(checksumMagic)], checksumMagic) ||
+			binary.BigEndian.Uint16(table[len(checksumMagic):]) != uint16(dataShards) ||
+			binary.BigEndian.Uint16(table[len(checksumMagic)+2:]) != uint16(parityShards) ||
+			binary.BigEndian.Uint32(table[length-4:]) != crc32.ChecksumIEEE(table[:length-4]) {
+			continue
+		}
+
+		checksums := make([]uint32, shards)
+		for j := range checksums {
+			checksums[j] = binary.BigEndian.Uint32(table[len(checksumMagic)+4+(j*4):])
+		}
+
+		return checksums, true
+	}
+
+	return nil, false
+}
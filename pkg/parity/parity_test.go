@@ -0,0 +1,403 @@
+package parity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+const (
+	recordSize   = 2
+	dataShards   = 4
+	parityShards = 2
+)
+
+var parityTests = []struct {
+	name          string
+	size          int   // Bytes of data to write
+	damaged       []int // Records to overwrite with garbage, counted including parity and checksum records
+	cut           int64 // Bytes to cut off the end of the file
+	wantCorrected int
+	wantSize      int // Bytes of data which can be read back
+	wantErr       error
+}{
+	{
+		"Can read intact records",
+		3 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{},
+		0,
+		0,
+		3 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		nil,
+	},
+	{
+		"Can read incomplete last group",
+		dataShards*recordSize*config.MagneticTapeBlockSize + 100,
+		[]int{},
+		0,
+		0,
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize, // The last group is filled with zeros
+		nil,
+	},
+	{
+		"Can rebuild damaged data record",
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{1},
+		0,
+		1,
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		nil,
+	},
+	{
+		"Can rebuild as many damaged records per group as there are parity records",
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{0, 3, dataShards + parityShards + 1 + 2, dataShards + parityShards + 1 + 3},
+		0,
+		4,
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		nil,
+	},
+	{
+		"Can read group with damaged checksum record",
+		dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{dataShards + parityShards},
+		0,
+		0,
+		dataShards * recordSize * config.MagneticTapeBlockSize,
+		nil,
+	},
+	{
+		"Can read intact records of cut off group",
+		2 * dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{},
+		int64(dataShards+parityShards+1-2) * recordSize * config.MagneticTapeBlockSize,
+		0,
+		(dataShards + 2) * recordSize * config.MagneticTapeBlockSize,
+		nil,
+	},
+	{
+		"Can not rebuild more damaged records than there are parity records",
+		dataShards * recordSize * config.MagneticTapeBlockSize,
+		[]int{0, 1, 2},
+		0,
+		0,
+		0,
+		config.ErrParityInsufficient,
+	},
+}
+
+func TestReader_Read(t *testing.T) {
+	for _, tt := range parityTests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := make([]byte, tt.size)
+			for i := range want {
+				want[i] = byte(i*7 + i/509)
+			}
+
+			f, err := os.Create(filepath.Join(t.TempDir(), "drive.tar"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			w, err := NewWriter(f, true, 0, recordSize, dataShards, parityShards)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Write(want); err != nil {
+				t.Errorf("Writer.Write() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if err := w.Close(); err != nil {
+				t.Errorf("Writer.Close() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			garbage := bytes.Repeat([]byte{0xff}, recordSize*config.MagneticTapeBlockSize)
+			for _, record := range tt.damaged {
+				if _, err := f.WriteAt(garbage, int64(record*recordSize*config.MagneticTapeBlockSize)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.cut > 0 {
+				info, err := f.Stat()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.Truncate(info.Size() - tt.cut); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			corrected := 0
+			reader, _, err := NewReader(config.DriveReaderConfig{Drive: f, DriveIsRegular: true}, nil, recordSize, dataShards, parityShards, func(event *config.CorrectionEvent) {
+				corrected += event.Corrected
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := io.ReadAll(reader.Drive)
+			if err != tt.wantErr {
+				t.Errorf("Reader.Read() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(got) != tt.wantSize {
+				t.Errorf("Reader.Read() size = %v, want %v", len(got), tt.wantSize)
+			}
+
+			if len(want) > len(got) {
+				want = want[:len(got)]
+			}
+
+			if !bytes.Equal(got[:len(want)], want) {
+				t.Errorf("Reader.Read() got different content")
+			}
+
+			if corrected != tt.wantCorrected {
+				t.Errorf("Reader.Read() corrected = %v, want %v", corrected, tt.wantCorrected)
+			}
+		})
+	}
+}
+
+// testTape stores records like a tape drive, which can only write whole records; nil records are file marks
+type testTape struct {
+	records [][]byte
+	pos     int
+}
+
+func (t *testTape) Write(p []byte) (int, error) {
+	if len(p) != recordSize*config.MagneticTapeBlockSize {
+		return 0, errPartialRecord
+	}
+
+	t.records = append(t.records[:t.pos], append([]byte{}, p...))
+	t.pos++
+
+	return len(p), nil
+}
+
+func (t *testTape) Read(p []byte) (int, error) {
+	if t.pos >= len(t.records) {
+		return 0, io.EOF
+	}
+
+	record := t.records[t.pos]
+	t.pos++
+
+	if record == nil {
+		return 0, io.EOF
+	}
+
+	return copy(p, record), nil
+}
+
+func (t *testTape) Seek(offset int64, whence int) (int64, error) {
+	return -1, errTapeSeek
+}
+
+func (t *testTape) Fd() uintptr {
+	return 0
+}
+
+func (t *testTape) GetCurrentRecordFromTape(fd uintptr) (int64, error) {
+	return int64(t.pos), nil
+}
+
+func (t *testTape) GoToEndOfTape(fd uintptr) error {
+	t.pos = len(t.records)
+
+	return nil
+}
+
+func (t *testTape) GoToNextFileOnTape(fd uintptr) error {
+	for t.pos < len(t.records) {
+		t.pos++
+
+		if t.records[t.pos-1] == nil {
+			return nil
+		}
+	}
+
+	return io.EOF
+}
+
+func (t *testTape) EjectTape(fd uintptr) error {
+	return nil
+}
+
+func (t *testTape) SeekToRecordOnTape(fd uintptr, record int32) error {
+	t.pos = int(record)
+
+	return nil
+}
+
+var (
+	errPartialRecord = errors.New("tape drives can only write whole records")
+	errTapeSeek      = errors.New("tape drives can't seek")
+)
+
+var parityTapeTests = []struct {
+	name          string
+	sessions      []int // Bytes of data to write in each session, which are separated by file marks
+	damaged       []int // Records to overwrite with garbage, counted including parity, checksum and file mark records
+	wantCorrected int
+}{
+	{
+		"Can read session",
+		[]int{dataShards*recordSize*config.MagneticTapeBlockSize + 100},
+		[]int{},
+		0,
+	},
+	{
+		"Can read sessions after file marks",
+		[]int{100, dataShards * recordSize * config.MagneticTapeBlockSize, 3 * dataShards * recordSize * config.MagneticTapeBlockSize},
+		[]int{},
+		0,
+	},
+	{
+		"Can rebuild damaged data records of sessions",
+		[]int{100, 2 * dataShards * recordSize * config.MagneticTapeBlockSize},
+		[]int{1, 2*(dataShards+parityShards+1) + 1},
+		2,
+	},
+}
+
+func TestReader_ReadTape(t *testing.T) {
+	for _, tt := range parityTapeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tape := &testTape{}
+
+			sessions := [][]byte{}
+			for i, size := range tt.sessions {
+				session := make([]byte, size)
+				for j := range session {
+					session[j] = byte(i*31 + j*7 + j/509)
+				}
+				sessions = append(sessions, session)
+
+				w, err := NewWriter(tape, false, int64(tape.pos), recordSize, dataShards, parityShards)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := w.Write(session); err != nil {
+					t.Errorf("Writer.Write() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				if err := w.Close(); err != nil {
+					t.Errorf("Writer.Close() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				// Write the file mark
+				tape.records = append(tape.records, nil)
+				tape.pos++
+			}
+
+			garbage := bytes.Repeat([]byte{0xff}, recordSize*config.MagneticTapeBlockSize)
+			for _, record := range tt.damaged {
+				if tape.records[record] == nil {
+					t.Fatalf("record %v is a file mark", record)
+				}
+
+				tape.records[record] = garbage
+			}
+
+			tape.pos = 0
+
+			corrected := 0
+			reader, mt, err := NewReader(config.DriveReaderConfig{Drive: tape, DriveIsRegular: false}, tape, recordSize, dataShards, parityShards, func(event *config.CorrectionEvent) {
+				corrected += event.Corrected
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Read the sessions one after another, like when indexing
+			records := []int64{}
+			for i, want := range sessions {
+				if i > 0 {
+					if err := mt.GoToNextFileOnTape(reader.Drive.Fd()); err != nil {
+						t.Errorf("Reader.GoToNextFileOnTape() error = %v, wantErr %v", err, false)
+
+						return
+					}
+				}
+
+				record, err := mt.GetCurrentRecordFromTape(reader.Drive.Fd())
+				if err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, record)
+
+				got := make([]byte, len(want))
+				if _, err := io.ReadFull(reader.Drive, got); err != nil {
+					t.Errorf("Reader.Read() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				if !bytes.Equal(got, want) {
+					t.Errorf("Reader.Read() got different content for session %v", i)
+				}
+			}
+
+			// There is nothing after the file mark of the last session
+			if err := mt.GoToNextFileOnTape(reader.Drive.Fd()); err != nil {
+				t.Errorf("Reader.GoToNextFileOnTape() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if _, err := reader.Drive.Read(make([]byte, 1)); err != io.EOF {
+				t.Errorf("Reader.Read() error = %v, want %v", err, io.EOF)
+			}
+
+			if corrected != tt.wantCorrected {
+				t.Errorf("Reader.Read() corrected = %v, want %v", corrected, tt.wantCorrected)
+			}
+
+			// Seek to the sessions in reverse, like when restoring
+			for i := len(sessions) - 1; i >= 0; i-- {
+				if err := mt.SeekToRecordOnTape(reader.Drive.Fd(), int32(records[i])); err != nil {
+					t.Errorf("Reader.SeekToRecordOnTape() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				got := make([]byte, len(sessions[i]))
+				if _, err := io.ReadFull(reader.Drive, got); err != nil {
+					t.Errorf("Reader.Read() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				if !bytes.Equal(got, sessions[i]) {
+					t.Errorf("Reader.Read() got different content for session %v after seeking", i)
+				}
+			}
+		})
+	}
+}
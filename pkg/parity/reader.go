@@ -0,0 +1,314 @@
+package parity
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// Reader reads the data records written by Writer and rebuilds damaged ones from the parity records of their group.
+// Record numbers on tapes exclude the parity and checksum records, so Reader also implements config.MagneticTapeIO to translate them.
+type Reader struct {
+	f            config.ReadSeekFder
+	isRegular    bool
+	mt           config.MagneticTapeIO
+	decoder      reedsolomon.Encoder
+	dataShards   int
+	parityShards int
+	recordSize   int
+	volume       int
+
+	onCorrection func(event *config.CorrectionEvent)
+
+	offset int64 // Logical offset, which excludes the parity and checksum records
+	group  int64
+	data   []byte // Data records of the current group
+	record int64  // Physical record at which the tape is; -1 if unknown
+	eof    int64  // Physical record at which the last read ended, i.e. at a file mark; -1 if it didn't
+}
+
+// NewReader wraps the drive of reader and mt, which has to be used with the returned drive instead
+func NewReader(
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	recordSize int,
+	dataShards int,
+	parityShards int,
+
+	onCorrection func(event *config.CorrectionEvent),
+) (config.DriveReaderConfig, config.MagneticTapeIO, error) {
+	if r, ok := reader.Drive.(*Reader); ok {
+		return reader, r, nil
+	}
+
+	decoder, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return config.DriveReaderConfig{}, nil, err
+	}
+
+	r := &Reader{
+		f:            reader.Drive,
+		isRegular:    reader.DriveIsRegular,
+		mt:           mt,
+		decoder:      decoder,
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		recordSize:   config.MagneticTapeBlockSize * recordSize,
+		volume:       reader.Volume,
+
+		onCorrection: onCorrection,
+
+		group:  -1,
+		record: -1,
+		eof:    -1,
+	}
+
+	return config.DriveReaderConfig{
+		Drive:          r,
+		DriveIsRegular: reader.DriveIsRegular,
+		Volume:         reader.Volume,
+	}, r, nil
+}
+
+func (r *Reader) groupSize() int64 {
+	return int64((r.dataShards + r.parityShards + 1) * r.recordSize)
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	groupData := int64(r.dataShards * r.recordSize)
+
+	group := r.offset / groupData
+	if group != r.group {
+		if err := r.readGroup(group); err != nil {
+			return 0, err
+		}
+	}
+
+	// The last group can be cut off, i.e. because the volume is full
+	within := r.offset - (group * groupData)
+	if within >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.data[within:])
+	r.offset += int64(n)
+
+	return n, nil
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		r.offset = offset
+	case io.SeekCurrent:
+		r.offset += offset
+	case io.SeekEnd:
+		size, err := r.f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+
+		groupData := int64(r.dataShards * r.recordSize)
+		rest := size % r.groupSize()
+		if rest > groupData {
+			rest = groupData
+		}
+
+		r.offset = ((size / r.groupSize()) * groupData) + ((rest / int64(r.recordSize)) * int64(r.recordSize)) + offset
+	}
+
+	return r.offset, nil
+}
+
+func (r *Reader) Fd() uintptr {
+	return r.f.Fd()
+}
+
+// GetCurrentRecordFromTape returns the record at the logical offset
+func (r *Reader) GetCurrentRecordFromTape(fd uintptr) (int64, error) {
+	return r.offset / int64(r.recordSize), nil
+}
+
+// GoToEndOfTape continues at the first group after the end of the tape
+func (r *Reader) GoToEndOfTape(fd uintptr) error {
+	if err := r.mt.GoToEndOfTape(fd); err != nil {
+		return err
+	}
+
+	return r.align(fd)
+}
+
+// GoToNextFileOnTape continues at the first group after the next file mark
+func (r *Reader) GoToNextFileOnTape(fd uintptr) error {
+	// Reading the last group of a file can already have read over its file mark, but not over the end of the data
+	if r.eof >= 0 {
+		record, err := r.mt.GetCurrentRecordFromTape(fd)
+		if err != nil {
+			return err
+		}
+
+		if record > r.eof {
+			return r.align(fd)
+		}
+	}
+
+	if err := r.mt.GoToNextFileOnTape(fd); err != nil {
+		return err
+	}
+
+	return r.align(fd)
+}
+
+func (r *Reader) EjectTape(fd uintptr) error {
+	return r.mt.EjectTape(fd)
+}
+
+// SeekToRecordOnTape continues at the logical record; the tape is positioned once the group of the record is read
+func (r *Reader) SeekToRecordOnTape(fd uintptr, record int32) error {
+	r.offset = int64(record) * int64(r.recordSize)
+
+	return nil
+}
+
+// align continues at the first group at or after the current position of the tape, since Writer starts groups at multiples of the group size
+func (r *Reader) align(fd uintptr) error {
+	record, err := r.mt.GetCurrentRecordFromTape(fd)
+	if err != nil {
+		return err
+	}
+
+	groupRecords := int64(r.dataShards + r.parityShards + 1)
+	group := (record + groupRecords - 1) / groupRecords
+
+	r.offset = group * int64(r.dataShards*r.recordSize)
+	r.group = -1
+	r.record = record
+	r.eof = -1
+
+	return nil
+}
+
+// seekRecord positions the drive at the physical record
+func (r *Reader) seekRecord(record int64) error {
+	if r.isRegular {
+		_, err := r.f.Seek(record*int64(r.recordSize), io.SeekStart)
+
+		return err
+	}
+
+	// Tapes are read sequentially, so they are only positioned if they are elsewhere
+	if r.record == record {
+		return nil
+	}
+
+	if err := r.mt.SeekToRecordOnTape(r.f.Fd(), int32(record)); err != nil {
+		return err
+	}
+	r.record = record
+
+	return nil
+}
+
+func (r *Reader) readGroup(group int64) error {
+	shards := make([][]byte, r.dataShards+r.parityShards+1)
+	damaged := map[int]bool{}
+	complete := true
+
+	r.eof = -1
+	for i := range shards {
+		record := (group * int64(len(shards))) + int64(i)
+		if err := r.seekRecord(record); err != nil {
+			return err
+		}
+
+		shard := make([]byte, r.recordSize)
+		if _, err := io.ReadFull(r.f, shard); err != nil {
+			// The position of tapes is unknown after file marks and errors
+			r.record = -1
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				complete = i >= r.dataShards+r.parityShards
+				r.eof = record
+
+				break
+			}
+
+			// Rebuild records which can't be read
+			damaged[i] = true
+
+			continue
+		}
+
+		if r.record >= 0 {
+			r.record++
+		}
+
+		shards[i] = shard
+	}
+
+	// Groups without checksums can only be checked for records which can't be read
+	checksumRecord := shards[len(shards)-1]
+	shards = shards[:len(shards)-1]
+	if checksumRecord != nil {
+		if checksums, ok := decodeChecksums(checksumRecord, r.dataShards, r.parityShards); ok {
+			for i, shard := range shards {
+				if shard != nil && crc32.ChecksumIEEE(shard) != checksums[i] {
+					damaged[i] = true
+				}
+			}
+		}
+	}
+
+	r.group = group
+	r.data = make([]byte, 0, r.dataShards*r.recordSize)
+
+	// Groups which have been cut off have no parity records, so only return the intact data records
+	if !complete {
+		for i := 0; i < r.dataShards && shards[i] != nil && !damaged[i]; i++ {
+			r.data = append(r.data, shards[i]...)
+		}
+
+		return nil
+	}
+
+	corrected := 0
+	for i := range shards {
+		if !damaged[i] {
+			continue
+		}
+
+		shards[i] = nil
+		if i < r.dataShards {
+			corrected++
+		}
+	}
+
+	if len(damaged) > 0 {
+		if err := r.decoder.ReconstructData(shards); err != nil {
+			r.group = -1
+
+			if errors.Is(err, reedsolomon.ErrTooFewShards) {
+				return config.ErrParityInsufficient
+			}
+
+			return err
+		}
+	}
+
+	if corrected > 0 && r.onCorrection != nil {
+		r.onCorrection(&config.CorrectionEvent{
+			Volume:    r.volume,
+			Record:    group * int64(r.dataShards),
+			Corrected: corrected,
+		})
+	}
+
+	for _, shard := range shards[:r.dataShards] {
+		r.data = append(r.data, shard...)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package parity
+
+import (
+	"io"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// Writer writes the data records to the underlying writer as they are filled and appends
+// the parity records and a record with the checksums of the group after every dataShards records
+type Writer struct {
+	w            io.Writer
+	isRegular    bool
+	encoder      reedsolomon.Encoder
+	dataShards   int
+	parityShards int
+	recordSize   int
+
+	shards [][]byte
+	shard  int
+	offset int
+	fill   int // Records to write before the first group so that it starts at a multiple of the group size
+}
+
+// DataOffset returns the offset in the data, as Reader reads it, at which a Writer that starts at the physical record continues
+func DataOffset(record int64, recordSize int, dataShards int, parityShards int) int64 {
+	groupSize := int64(dataShards + parityShards + 1)
+
+	return ((record + groupSize - 1) / groupSize) * int64(dataShards*config.MagneticTapeBlockSize*recordSize)
+}
+
+// NewWriter creates a writer which starts at record. Groups on tapes start at multiples of the group size, so that
+// readers can find them after the file marks between sessions; tar files always contain complete groups, so record is ignored for them.
+func NewWriter(
+	w io.Writer,
+	isRegular bool,
+	record int64,
+	recordSize int,
+	dataShards int,
+	parityShards int,
+) (*Writer, error) {
+	encoder, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := make([][]byte, dataShards+parityShards)
+	for i := range shards {
+		shards[i] = make([]byte, config.MagneticTapeBlockSize*recordSize)
+	}
+
+	fill := 0
+	if groupSize := int64(dataShards + parityShards + 1); !isRegular && record%groupSize != 0 {
+		fill = int(groupSize - (record % groupSize))
+	}
+
+	return &Writer{
+		w:            w,
+		isRegular:    isRegular,
+		encoder:      encoder,
+		dataShards:   dataShards,
+		parityShards: parityShards,
+		recordSize:   config.MagneticTapeBlockSize * recordSize,
+
+		shards: shards,
+		fill:   fill,
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if err := w.align(); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > w.recordSize-w.offset {
+			chunk = chunk[:w.recordSize-w.offset]
+		}
+
+		copy(w.shards[w.shard][w.offset:], chunk)
+
+		if w.isRegular {
+			// Write the data through so that everything which has been written has reached the drive
+			n, err := w.w.Write(chunk)
+			written += n
+			w.offset += n
+			if err != nil {
+				return written, err
+			}
+		} else {
+			// Tapes can only write whole records, so the record is written once it is full
+			if w.offset+len(chunk) >= w.recordSize {
+				if _, err := w.w.Write(w.shards[w.shard]); err != nil {
+					return written, err
+				}
+			}
+
+			written += len(chunk)
+			w.offset += len(chunk)
+		}
+
+		p = p[len(chunk):]
+
+		if w.offset >= w.recordSize {
+			w.shard++
+			w.offset = 0
+		}
+
+		if w.shard >= w.dataShards {
+			if err := w.writeParity(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close fills the last group with zeros and writes its parity records
+func (w *Writer) Close() error {
+	if w.shard == 0 && w.offset == 0 {
+		return nil
+	}
+
+	for w.shard < w.dataShards {
+		rest := w.shards[w.shard][w.offset:]
+		if !w.isRegular {
+			rest = w.shards[w.shard]
+		}
+
+		if _, err := w.w.Write(rest); err != nil {
+			return err
+		}
+
+		w.shard++
+		w.offset = 0
+	}
+
+	return w.writeParity()
+}
+
+// align writes empty records until the first group starts at a multiple of the group size
+func (w *Writer) align() error {
+	for ; w.fill > 0; w.fill-- {
+		if _, err := w.w.Write(make([]byte, w.recordSize)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Writer) writeParity() error {
+	if err := w.encoder.Encode(w.shards); err != nil {
+		return err
+	}
+
+	for _, shard := range w.shards[w.dataShards:] {
+		if _, err := w.w.Write(shard); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.w.Write(encodeChecksums(w.shards, w.dataShards, w.parityShards, w.recordSize)); err != nil {
+		return err
+	}
+
+	for _, shard := range w.shards {
+		for i := range shard {
+			shard[i] = 0
+		}
+	}
+
+	w.shard = 0
+	w.offset = 0
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package persisters
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// journalRecord is a line of the journal; the first one starts the session, the following ones are its headers
+type journalRecord struct {
+	Session *config.JournalSession `json:",omitempty"`
+	Header  *config.Header         `json:",omitempty"`
+}
+
+// JournalPersister stores the unfinished session in a file which is synced after every record, i.e. next to the metadata database
+type JournalPersister struct {
+	path string
+
+	lock sync.Mutex
+	file *os.File
+}
+
+func NewJournalPersister(path string) *JournalPersister {
+	return &JournalPersister{
+		path: path,
+	}
+}
+
+func (p *JournalPersister) Begin(session *config.JournalSession) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file != nil {
+		_ = p.file.Close()
+
+		p.file = nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	// Replace the previous session atomically so that it is never lost before the new one has been recorded
+	tmp := p.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	s := *session
+	s.Headers = nil
+	if err := writeJournalRecord(file, &journalRecord{Session: &s}); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	for _, hdr := range session.Headers {
+		if err := writeJournalRecord(file, &journalRecord{Header: hdr}); err != nil {
+			_ = file.Close()
+
+			return err
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	if err := os.Rename(tmp, p.path); err != nil {
+		_ = file.Close()
+
+		return err
+	}
+
+	p.file = file
+
+	return nil
+}
+
+func (p *JournalPersister) Append(hdr *config.Header) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		return config.ErrJournalSessionMissing
+	}
+
+	if err := writeJournalRecord(p.file, &journalRecord{Header: hdr}); err != nil {
+		return err
+	}
+
+	return p.file.Sync()
+}
+
+func (p *JournalPersister) Get() (*config.JournalSession, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	file, err := os.Open(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer file.Close()
+
+	var session *config.JournalSession
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// The last record is incomplete if the process died while writing it
+			if err == io.EOF {
+				break
+			}
+
+			return nil, err
+		}
+
+		record := &journalRecord{}
+		if err := json.Unmarshal(line, record); err != nil {
+			return nil, err
+		}
+
+		if record.Session != nil {
+			session = record.Session
+
+			continue
+		}
+
+		if session == nil || record.Header == nil {
+			return nil, config.ErrJournalInvalid
+		}
+
+		session.Headers = append(session.Headers, record.Header)
+	}
+
+	if session == nil {
+		return nil, config.ErrJournalInvalid
+	}
+
+	return session, nil
+}
+
+func (p *JournalPersister) Commit() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file != nil {
+		_ = p.file.Close()
+
+		p.file = nil
+	}
+
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (p *JournalPersister) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+
+	err := p.file.Close()
+	p.file = nil
+
+	return err
+}
+
+func writeJournalRecord(w io.Writer, record *journalRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(line, '\n'))
+
+	return err
+}
@@ -0,0 +1,140 @@
+package persisters
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+var journalPersisterTests = []struct {
+	name    string
+	session *config.JournalSession
+	appends []*config.Header
+	commit  bool
+	trailer string // Written after the records, i.e. an incomplete record of a process which died while writing it
+	want    *config.JournalSession
+	wantErr bool
+}{
+	{
+		"Can get session without headers",
+		&config.JournalSession{Volume: 1, Record: 2, Block: 3, Offset: 1},
+		[]*config.Header{},
+		false,
+		"",
+		&config.JournalSession{Volume: 1, Record: 2, Block: 3, Offset: 1},
+		false,
+	},
+	{
+		"Can get session with headers",
+		&config.JournalSession{Overwrite: true, Headers: []*config.Header{{Name: "a.txt"}}},
+		[]*config.Header{{Name: "b.txt"}, {Name: "c.txt", Hash: "abc"}},
+		false,
+		"",
+		&config.JournalSession{Overwrite: true, Headers: []*config.Header{{Name: "a.txt"}, {Name: "b.txt"}, {Name: "c.txt", Hash: "abc"}}},
+		false,
+	},
+	{
+		"Can get session with incomplete last record",
+		&config.JournalSession{},
+		[]*config.Header{{Name: "a.txt"}},
+		false,
+		`{"Header":{"Name":"b.t`,
+		&config.JournalSession{Headers: []*config.Header{{Name: "a.txt"}}},
+		false,
+	},
+	{
+		"Can not get committed session",
+		&config.JournalSession{},
+		[]*config.Header{{Name: "a.txt"}},
+		true,
+		"",
+		nil,
+		false,
+	},
+	{
+		"Can not get session with invalid record",
+		&config.JournalSession{},
+		[]*config.Header{},
+		false,
+		"{]\n",
+		nil,
+		true,
+	},
+}
+
+func TestJournalPersister_Get(t *testing.T) {
+	for _, tt := range journalPersisterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metadata.sqlite.journal")
+
+			p := NewJournalPersister(path)
+			if err := p.Begin(tt.session); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, hdr := range tt.appends {
+				if err := p.Append(hdr); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.commit {
+				if err := p.Commit(); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := p.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.trailer != "" {
+				f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := f.WriteString(tt.trailer); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := f.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Read the journal like the next process would
+			got, err := NewJournalPersister(path).Get()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JournalPersister.Get() error = %v, wantErr %v", err, tt.wantErr)
+
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JournalPersister.Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalPersister_Append(t *testing.T) {
+	p := NewJournalPersister(filepath.Join(t.TempDir(), "metadata.sqlite.journal"))
+
+	if err := p.Append(&config.Header{Name: "a.txt"}); err != config.ErrJournalSessionMissing {
+		t.Errorf("JournalPersister.Append() error = %v, want %v", err, config.ErrJournalSessionMissing)
+	}
+
+	if err := p.Begin(&config.JournalSession{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Append(&config.Header{Name: "a.txt"}); err != config.ErrJournalSessionMissing {
+		t.Errorf("JournalPersister.Append() after Close() error = %v, want %v", err, config.ErrJournalSessionMissing)
+	}
+}
@@ -4,6 +4,7 @@ package persisters
 //go:generate go-bindata -pkg metadata -o ../../internal/db/sqlite/migrations/metadata/migrations.go ../../db/sqlite/migrations/metadata
 
 import (
+	"archive/tar"
 	"context"
 	"database/sql"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	models "github.com/pojntfx/stfs/internal/db/sqlite/models/metadata"
 	"github.com/pojntfx/stfs/internal/pathext"
 	ipersisters "github.com/pojntfx/stfs/internal/persisters"
+	"github.com/pojntfx/stfs/internal/records"
 	"github.com/pojntfx/stfs/pkg/config"
 	migrate "github.com/rubenv/sql-migrate"
 	"github.com/volatiletech/sqlboiler/v4/boil"
@@ -27,11 +29,44 @@ type depth struct {
 	Depth int64 `boil:"depth" json:"depth" toml:"depth" yaml:"depth"`
 }
 
+type latestSession struct {
+	Session int64 `boil:"session" json:"session" toml:"session" yaml:"session"`
+}
+
+// historyColumns are the columns which the history shares with the headers
+var historyColumns = []string{
+	models.HeaderColumns.Record,
+	models.HeaderColumns.Lastknownrecord,
+	models.HeaderColumns.Block,
+	models.HeaderColumns.Lastknownblock,
+	models.HeaderColumns.Deleted,
+	models.HeaderColumns.Typeflag,
+	models.HeaderColumns.Name,
+	models.HeaderColumns.Linkname,
+	models.HeaderColumns.Size,
+	models.HeaderColumns.Mode,
+	models.HeaderColumns.UID,
+	models.HeaderColumns.Gid,
+	models.HeaderColumns.Uname,
+	models.HeaderColumns.Gname,
+	models.HeaderColumns.Modtime,
+	models.HeaderColumns.Accesstime,
+	models.HeaderColumns.Changetime,
+	models.HeaderColumns.Devmajor,
+	models.HeaderColumns.Devminor,
+	models.HeaderColumns.Paxrecords,
+	models.HeaderColumns.Format,
+	models.HeaderColumns.Volume,
+	models.HeaderColumns.Lastknownvolume,
+}
+
 type MetadataPersister struct {
 	sqlite *ipersisters.SQLite
 
 	root              string
 	rootIsEmptyString bool
+
+	snapshot int64
 }
 
 func NewMetadataPersister(dbPath string) *MetadataPersister {
@@ -46,6 +81,8 @@ func NewMetadataPersister(dbPath string) *MetadataPersister {
 		},
 		"",
 		false,
+
+		0,
 	}
 }
 
@@ -78,14 +115,16 @@ func (p *MetadataPersister) GetRootPath(ctx context.Context) (string, error) {
 
 	root := models.Header{}
 
+	snapshot, args := p.snapshotHeaders()
 	if err := queries.Raw(
-		fmt.Sprintf(
+		snapshot+fmt.Sprintf(
 			`select min(length(%v) - length(replace(%v, "/", ""))) as depth, name from %v where %v != 1`,
 			models.HeaderColumns.Name,
 			models.HeaderColumns.Name,
 			models.TableNames.Headers,
 			models.HeaderColumns.Deleted,
 		),
+		args...,
 	).Bind(ctx, p.sqlite.DB, &root); err != nil {
 		if strings.Contains(err.Error(), "converting NULL to string is unsupported") {
 			return "", config.ErrNoRootDirectory
@@ -140,21 +179,23 @@ func (p *MetadataPersister) UpdateHeaderMetadata(ctx context.Context, dbhdr *con
 	return nil
 }
 
-func (p *MetadataPersister) MoveHeader(ctx context.Context, oldName string, newName string, lastknownrecord, lastknownblock int64) error {
+func (p *MetadataPersister) MoveHeader(ctx context.Context, oldName string, newName string, lastknownvolume, lastknownrecord, lastknownblock int64) error {
 	newName = p.getSanitizedPath(ctx, newName)
 	oldName = p.getSanitizedPath(ctx, oldName)
 
 	// We can't do this with `dbhdr.Update` because we are renaming the primary key
 	n, err := queries.Raw(
 		fmt.Sprintf(
-			`update %v set %v = ?, %v = ?, %v = ? where %v = ?;`,
+			`update %v set %v = ?, %v = ?, %v = ?, %v = ? where %v = ?;`,
 			models.TableNames.Headers,
 			models.HeaderColumns.Name,
+			models.HeaderColumns.Lastknownvolume,
 			models.HeaderColumns.Lastknownrecord,
 			models.HeaderColumns.Lastknownblock,
 			models.HeaderColumns.Name,
 		),
 		newName,
+		lastknownvolume,
 		lastknownrecord,
 		lastknownblock,
 		oldName,
@@ -171,14 +212,16 @@ func (p *MetadataPersister) MoveHeader(ctx context.Context, oldName string, newN
 	if written < 1 {
 		if _, err := queries.Raw(
 			fmt.Sprintf(
-				`update %v set %v = ?, %v = ?, %v = ? where %v = ?;`,
+				`update %v set %v = ?, %v = ?, %v = ?, %v = ? where %v = ?;`,
 				models.TableNames.Headers,
 				models.HeaderColumns.Name,
+				models.HeaderColumns.Lastknownvolume,
 				models.HeaderColumns.Lastknownrecord,
 				models.HeaderColumns.Lastknownblock,
 				models.HeaderColumns.Name,
 			),
 			newName,
+			lastknownvolume,
 			lastknownrecord,
 			lastknownblock,
 			oldName,
@@ -187,11 +230,28 @@ func (p *MetadataPersister) MoveHeader(ctx context.Context, oldName string, newN
 		}
 	}
 
+	// Keep hard links pointing to the moved header
+	if _, err := queries.Raw(
+		fmt.Sprintf(
+			`update %v set %v = ? where %v = ? and %v = ?;`,
+			models.TableNames.Headers,
+			models.HeaderColumns.Linkname,
+			models.HeaderColumns.Typeflag,
+			models.HeaderColumns.Linkname,
+		),
+		newName,
+		tar.TypeLink,
+		oldName,
+	).ExecContext(ctx, p.sqlite.DB); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (p *MetadataPersister) GetHeaders(ctx context.Context) ([]*config.Header, error) {
 	dbhdrs, err := models.Headers(
+		p.withSnapshot(),
 		qm.Where(models.HeaderColumns.Deleted+" != 1"),
 	).All(ctx, p.sqlite.DB)
 	if err != nil {
@@ -210,6 +270,7 @@ func (p *MetadataPersister) GetHeader(ctx context.Context, name string) (*config
 	name = p.getSanitizedPath(ctx, name)
 
 	hdr, err := models.Headers(
+		p.withSnapshot(),
 		qm.Where(models.HeaderColumns.Name+" = ?", name),
 		qm.Where(models.HeaderColumns.Deleted+" != 1"),
 	).One(ctx, p.sqlite.DB)
@@ -224,6 +285,7 @@ func (p *MetadataPersister) GetHeaderByLinkname(ctx context.Context, linkname st
 	linkname = p.getSanitizedPath(ctx, linkname)
 
 	hdr, err := models.Headers(
+		p.withSnapshot(),
 		qm.Where(models.HeaderColumns.Linkname+" = ?", linkname),
 		qm.Where(models.HeaderColumns.Deleted+" != 1"),
 	).One(ctx, p.sqlite.DB)
@@ -234,10 +296,55 @@ func (p *MetadataPersister) GetHeaderByLinkname(ctx context.Context, linkname st
 	return converters.DBHeaderToConfigHeader(hdr), nil
 }
 
+func (p *MetadataPersister) GetHeaderByHash(ctx context.Context, hash string) (*config.Header, error) {
+	// Headers without content have no hash
+	if hash == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	hdr, err := models.Headers(
+		p.withSnapshot(),
+		qm.Where(models.HeaderColumns.Hash+" = ?", hash),
+		qm.Where(models.HeaderColumns.Deleted+" != 1"),
+	).One(ctx, p.sqlite.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return converters.DBHeaderToConfigHeader(hdr), nil
+}
+
+// ResolveHardlink returns the header which holds the content of a hard link, even if it has been deleted since
+func (p *MetadataPersister) ResolveHardlink(ctx context.Context, linkname string) (*config.Header, error) {
+	seen := map[string]struct{}{}
+	for {
+		linkname = p.getSanitizedPath(ctx, linkname)
+		if _, ok := seen[linkname]; ok {
+			return nil, sql.ErrNoRows // Hard links can't form cycles
+		}
+		seen[linkname] = struct{}{}
+
+		hdr, err := models.Headers(
+			p.withSnapshot(),
+			qm.Where(models.HeaderColumns.Name+" = ?", linkname),
+		).One(ctx, p.sqlite.DB)
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeLink {
+			return converters.DBHeaderToConfigHeader(hdr), nil
+		}
+
+		linkname = hdr.Linkname
+	}
+}
+
 func (p *MetadataPersister) GetHeaderChildren(ctx context.Context, name string) ([]*config.Header, error) {
 	name = p.getSanitizedPath(ctx, name)
 
 	headers, err := models.Headers(
+		p.withSnapshot(),
 		qm.Where(models.HeaderColumns.Name+" like ?", strings.TrimSuffix(name, "/")+"/%"), // Prevent double trailing slashes
 		qm.Where(models.HeaderColumns.Deleted+" != 1"),
 	).All(ctx, p.sqlite.DB)
@@ -260,6 +367,7 @@ func (p *MetadataPersister) GetHeaderDirectChildren(ctx context.Context, name st
 	name = p.getSanitizedPath(ctx, name)
 	prefix := strings.TrimSuffix(name, "/") + "/"
 	rootDepth := 0
+	snapshot, snapshotArgs := p.snapshotHeaders()
 
 	// We want <=, not <
 	if limit > 0 {
@@ -272,13 +380,14 @@ func (p *MetadataPersister) GetHeaderDirectChildren(ctx context.Context, name st
 		depth := depth{}
 
 		if err := queries.Raw(
-			fmt.Sprintf(
+			snapshot+fmt.Sprintf(
 				`select min(length(%v) - length(replace(%v, "/", ""))) as depth from %v where %v != 1`,
 				models.HeaderColumns.Name,
 				models.HeaderColumns.Name,
 				models.TableNames.Headers,
 				models.HeaderColumns.Deleted,
 			),
+			snapshotArgs...,
 		).Bind(ctx, p.sqlite.DB, &depth); err != nil {
 			if err == sql.ErrNoRows {
 				return []*config.Header{}, nil
@@ -299,8 +408,8 @@ func (p *MetadataPersister) GetHeaderDirectChildren(ctx context.Context, name st
 		}
 		headers := []*config.Header{}
 
-		query := fmt.Sprintf(
-			`select %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v,
+		query := snapshot + fmt.Sprintf(
+			`select %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v, %v,
     length(replace(%v, ?, '')) - length(replace(replace(%v, ?, ''), '/', '')) as depth
 from %v
 where %v like ?
@@ -335,6 +444,8 @@ where %v like ?
 			models.HeaderColumns.Devminor,
 			models.HeaderColumns.Paxrecords,
 			models.HeaderColumns.Format,
+			models.HeaderColumns.Volume,
+			models.HeaderColumns.Lastknownvolume,
 			pk,
 			pk,
 			models.TableNames.Headers,
@@ -348,12 +459,15 @@ where %v like ?
 		if limit > 0 {
 			if err := queries.Raw(
 				query+`limit ?`,
-				prefix,
-				prefix,
-				prefix+"%",
-				rootDepth,
-				rootDepth+1,
-				limit+1, // +1 to accomodate the parent directory if it exists
+				append(
+					snapshotArgs,
+					prefix,
+					prefix,
+					prefix+"%",
+					rootDepth,
+					rootDepth+1,
+					limit+1, // +1 to accomodate the parent directory if it exists
+				)...,
 			).Bind(ctx, p.sqlite.DB, &headers); err != nil {
 				if err == sql.ErrNoRows {
 					return headers, nil
@@ -364,11 +478,14 @@ where %v like ?
 		} else if limit <= 0 {
 			if err := queries.Raw(
 				query,
-				prefix,
-				prefix,
-				prefix+"%",
-				rootDepth,
-				rootDepth+1,
+				append(
+					snapshotArgs,
+					prefix,
+					prefix,
+					prefix+"%",
+					rootDepth,
+					rootDepth+1,
+				)...,
 			).Bind(ctx, p.sqlite.DB, &headers); err != nil {
 				if err == sql.ErrNoRows {
 					return headers, nil
@@ -436,7 +553,7 @@ where %v like ?
 	return outhdrs[:limit-1], nil
 }
 
-func (p *MetadataPersister) DeleteHeader(ctx context.Context, name string, lastknownrecord, lastknownblock int64) (*config.Header, error) {
+func (p *MetadataPersister) DeleteHeader(ctx context.Context, name string, lastknownvolume, lastknownrecord, lastknownblock int64) (*config.Header, error) {
 	name = p.getSanitizedPath(ctx, name)
 
 	hdr, err := models.Headers(
@@ -448,6 +565,81 @@ func (p *MetadataPersister) DeleteHeader(ctx context.Context, name string, lastk
 	}
 
 	hdr.Deleted = 1
+	hdr.Lastknownvolume = lastknownvolume
+	hdr.Lastknownrecord = lastknownrecord
+	hdr.Lastknownblock = lastknownblock
+
+	if _, err := hdr.Update(ctx, p.sqlite.DB, boil.Infer()); err != nil {
+		return nil, err
+	}
+
+	return converters.DBHeaderToConfigHeader(hdr), nil
+}
+
+// GetDeletedHeader returns the header which has been deleted most recently with the given name
+func (p *MetadataPersister) GetDeletedHeader(ctx context.Context, name string) (*config.Header, error) {
+	name = p.getSanitizedPath(ctx, name)
+
+	hdr, err := models.Headers(
+		p.withSnapshot(),
+		qm.Where(models.HeaderColumns.Name+" = ?", name),
+		qm.Where(models.HeaderColumns.Deleted+" = 1"),
+		qm.OrderBy(models.HeaderColumns.Lastknownvolume+" desc, "+models.HeaderColumns.Lastknownrecord+" desc, "+models.HeaderColumns.Lastknownblock+" desc"),
+	).One(ctx, p.sqlite.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return converters.DBHeaderToConfigHeader(hdr), nil
+}
+
+// GetDeletedHeaderChildren returns the headers below name which have been deleted most recently, at most one per name
+func (p *MetadataPersister) GetDeletedHeaderChildren(ctx context.Context, name string) ([]*config.Header, error) {
+	name = p.getSanitizedPath(ctx, name)
+
+	headers, err := models.Headers(
+		p.withSnapshot(),
+		qm.Where(models.HeaderColumns.Name+" like ?", strings.TrimSuffix(name, "/")+"/%"), // Prevent double trailing slashes
+		qm.Where(models.HeaderColumns.Deleted+" = 1"),
+		qm.OrderBy(models.HeaderColumns.Lastknownvolume+" desc, "+models.HeaderColumns.Lastknownrecord+" desc, "+models.HeaderColumns.Lastknownblock+" desc"),
+	).All(ctx, p.sqlite.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	outhdrs := []*config.Header{}
+	names := map[string]struct{}{}
+	for _, hdr := range headers {
+		prefix := strings.TrimSuffix(hdr.Name, "/")
+		if name == prefix || name == prefix+"/" {
+			continue
+		}
+
+		if _, ok := names[hdr.Name]; ok {
+			continue
+		}
+		names[hdr.Name] = struct{}{}
+
+		outhdrs = append(outhdrs, converters.DBHeaderToConfigHeader(hdr))
+	}
+
+	return outhdrs, nil
+}
+
+func (p *MetadataPersister) UndeleteHeader(ctx context.Context, name string, linkname string, lastknownvolume, lastknownrecord, lastknownblock int64) (*config.Header, error) {
+	name = p.getSanitizedPath(ctx, name)
+
+	hdr, err := models.Headers(
+		qm.Where(models.HeaderColumns.Name+" = ?", name),
+		qm.Where(models.HeaderColumns.Linkname+" = ?", linkname),
+		qm.Where(models.HeaderColumns.Deleted+" = 1"),
+	).One(ctx, p.sqlite.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr.Deleted = 0
+	hdr.Lastknownvolume = lastknownvolume
 	hdr.Lastknownrecord = lastknownrecord
 	hdr.Lastknownblock = lastknownblock
 
@@ -462,12 +654,13 @@ func (p *MetadataPersister) GetLastIndexedRecordAndBlock(ctx context.Context, re
 	var header models.Header
 	if err := queries.Raw(
 		fmt.Sprintf(
-			`select %v, %v, ((%v*$1)+%v) as location from %v order by location desc limit 1`, // We include deleted headers here as they are still physically on the tape and have to be considered when re-indexing
+			`select %v, %v, ((%v*$1)+%v) as location from %v order by %v desc, location desc limit 1`, // We include deleted headers here as they are still physically on the tape and have to be considered when re-indexing
 			models.HeaderColumns.Lastknownrecord,
 			models.HeaderColumns.Lastknownblock,
 			models.HeaderColumns.Lastknownrecord,
 			models.HeaderColumns.Lastknownblock,
 			models.TableNames.Headers,
+			models.HeaderColumns.Lastknownvolume,
 		),
 		recordSize,
 	).Bind(ctx, p.sqlite.DB, &header); err != nil {
@@ -481,19 +674,208 @@ func (p *MetadataPersister) GetLastIndexedRecordAndBlock(ctx context.Context, re
 	return header.Lastknownrecord, header.Lastknownblock, nil
 }
 
+func (p *MetadataPersister) GetLastIndexedVolume(ctx context.Context) (int64, error) {
+	var header models.Header
+	if err := queries.Raw(
+		fmt.Sprintf(
+			`select %v from %v order by %v desc limit 1`, // Deleted headers are still physically on the volume
+			models.HeaderColumns.Lastknownvolume,
+			models.TableNames.Headers,
+			models.HeaderColumns.Lastknownvolume,
+		),
+	).Bind(ctx, p.sqlite.DB, &header); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return header.Lastknownvolume, nil
+}
+
 func (p *MetadataPersister) PurgeAllHeaders(ctx context.Context) error {
 	if _, err := models.Headers().DeleteAll(ctx, p.sqlite.DB); err != nil {
 		return err
 	}
 
+	if _, err := models.Histories().DeleteAll(ctx, p.sqlite.DB); err != nil {
+		return err
+	}
+
 	p.root = ""
 	p.rootIsEmptyString = false
 
 	return nil
 }
 
+// AddHeaderVersion records the headers which have last been changed by the header at the last known volume, record and block in the history.
+// If the header has been moved, replacesName is recorded as deleted.
+func (p *MetadataPersister) AddHeaderVersion(ctx context.Context, session int64, replacesName string, lastknownvolume, lastknownrecord, lastknownblock int64) error {
+	// Headers without a session have been written before sessions were recorded, so keep the order of the history
+	if session <= 0 {
+		latest := latestSession{}
+		if err := queries.Raw(
+			fmt.Sprintf(
+				`select coalesce(max(%v), 0) as session from %v`,
+				models.HistoryColumns.Session,
+				models.TableNames.History,
+			),
+		).Bind(ctx, p.sqlite.DB, &latest); err != nil {
+			return err
+		}
+
+		session = latest.Session
+	}
+
+	addVersions := func(name *string) error {
+		columns := []string{}
+		args := []interface{}{session}
+		for _, column := range historyColumns {
+			switch {
+			case name != nil && column == models.HeaderColumns.Name:
+				columns = append(columns, "? as "+column)
+				args = append(args, *name)
+			case name != nil && column == models.HeaderColumns.Deleted:
+				columns = append(columns, "1 as "+column)
+			default:
+				columns = append(columns, column)
+			}
+		}
+
+		// Re-indexing doesn't add the same versions again
+		args = append(args, lastknownvolume, lastknownrecord, lastknownblock)
+		if _, err := queries.Raw(
+			fmt.Sprintf(
+				`insert into %v (%v, %v)
+select * from (select ? as %v, %v from %v where %v = ? and %v = ? and %v = ?) as versions
+where not exists (
+    select 1 from %v where %v = versions.%v and %v = versions.%v and %v = versions.%v and %v = versions.%v and %v = versions.%v and %v = versions.%v
+)`,
+				models.TableNames.History,
+				models.HistoryColumns.Session,
+				strings.Join(historyColumns, ", "),
+				models.HistoryColumns.Session,
+				strings.Join(columns, ", "),
+				models.TableNames.Headers,
+				models.HeaderColumns.Lastknownvolume,
+				models.HeaderColumns.Lastknownrecord,
+				models.HeaderColumns.Lastknownblock,
+				models.TableNames.History,
+				models.HistoryColumns.Name, models.HeaderColumns.Name,
+				models.HistoryColumns.Linkname, models.HeaderColumns.Linkname,
+				models.HistoryColumns.Deleted, models.HeaderColumns.Deleted,
+				models.HistoryColumns.Lastknownvolume, models.HeaderColumns.Lastknownvolume,
+				models.HistoryColumns.Lastknownrecord, models.HeaderColumns.Lastknownrecord,
+				models.HistoryColumns.Lastknownblock, models.HeaderColumns.Lastknownblock,
+			),
+			args...,
+		).ExecContext(ctx, p.sqlite.DB); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := addVersions(nil); err != nil {
+		return err
+	}
+
+	if replacesName == "" {
+		return nil
+	}
+
+	replacesName = p.getSanitizedPath(ctx, replacesName)
+	if exists, err := models.Headers(
+		qm.Where(models.HeaderColumns.Name+" = ?", replacesName),
+		qm.Where(models.HeaderColumns.Lastknownvolume+" = ?", lastknownvolume),
+		qm.Where(models.HeaderColumns.Lastknownrecord+" = ?", lastknownrecord),
+		qm.Where(models.HeaderColumns.Lastknownblock+" = ?", lastknownblock),
+	).Exists(ctx, p.sqlite.DB); err != nil || exists {
+		return err // The header hasn't been moved
+	}
+
+	return addVersions(&replacesName)
+}
+
+// GetHeaderHistory returns all versions of the header with the name, from the oldest to the latest one
+func (p *MetadataPersister) GetHeaderHistory(ctx context.Context, name string) ([]*config.HeaderVersion, error) {
+	name = p.getSanitizedPath(ctx, name)
+
+	dbversions, err := models.Histories(
+		qm.Where(models.HistoryColumns.Name+" = ?", name),
+		qm.OrderBy(models.HistoryColumns.ID+" asc"),
+	).All(ctx, p.sqlite.DB)
+	if err != nil {
+		return []*config.HeaderVersion{}, err
+	}
+
+	versions := []*config.HeaderVersion{}
+	for _, dbversion := range dbversions {
+		versions = append(versions, converters.DBHistoryToConfigHeaderVersion(dbversion))
+	}
+
+	return versions, nil
+}
+
+// SetSnapshot makes all following queries for headers use the headers as they were after the operation with the session has been indexed.
+// A session of 0 or less goes back to the latest headers. Writes always go to the latest headers.
+func (p *MetadataPersister) SetSnapshot(ctx context.Context, session int64) error {
+	p.snapshot = session
+
+	p.root = ""
+	p.rootIsEmptyString = false
+
+	if _, err := p.GetRootPath(ctx); err != nil && err != config.ErrNoRootDirectory {
+		return err
+	}
+
+	return nil
+}
+
+// snapshotHeaders returns the common table expression which replaces the headers in a read query with the latest versions in the history up to the session of the snapshot and its arguments.
+// If no snapshot is set, it is empty.
+func (p *MetadataPersister) snapshotHeaders() (string, []interface{}) {
+	if p.snapshot <= 0 {
+		return "", []interface{}{}
+	}
+
+	return fmt.Sprintf(
+		`with %v as (
+    select %v,
+        coalesce(case when json_valid(%v) then json_extract(%v, '$."%v"') end, '') as %v
+    from %v
+    where %v in (select max(%v) from %v where %v <= ? group by %v, %v)
+) `,
+		models.TableNames.Headers,
+		strings.Join(historyColumns, ", "),
+		models.HistoryColumns.Paxrecords,
+		models.HistoryColumns.Paxrecords,
+		records.STFSRecordHash,
+		models.HeaderColumns.Hash, // The history doesn't keep the hash, but it is part of the PAX records
+		models.TableNames.History,
+		models.HistoryColumns.ID,
+		models.HistoryColumns.ID,
+		models.TableNames.History,
+		models.HistoryColumns.Session,
+		models.HistoryColumns.Name,
+		models.HistoryColumns.Linkname,
+	), []interface{}{p.snapshot}
+}
+
+// withSnapshot is snapshotHeaders as a query mod
+func (p *MetadataPersister) withSnapshot() qm.QueryMod {
+	snapshot, args := p.snapshotHeaders()
+	if snapshot == "" {
+		return qm.QueryModFunc(func(q *queries.Query) {})
+	}
+
+	return qm.With(strings.TrimPrefix(snapshot, "with "), args...)
+}
+
 func (p *MetadataPersister) headerExistsExact(ctx context.Context, name string) error {
 	exists, err := models.Headers(
+		p.withSnapshot(),
 		qm.Where(models.HeaderColumns.Name+" = ?", name),
 		qm.Where(models.HeaderColumns.Deleted+" != 1"),
 	).Exists(ctx, p.sqlite.DB)
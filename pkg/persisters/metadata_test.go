@@ -0,0 +1,278 @@
+package persisters
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+type metadataTestOperation struct {
+	session int64
+	upserts map[string]string // Names and hashes of the regular files which are archived
+	deletes []string          // Names of the headers which are deleted
+}
+
+// metadataTestOperations are indexed one after another; each one is written at the record of its session
+var metadataTestOperations = []metadataTestOperation{
+	{1, map[string]string{"d/a.txt": "a1", "d/b.txt": "b1"}, []string{}},
+	{2, map[string]string{"d/a.txt": "a2"}, []string{}},
+	{3, map[string]string{"d/c.txt": "c3"}, []string{"d/b.txt"}},
+}
+
+var getHeaderHistoryTests = []struct {
+	name         string
+	header       string
+	wantSessions []int64
+	wantHashes   []string
+	wantDeleted  []int64
+}{
+	{
+		"Can get history of changed file",
+		"d/a.txt",
+		[]int64{1, 2},
+		[]string{"a1", "a2"},
+		[]int64{0, 0},
+	},
+	{
+		"Can get history of deleted file",
+		"d/b.txt",
+		[]int64{1, 3},
+		[]string{"b1", "b1"},
+		[]int64{0, 1},
+	},
+	{
+		"Can get history of added file",
+		"d/c.txt",
+		[]int64{3},
+		[]string{"c3"},
+		[]int64{0},
+	},
+	{
+		"Can get history of directory",
+		"d",
+		[]int64{1},
+		[]string{""},
+		[]int64{0},
+	},
+	{
+		"Can get empty history of unknown file",
+		"d/e.txt",
+		[]int64{},
+		[]string{},
+		[]int64{},
+	},
+}
+
+func TestMetadataPersister_GetHeaderHistory(t *testing.T) {
+	for _, tt := range getHeaderHistoryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := createIndexedMetadataPersister(filepath.Join(t.TempDir(), "metadata.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			versions, err := p.GetHeaderHistory(context.Background(), tt.header)
+			if err != nil {
+				t.Errorf("GetHeaderHistory() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			sessions := []int64{}
+			hashes := []string{}
+			deleted := []int64{}
+			for _, version := range versions {
+				sessions = append(sessions, version.Session)
+				hashes = append(hashes, version.Header.Hash)
+				deleted = append(deleted, version.Header.Deleted)
+			}
+
+			if !reflect.DeepEqual(sessions, tt.wantSessions) {
+				t.Errorf("GetHeaderHistory() sessions = %v, want %v", sessions, tt.wantSessions)
+			}
+
+			if !reflect.DeepEqual(hashes, tt.wantHashes) {
+				t.Errorf("GetHeaderHistory() hashes = %v, want %v", hashes, tt.wantHashes)
+			}
+
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("GetHeaderHistory() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+var setSnapshotTests = []struct {
+	name    string
+	session int64
+	want    map[string]string // Names and hashes of the headers in the snapshot
+}{
+	{
+		"Can get snapshot after first session",
+		1,
+		map[string]string{"d": "", "d/a.txt": "a1", "d/b.txt": "b1"},
+	},
+	{
+		"Can get snapshot after changed file",
+		2,
+		map[string]string{"d": "", "d/a.txt": "a2", "d/b.txt": "b1"},
+	},
+	{
+		"Can get snapshot after deleted file",
+		3,
+		map[string]string{"d": "", "d/a.txt": "a2", "d/c.txt": "c3"},
+	},
+	{
+		"Can get latest headers without snapshot",
+		0,
+		map[string]string{"d": "", "d/a.txt": "a2", "d/c.txt": "c3"},
+	},
+}
+
+func TestMetadataPersister_SetSnapshot(t *testing.T) {
+	for _, tt := range setSnapshotTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			p, err := createIndexedMetadataPersister(filepath.Join(t.TempDir(), "metadata.sqlite"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := p.SetSnapshot(ctx, tt.session); err != nil {
+				t.Errorf("SetSnapshot() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			root, err := p.GetRootPath(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if root != "d" {
+				t.Errorf("GetRootPath() = %v, want %v", root, "d")
+			}
+
+			hdrs, err := p.GetHeaders(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]string{}
+			for _, hdr := range hdrs {
+				got[hdr.Name] = hdr.Hash
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetHeaders() = %v, want %v", got, tt.want)
+			}
+
+			children, err := p.GetHeaderDirectChildren(ctx, "d", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(children) != len(tt.want)-1 {
+				t.Errorf("GetHeaderDirectChildren() returned %v headers, want %v", len(children), len(tt.want)-1)
+			}
+
+			// Content can only be referenced if it is in the snapshot
+			for _, hash := range []string{"a1", "a2", "b1", "c3"} {
+				_, err := p.GetHeaderByHash(ctx, hash)
+				if wantFound := containsHash(tt.want, hash); (err == nil) != wantFound {
+					t.Errorf("GetHeaderByHash(%v) error = %v, want found %v", hash, err, wantFound)
+				}
+			}
+
+			// Writes go to the latest headers, not to the snapshot
+			if err := p.UpsertHeader(ctx, newMetadataTestHeader("d/f.txt", "f4", 4), false); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := p.GetHeader(ctx, "d/f.txt"); (err == nil) != (tt.session <= 0) {
+				t.Errorf("GetHeader() in snapshot %v error = %v", tt.session, err)
+			}
+
+			if err := p.SetSnapshot(ctx, 0); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := p.GetHeader(ctx, "d/f.txt"); err != nil {
+				t.Errorf("GetHeader() error = %v, wantErr %v", err, false)
+			}
+		})
+	}
+}
+
+// createIndexedMetadataPersister creates a metadata persister which has indexed the metadataTestOperations
+func createIndexedMetadataPersister(dbPath string) (*MetadataPersister, error) {
+	ctx := context.Background()
+
+	p := NewMetadataPersister(dbPath)
+	if err := p.Open(); err != nil {
+		return nil, err
+	}
+
+	for _, operation := range metadataTestOperations {
+		if operation.session == 1 {
+			root := newMetadataTestHeader("d", "", operation.session)
+			root.Typeflag = tar.TypeDir
+
+			if err := p.UpsertHeader(ctx, root, false); err != nil {
+				return nil, err
+			}
+		}
+
+		for name, hash := range operation.upserts {
+			if err := p.UpsertHeader(ctx, newMetadataTestHeader(name, hash, operation.session), false); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, name := range operation.deletes {
+			if _, err := p.DeleteHeader(ctx, name, 0, operation.session, 0); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := p.AddHeaderVersion(ctx, operation.session, "", 0, operation.session, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func newMetadataTestHeader(name string, hash string, record int64) *config.Header {
+	paxrecords := "{}"
+	if hash != "" {
+		paxrecords = fmt.Sprintf(`{"%v":"%v"}`, records.STFSRecordHash, hash)
+	}
+
+	return &config.Header{
+		Record:          record,
+		Lastknownrecord: record,
+		Typeflag:        tar.TypeReg,
+		Name:            name,
+		Paxrecords:      paxrecords,
+		Format:          int64(tar.FormatPAX),
+		Hash:            hash,
+	}
+}
+
+func containsHash(hdrs map[string]string, hash string) bool {
+	for _, candidate := range hdrs {
+		if candidate == hash {
+			return true
+		}
+	}
+
+	return false
+}
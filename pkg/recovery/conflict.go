@@ -0,0 +1,79 @@
+package recovery
+
+import (
+	"archive/tar"
+	"os"
+	"strconv"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// ResolveConflict decides what to do if something already exists at to using the conflict policy and returns the path to restore hdr to,
+// or false if it should not be restored; an empty policy overwrites the existing file
+func ResolveConflict(
+	sink config.RestoreSink,
+
+	policy string,
+	hdr *tar.Header,
+	to string,
+
+	onConflict func(event *config.ConflictEvent),
+) (string, bool, error) {
+	info, err := sink.Lstat(to)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return to, true, nil
+		}
+
+		return "", false, err
+	}
+
+	// The children of an existing directory are merged into it
+	if info.IsDir() && hdr.Typeflag == tar.TypeDir {
+		return to, true, nil
+	}
+
+	event := &config.ConflictEvent{
+		Type: config.ConflictEventTypeOverwrite,
+		Path: to,
+		To:   to,
+	}
+
+	switch policy {
+	case config.ConflictPolicySkipExisting:
+		event.Type = config.ConflictEventTypeSkip
+	case config.ConflictPolicyKeepNewer:
+		if !info.ModTime().Before(hdr.ModTime) {
+			event.Type = config.ConflictEventTypeSkipNewer
+		}
+	case config.ConflictPolicyRename:
+		event.Type = config.ConflictEventTypeRename
+
+		for i := 1; ; i++ {
+			candidate := to + "." + strconv.Itoa(i)
+			if _, err := sink.Lstat(candidate); err != nil {
+				if os.IsNotExist(err) {
+					event.To = candidate
+
+					break
+				}
+
+				return "", false, err
+			}
+		}
+	case config.ConflictPolicyOverwrite, "":
+	default:
+		return "", false, config.ErrConflictPolicyUnknown
+	}
+
+	restore := event.Type != config.ConflictEventTypeSkip && event.Type != config.ConflictEventTypeSkipNewer
+	if !restore {
+		event.To = ""
+	}
+
+	if onConflict != nil {
+		onConflict(event)
+	}
+
+	return event.To, restore, nil
+}
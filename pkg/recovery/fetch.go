@@ -3,147 +3,472 @@ package recovery
 import (
 	"archive/tar"
 	"bufio"
+	"context"
 	"io"
-	"io/fs"
 	"path"
 	"path/filepath"
+	"strconv"
 
 	"github.com/pojntfx/stfs/internal/converters"
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
 	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/sparse"
+	"github.com/pojntfx/stfs/internal/xattrext"
 	"github.com/pojntfx/stfs/pkg/compression"
 	"github.com/pojntfx/stfs/pkg/config"
 	"github.com/pojntfx/stfs/pkg/encryption"
+	"github.com/pojntfx/stfs/pkg/parity"
 	"github.com/pojntfx/stfs/pkg/signature"
 )
 
 func Fetch(
 	reader config.DriveReaderConfig,
 	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
 	pipes config.PipeConfig,
 	crypto config.CryptoConfig,
 
-	getDst func(path string, mode fs.FileMode) (io.WriteCloser, error),
-	mkdirAll func(path string, mode fs.FileMode) error,
+	sink config.RestoreSink,
 
 	record int,
 	block int,
 	to string,
 	preview bool,
+	conflictPolicy string,
 
 	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
+	onConflict func(event *config.ConflictEvent),
+	onProgress func(event *config.ProgressEvent),
 ) error {
+	return FetchContext(
+		context.Background(),
+
+		reader,
+		mt,
+		changeVolume,
+		pipes,
+		crypto,
+
+		sink,
+
+		record,
+		block,
+		to,
+		preview,
+		conflictPolicy,
+
+		onHeader,
+		onCorrection,
+		onConflict,
+		onProgress,
+	)
+}
+
+// FetchContext is like Fetch, but stops copying the content once ctx has been cancelled
+func FetchContext(
+	ctx context.Context,
+
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	sink config.RestoreSink,
+
+	record int,
+	block int,
+	to string,
+	preview bool,
+	conflictPolicy string,
+
+	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
+	onConflict func(event *config.ConflictEvent),
+	onProgress func(event *config.ProgressEvent),
+) (err error) {
 	to = filepath.ToSlash(to)
 
-	var tr *tar.Reader
-	if reader.DriveIsRegular {
-		// Seek to record and block
-		if _, err := reader.Drive.Seek(int64((pipes.RecordSize*config.MagneticTapeBlockSize*record)+block*config.MagneticTapeBlockSize), io.SeekStart); err != nil {
-			return err
-		}
+	tr, hdr, err := readHeaderAt(reader, mt, pipes, crypto, record, block, onCorrection)
+	if err != nil {
+		return err
+	}
 
-		tr = tar.NewReader(reader.Drive)
-	} else {
-		// Seek to record
-		if err := mt.SeekToRecordOnTape(reader.Drive.Fd(), int32(record)); err != nil {
-			return err
+	tracker := progress.NewTracker(config.ProgressEventTypeRestore, 1, hdr.Size, onProgress)
+	tracker.Start(hdr.Name)
+	defer func() {
+		if err == nil {
+			tracker.Done()
 		}
 
-		// Seek to block
-		br := bufio.NewReaderSize(reader.Drive, config.MagneticTapeBlockSize*pipes.RecordSize)
-		if _, err := br.Read(make([]byte, block*config.MagneticTapeBlockSize)); err != nil {
+		tracker.Finish()
+	}()
+
+	if onHeader != nil {
+		dbhdr, err := converters.TarHeaderToDBHeader(int64(record), -1, int64(block), -1, hdr)
+		if err != nil {
 			return err
 		}
+		dbhdr.Volume = int64(reader.Volume)
 
-		tr = tar.NewReader(br)
+		onHeader(converters.DBHeaderToConfigHeader(dbhdr))
 	}
 
-	hdr, err := tr.Next()
+	if !preview {
+		return restoreHeader(ctx, reader, mt, changeVolume, pipes, crypto, sink, tr, hdr, to, conflictPolicy, onCorrection, onConflict, tracker)
+	}
+
+	return nil
+}
+
+// restoreHeader creates the file, directory, symlink or device of hdr, which tr is positioned at, at to using sink unless the conflict policy skips it
+func restoreHeader(
+	ctx context.Context,
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	sink config.RestoreSink,
+
+	tr *tar.Reader,
+	hdr *tar.Header,
+	to string,
+	conflictPolicy string,
+
+	onCorrection func(event *config.CorrectionEvent),
+	onConflict func(event *config.ConflictEvent),
+	tracker *progress.Tracker,
+) error {
+	// Hard links have no content; their target has to be fetched instead
+	if hdr.Typeflag == tar.TypeLink {
+		return config.ErrIsHardlink
+	}
+
+	if to == "" {
+		to = path.Base(hdr.Name)
+	}
+
+	to, restore, err := ResolveConflict(sink, conflictPolicy, hdr, to, onConflict)
 	if err != nil {
 		return err
 	}
 
-	if err := encryption.DecryptHeader(hdr, pipes.Encryption, crypto.Identity); err != nil {
-		return err
+	if !restore {
+		return nil
 	}
 
-	if err := signature.VerifyHeader(hdr, reader.DriveIsRegular, pipes.Signature, crypto.Recipient); err != nil {
-		return err
+	// Restore the extended attributes once the file or directory has been written
+	restoreXattrs := func() error {
+		xattrs := xattrext.GetFromPAXRecords(hdr.PAXRecords)
+		if len(xattrs) == 0 {
+			return nil
+		}
+
+		return sink.SetXattrs(to, xattrs)
 	}
 
-	if onHeader != nil {
-		dbhdr, err := converters.TarHeaderToDBHeader(int64(record), -1, int64(block), -1, hdr)
-		if err != nil {
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err := sink.CreateDirectory(to, hdr); err != nil {
 			return err
 		}
 
-		onHeader(converters.DBHeaderToConfigHeader(dbhdr))
-	}
+		if err := restoreXattrs(); err != nil {
+			return err
+		}
 
-	if !preview {
-		if to == "" {
-			to = path.Base(hdr.Name)
+		return sink.SetMetadata(to, hdr)
+	case tar.TypeSymlink:
+		if err := sink.CreateSymlink(to, hdr); err != nil {
+			return err
 		}
 
-		if hdr.Typeflag == tar.TypeDir {
-			return mkdirAll(to, hdr.FileInfo().Mode())
+		return sink.SetMetadata(to, hdr)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		if err := sink.CreateDevice(to, hdr); err != nil {
+			return err
 		}
 
-		dstFile, err := getDst(to, hdr.FileInfo().Mode())
-		if err != nil {
+		if err := restoreXattrs(); err != nil {
 			return err
 		}
 
-		// Don't decompress non-regular files
-		if !hdr.FileInfo().Mode().IsRegular() {
-			if _, err := io.Copy(dstFile, tr); err != nil {
-				return err
-			}
+		return sink.SetMetadata(to, hdr)
+	}
 
-			return nil
+	dstFile, err := sink.CreateFile(to, hdr)
+	if err != nil {
+		return err
+	}
+
+	// Don't decompress non-regular files
+	if !hdr.FileInfo().Mode().IsRegular() {
+		if _, err := io.Copy(tracker.Writer(dstFile), tracker.Reader(&ioext.ContextReader{Context: ctx, Reader: tr})); err != nil {
+			_ = dstFile.Close()
+
+			return err
 		}
+	} else if _, err := copyPayload(ctx, tracker.Writer(dstFile), reader, mt, changeVolume, pipes, crypto, tr, hdr, onCorrection, tracker); err != nil {
+		_ = dstFile.Close()
+
+		return err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+
+	if err := restoreXattrs(); err != nil {
+		return err
+	}
+
+	return sink.SetMetadata(to, hdr)
+}
+
+// copyPayload decrypts, decompresses and verifies the payload of hdr, which tr is positioned at, and returns the header of the payload
+func copyPayload(
+	ctx context.Context,
+	dst io.Writer,
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
 
-		decryptor, err := encryption.Decrypt(tr, pipes.Encryption, crypto.Identity)
+	tr *tar.Reader,
+	hdr *tar.Header,
+
+	onCorrection func(event *config.CorrectionEvent),
+	tracker *progress.Tracker,
+) (*tar.Header, error) {
+	// Follow references to deduplicated content
+	payloadHdr := hdr
+	if referencedRecord, ok := hdr.PAXRecords[records.STFSRecordReferencesRecord]; ok {
+		record, err := strconv.Atoi(referencedRecord)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		decompressor, err := compression.Decompress(decryptor, pipes.Compression)
+		block, err := strconv.Atoi(hdr.PAXRecords[records.STFSRecordReferencesBlock])
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		sig := ""
-		if hdr.PAXRecords != nil {
-			if s, ok := hdr.PAXRecords[records.STFSRecordSignature]; ok {
-				sig = s
+		if referencedVolume, ok := hdr.PAXRecords[records.STFSRecordReferencesVolume]; ok {
+			volume, err := strconv.Atoi(referencedVolume)
+			if err != nil {
+				return nil, err
+			}
+
+			if volume != reader.Volume {
+				if changeVolume == nil {
+					return nil, config.ErrVolumeChangeUnsupported
+				}
+
+				reader, err = changeVolume(volume)
+				if err != nil {
+					return nil, err
+				}
 			}
 		}
 
-		verifier, verify, err := signature.Verify(decompressor, reader.DriveIsRegular, pipes.Signature, crypto.Recipient, sig)
+		tr, payloadHdr, err = readHeaderAt(reader, mt, pipes, crypto, record, block, onCorrection)
 		if err != nil {
-			return err
+			return nil, err
 		}
+	}
 
-		if _, err := io.Copy(dstFile, verifier); err != nil {
-			return err
+	// Continue reading on the next volumes if the payload spans several of them
+	payload := &continuationReader{
+		tr:           tr,
+		name:         payloadHdr.Name,
+		reader:       reader,
+		mt:           mt,
+		changeVolume: changeVolume,
+		pipes:        pipes,
+		crypto:       crypto,
+		onCorrection: onCorrection,
+	}
+
+	decryptor, err := encryption.Decrypt(tracker.Reader(payload), pipes.Encryption, crypto.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressor, err := compression.Decompress(decryptor, pipes.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := ""
+	if payloadHdr.PAXRecords != nil {
+		if s, ok := payloadHdr.PAXRecords[records.STFSRecordSignature]; ok {
+			sig = s
 		}
+	}
 
-		if err := verify(); err != nil {
-			return err
+	verifier, verify, err := signature.Verify(decompressor, reader.DriveIsRegular, pipes.Signature, crypto.Recipient, sig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recreate the holes of sparse files instead of writing zeros
+	var out io.Writer = dst
+	var sparseWriter *sparse.Writer
+	if sparseMap, ok := payloadHdr.PAXRecords[records.STFSRecordSparseMap]; ok {
+		regions, err := sparse.DecodeMap(sparseMap)
+		if err != nil {
+			return nil, err
 		}
 
-		if err := decryptor.Close(); err != nil {
-			return err
+		sparseWriter = sparse.NewWriter(dst, regions)
+		out = sparseWriter
+	}
+
+	if _, err := io.Copy(out, &ioext.ContextReader{Context: ctx, Reader: verifier}); err != nil {
+		return nil, err
+	}
+
+	if sparseWriter != nil {
+		size, err := strconv.ParseInt(payloadHdr.PAXRecords[records.STFSRecordUncompressedSize], 10, 64)
+		if err != nil {
+			return nil, err
 		}
 
-		if err := decompressor.Close(); err != nil {
-			return err
+		if err := sparseWriter.Finish(size); err != nil {
+			return nil, err
 		}
+	}
 
-		if err := dstFile.Close(); err != nil {
-			return err
+	if err := verify(); err != nil {
+		return nil, err
+	}
+
+	if err := decryptor.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := decompressor.Close(); err != nil {
+		return nil, err
+	}
+
+	return payloadHdr, nil
+}
+
+func readHeaderAt(
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	record int,
+	block int,
+
+	onCorrection func(event *config.CorrectionEvent),
+) (*tar.Reader, *tar.Header, error) {
+	reader, mt, err := openParity(reader, mt, pipes, onCorrection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tr *tar.Reader
+	if reader.DriveIsRegular {
+		// Seek to record and block
+		if _, err := reader.Drive.Seek(int64((pipes.RecordSize*config.MagneticTapeBlockSize*record)+block*config.MagneticTapeBlockSize), io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+
+		tr = tar.NewReader(reader.Drive)
+	} else {
+		// Seek to record
+		if err := mt.SeekToRecordOnTape(reader.Drive.Fd(), int32(record)); err != nil {
+			return nil, nil, err
+		}
+
+		// Seek to block
+		br := bufio.NewReaderSize(reader.Drive, config.MagneticTapeBlockSize*pipes.RecordSize)
+		if _, err := br.Read(make([]byte, block*config.MagneticTapeBlockSize)); err != nil {
+			return nil, nil, err
 		}
+
+		tr = tar.NewReader(br)
 	}
 
-	return nil
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := encryption.DecryptHeader(hdr, pipes.Encryption, crypto.Identity); err != nil {
+		return nil, nil, err
+	}
+
+	if err := signature.VerifyHeader(hdr, reader.DriveIsRegular, pipes.Signature, crypto.Recipient); err != nil {
+		return nil, nil, err
+	}
+
+	return tr, hdr, nil
+}
+
+type continuationReader struct {
+	tr   *tar.Reader
+	name string
+	read int64
+
+	reader       config.DriveReaderConfig
+	mt           config.MagneticTapeIO
+	changeVolume func(volume int) (config.DriveReaderConfig, error)
+	pipes        config.PipeConfig
+	crypto       config.CryptoConfig
+	onCorrection func(event *config.CorrectionEvent)
+}
+
+func (r *continuationReader) Read(p []byte) (int, error) {
+	n, err := r.tr.Read(p)
+	r.read += int64(n)
+
+	if err != io.ErrUnexpectedEOF || r.changeVolume == nil {
+		return n, err
+	}
+
+	// The volume ended in the middle of the payload, so continue with the continuation header on the next volume
+	r.reader, err = r.changeVolume(r.reader.Volume + 1)
+	if err != nil {
+		return n, err
+	}
+
+	tr, hdr, err := readHeaderAt(r.reader, r.mt, r.pipes, r.crypto, 0, 0, r.onCorrection)
+	if err != nil {
+		return n, err
+	}
+
+	if hdr.Name != r.name || hdr.PAXRecords[records.STFSRecordContinuationOffset] != strconv.FormatInt(r.read, 10) {
+		return n, config.ErrContinuationMissing
+	}
+
+	r.tr = tr
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return r.Read(p)
+}
+
+// openParity rebuilds damaged records from the parity records if they are enabled; the returned mt has to be used for the returned reader
+func openParity(
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	pipes config.PipeConfig,
+	onCorrection func(event *config.CorrectionEvent),
+) (config.DriveReaderConfig, config.MagneticTapeIO, error) {
+	if pipes.ParityShards <= 0 {
+		return reader, mt, nil
+	}
+
+	return parity.NewReader(reader, mt, pipes.RecordSize, pipes.DataShards, pipes.ParityShards, onCorrection)
 }
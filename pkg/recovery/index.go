@@ -13,6 +13,7 @@ import (
 	"github.com/pojntfx/stfs/internal/converters"
 	models "github.com/pojntfx/stfs/internal/db/sqlite/models/metadata"
 	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
 	"github.com/pojntfx/stfs/internal/records"
 	"github.com/pojntfx/stfs/internal/suffix"
 	"github.com/pojntfx/stfs/pkg/config"
@@ -41,13 +42,85 @@ func Index(
 	) error,
 
 	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
+	onProgress func(event *config.ProgressEvent),
+) error {
+	return IndexContext(
+		context.Background(),
+
+		reader,
+		mt,
+		metadata,
+		pipes,
+		crypto,
+
+		record,
+		block,
+		overwrite,
+		initializing,
+		offset,
+
+		decryptHeader,
+		verifyHeader,
+
+		onHeader,
+		onCorrection,
+		onProgress,
+	)
+}
+
+// IndexContext is like Index, but stops before indexing the next header once ctx has been cancelled; the headers which have been indexed until then are kept
+func IndexContext(
+	ctx context.Context,
+
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	metadata config.MetadataConfig,
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	record int,
+	block int,
+	overwrite bool,
+	initializing bool,
+	offset int,
+
+	decryptHeader func(
+		hdr *tar.Header,
+		i int,
+	) error,
+	verifyHeader func(
+		hdr *tar.Header,
+		isRegular bool,
+	) error,
+
+	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
+	onProgress func(event *config.ProgressEvent),
 ) error {
 	if overwrite {
-		if err := metadata.Metadata.PurgeAllHeaders(context.Background()); err != nil {
+		if err := metadata.Metadata.PurgeAllHeaders(ctx); err != nil {
+			return err
+		}
+	}
+
+	reader, mt, err := openParity(reader, mt, pipes, onCorrection)
+	if err != nil {
+		return err
+	}
+
+	// The size of tapes is unknown, so only tar files have an ETA
+	total := int64(0)
+	if reader.DriveIsRegular && onProgress != nil {
+		total, err = reader.Drive.Seek(0, io.SeekEnd)
+		if err != nil {
 			return err
 		}
 	}
 
+	tracker := progress.NewTracker(config.ProgressEventTypeIndex, 0, total, onProgress)
+	defer tracker.Finish()
+
 	if reader.DriveIsRegular {
 		// Seek to record and block
 		if _, err := reader.Drive.Seek(int64((pipes.RecordSize*config.MagneticTapeBlockSize*record)+block*config.MagneticTapeBlockSize), 0); err != nil {
@@ -82,7 +155,8 @@ func Index(
 					}
 
 					// Seek to record and block
-					if _, err := reader.Drive.Seek(int64((pipes.RecordSize*config.MagneticTapeBlockSize*int(record))+int(block)*config.MagneticTapeBlockSize), io.SeekStart); err != nil {
+					start := int64((pipes.RecordSize * config.MagneticTapeBlockSize * int(record)) + int(block)*config.MagneticTapeBlockSize)
+					if _, err := reader.Drive.Seek(start, io.SeekStart); err != nil {
 						return err
 					}
 
@@ -95,6 +169,11 @@ func Index(
 							break
 						}
 
+						// A zero block can be followed by a header, so retry at the next block
+						if _, err := reader.Drive.Seek(start+config.MagneticTapeBlockSize, io.SeekStart); err != nil {
+							return err
+						}
+
 						continue
 					}
 
@@ -103,8 +182,10 @@ func Index(
 			}
 
 			if hdr == nil {
-				// Try to skip over the next file mark; this makes it possible to append to a tar file created by i.e. GNU tar
-				if _, err := reader.Drive.Read(make([]byte, config.MagneticTapeBlockSize*2)); err != nil {
+				// Continue after the zero blocks until the end of the tar file; this makes it possible to append to a tar file created by i.e. GNU tar,
+				// and to find the headers after the zero records which complete the last parity group of a previous write
+				n, err := reader.Drive.Read(make([]byte, config.MagneticTapeBlockSize))
+				if err != nil {
 					if err == io.EOF {
 						// EOF
 						break
@@ -113,10 +194,18 @@ func Index(
 					return err
 				}
 
+				if _, err := reader.Drive.Seek(-int64(n), io.SeekCurrent); err != nil {
+					return err
+				}
+
 				continue
 			}
 
 			if i >= offset {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
 				if err := decryptHeader(hdr, i-offset); err != nil {
 					return err
 				}
@@ -125,9 +214,13 @@ func Index(
 					return err
 				}
 
-				if err := indexHeader(record, block, hdr, metadata.Metadata, pipes.Compression, pipes.Encryption, initializing, onHeader); err != nil {
+				tracker.Start(hdr.Name)
+
+				if err := indexHeader(int64(reader.Volume), record, block, hdr, metadata.Metadata, pipes.Compression, pipes.Encryption, initializing, onHeader); err != nil {
 					return err
 				}
+
+				tracker.Done()
 			}
 
 			curr, err := reader.Drive.Seek(0, io.SeekCurrent)
@@ -136,6 +229,11 @@ func Index(
 			}
 
 			if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+				// The last file on a full volume continues on the next volume
+				if err == io.ErrUnexpectedEOF {
+					break
+				}
+
 				return err
 			}
 
@@ -144,6 +242,8 @@ func Index(
 				return err
 			}
 
+			tracker.SetRead(currAndSize)
+
 			nextTotalBlocks := math.Ceil(float64(curr+(currAndSize-curr)) / float64(config.MagneticTapeBlockSize))
 			record = int64(nextTotalBlocks) / int64(pipes.RecordSize)
 			block = int64(nextTotalBlocks) - (record * int64(pipes.RecordSize))
@@ -197,12 +297,19 @@ func Index(
 					tr = tar.NewReader(counter)
 
 					continue
+				} else if err == io.ErrUnexpectedEOF {
+					// The last header on a full volume has been rewritten to the next volume
+					break
 				} else {
 					return err
 				}
 			}
 
 			if i >= offset {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
 				if err := decryptHeader(hdr, i-offset); err != nil {
 					return err
 				}
@@ -211,19 +318,30 @@ func Index(
 					return err
 				}
 
-				if err := indexHeader(record, block, hdr, metadata.Metadata, pipes.Compression, pipes.Encryption, initializing, onHeader); err != nil {
+				tracker.Start(hdr.Name)
+
+				if err := indexHeader(int64(reader.Volume), record, block, hdr, metadata.Metadata, pipes.Compression, pipes.Encryption, initializing, onHeader); err != nil {
 					return err
 				}
+
+				tracker.Done()
 			}
 
 			curr = int64(counter.BytesRead)
 
 			if _, err := io.Copy(ioutil.Discard, tr); err != nil {
+				// The last file on a full volume continues on the next volume
+				if err == io.ErrUnexpectedEOF {
+					break
+				}
+
 				return err
 			}
 
 			currAndSize := int64(counter.BytesRead)
 
+			tracker.SetRead(currAndSize)
+
 			nextTotalBlocks := math.Ceil(float64(curr+(currAndSize-curr)) / float64(config.MagneticTapeBlockSize))
 			record = int64(nextTotalBlocks) / int64(pipes.RecordSize)
 			block = int64(nextTotalBlocks) - (record * int64(pipes.RecordSize))
@@ -240,8 +358,9 @@ func Index(
 	return nil
 }
 
+// indexHeader isn't cancellable so that every header is either indexed completely or not at all
 func indexHeader(
-	record, block int64,
+	volume, record, block int64,
 	hdr *tar.Header,
 	metadataPersister config.MetadataPersister,
 	compressionFormat string,
@@ -267,11 +386,26 @@ func indexHeader(
 		hdr.Name = newName
 	}
 
+	// Continuation headers only move the last known location of the file they continue
+	if _, ok := hdr.PAXRecords[records.STFSRecordContinuationOffset]; ok {
+		continuedHdr, err := metadataPersister.GetHeader(context.Background(), hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		continuedHdr.Lastknownvolume = volume
+		continuedHdr.Lastknownrecord = record
+		continuedHdr.Lastknownblock = block
+
+		return metadataPersister.UpdateHeaderMetadata(context.Background(), continuedHdr)
+	}
+
 	if onHeader != nil {
 		dbhdr, err := converters.TarHeaderToDBHeader(record, -1, block, -1, hdr)
 		if err != nil {
 			return err
 		}
+		dbhdr.Volume = volume
 
 		onHeader(converters.DBHeaderToConfigHeader(dbhdr))
 	}
@@ -294,12 +428,18 @@ func indexHeader(
 			if err != nil {
 				return err
 			}
+			dbhdr.Volume = volume
+			dbhdr.Lastknownvolume = volume
 
 			if err := metadataPersister.UpsertHeader(context.Background(), converters.DBHeaderToConfigHeader(dbhdr), initializing); err != nil {
 				return err
 			}
 		case records.STFSRecordActionDelete:
-			if _, err := metadataPersister.DeleteHeader(context.Background(), hdr.Name, record, block); err != nil {
+			if _, err := metadataPersister.DeleteHeader(context.Background(), hdr.Name, volume, record, block); err != nil {
+				return err
+			}
+		case records.STFSRecordActionUndelete:
+			if _, err := metadataPersister.UndeleteHeader(context.Background(), hdr.Name, hdr.Linkname, volume, record, block); err != nil {
 				return err
 			}
 		case records.STFSRecordActionUpdate:
@@ -317,6 +457,8 @@ func indexHeader(
 				if err != nil {
 					return err
 				}
+				h.Volume = volume
+				h.Lastknownvolume = volume
 
 				newHdr = h
 
@@ -331,6 +473,8 @@ func indexHeader(
 					if err != nil {
 						return err
 					}
+					h.Volume = oldHdr.Volume
+					h.Lastknownvolume = volume
 
 					newHdr = h
 
@@ -347,7 +491,7 @@ func indexHeader(
 
 			if moveAfterEdits {
 				// Move header (will be a no-op if the header has been moved before)
-				if err := metadataPersister.MoveHeader(context.Background(), oldName, hdr.Name, record, block); err != nil {
+				if err := metadataPersister.MoveHeader(context.Background(), oldName, hdr.Name, volume, record, block); err != nil {
 					return err
 				}
 			}
@@ -359,5 +503,15 @@ func indexHeader(
 		return config.ErrSTFSVersionUnsupported
 	}
 
-	return nil
+	// Keep the previous versions so that the index can be browsed as it was at any time
+	session := int64(0)
+	if rawSession, ok := hdr.PAXRecords[records.STFSRecordSession]; ok {
+		var err error
+		session, err = strconv.ParseInt(rawSession, 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+
+	return metadataPersister.AddHeaderVersion(context.Background(), session, hdr.PAXRecords[records.STFSRecordReplacesName], volume, record, block)
 }
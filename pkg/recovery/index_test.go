@@ -0,0 +1,209 @@
+package recovery
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/parity"
+	"github.com/pojntfx/stfs/pkg/persisters"
+)
+
+const (
+	recordSize = 20
+)
+
+type indexPosition struct {
+	record int64
+	block  int64
+}
+
+var indexTests = []struct {
+	name         string
+	dataShards   int
+	parityShards int
+	writes       [][]string // Names of the files to write in each write, which are appended to the tar file
+	padding      int        // Zero blocks to append after each write, like GNU tar does
+	want         map[string]indexPosition
+}{
+	{
+		"Can index one write",
+		0,
+		0,
+		[][]string{{"a.txt", "b.txt"}},
+		0,
+		map[string]indexPosition{"a.txt": {0, 0}, "b.txt": {0, 4}},
+	},
+	{
+		"Can index appended writes",
+		0,
+		0,
+		[][]string{{"a.txt"}, {"b.txt"}},
+		0,
+		map[string]indexPosition{"a.txt": {0, 0}, "b.txt": {0, 6}}, // After the header, payload and trailer of the first write
+	},
+	{
+		"Can index appended writes after an odd amount of zero blocks",
+		0,
+		0,
+		[][]string{{"a.txt"}, {"b.txt"}},
+		3,
+		map[string]indexPosition{"a.txt": {0, 0}, "b.txt": {0, 9}},
+	},
+	{
+		"Can index appended writes with parity",
+		4,
+		2,
+		[][]string{{"a.txt"}, {"b.txt"}, {"c.txt"}},
+		0,
+		map[string]indexPosition{"a.txt": {0, 0}, "b.txt": {4, 0}, "c.txt": {8, 0}}, // Every write completes its last group
+	},
+}
+
+func TestIndex(t *testing.T) {
+	for _, tt := range indexTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			drive := filepath.Join(dir, "drive.tar")
+			for _, names := range tt.writes {
+				if err := writeIndexTestFiles(drive, names, tt.padding, tt.dataShards, tt.parityShards); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			metadataPersister := persisters.NewMetadataPersister(filepath.Join(dir, "metadata.sqlite"))
+			if err := metadataPersister.Open(); err != nil {
+				t.Fatal(err)
+			}
+
+			f, err := os.Open(drive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			if err := Index(
+				config.DriveReaderConfig{Drive: f, DriveIsRegular: true},
+				nil,
+				config.MetadataConfig{Metadata: metadataPersister},
+				config.PipeConfig{
+					Compression:  config.NoneKey,
+					Encryption:   config.NoneKey,
+					Signature:    config.NoneKey,
+					RecordSize:   recordSize,
+					DataShards:   tt.dataShards,
+					ParityShards: tt.parityShards,
+				},
+				config.CryptoConfig{},
+
+				0,
+				0,
+				true,
+				false,
+				0,
+
+				func(hdr *tar.Header, i int) error {
+					return nil
+				},
+				func(hdr *tar.Header, isRegular bool) error {
+					return nil
+				},
+
+				nil,
+				nil,
+				nil,
+			); err != nil {
+				t.Errorf("Index() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			dbhdrs, err := metadataPersister.GetHeaders(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(dbhdrs) != len(tt.want) {
+				t.Errorf("Index() indexed %v headers, want %v", len(dbhdrs), len(tt.want))
+			}
+
+			for _, dbhdr := range dbhdrs {
+				want, ok := tt.want[dbhdr.Name]
+				if !ok {
+					t.Errorf("Index() indexed unexpected header %v", dbhdr.Name)
+
+					continue
+				}
+
+				if got := (indexPosition{dbhdr.Record, dbhdr.Block}); got != want {
+					t.Errorf("Index() position of %v = %v, want %v", dbhdr.Name, got, want)
+				}
+
+				// The PAX records are only kept if the header is read from its start
+				if dbhdr.Paxrecords == "" || dbhdr.Paxrecords == "{}" {
+					t.Errorf("Index() lost the PAX records of %v", dbhdr.Name)
+				}
+			}
+		})
+	}
+}
+
+// writeIndexTestFiles appends a write with one block of content per file to the tar file at drive
+func writeIndexTestFiles(drive string, names []string, padding int, dataShards int, parityShards int) error {
+	f, err := os.OpenFile(drive, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var pw *parity.Writer
+	if parityShards > 0 {
+		pw, err = parity.NewWriter(f, true, 0, recordSize, dataShards, parityShards)
+		if err != nil {
+			return err
+		}
+
+		w = pw
+	}
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0600,
+			Size:     int64(len(name)),
+			Format:   tar.FormatPAX,
+			PAXRecords: map[string]string{
+				records.STFSRecordUncompressedSize: "5",
+			},
+		}); err != nil {
+			return err
+		}
+
+		if _, err := tw.Write([]byte(name)); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(make([]byte, padding*config.MagneticTapeBlockSize)); err != nil {
+		return err
+	}
+
+	if pw != nil {
+		return pw.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,329 @@
+package recovery
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/progress"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+	"github.com/pojntfx/stfs/pkg/encryption"
+	"github.com/pojntfx/stfs/pkg/signature"
+)
+
+func FetchPass(
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	sink config.RestoreSink,
+
+	entries []*config.RestorePlanEntry,
+	conflictPolicy string,
+
+	onStart func(entry *config.RestorePlanEntry) func(event *config.ProgressEvent),
+	onDone func(entry *config.RestorePlanEntry),
+	onCorrection func(event *config.CorrectionEvent),
+	onConflict func(event *config.ConflictEvent),
+) error {
+	return FetchPassContext(
+		context.Background(),
+
+		reader,
+		mt,
+		changeVolume,
+		pipes,
+		crypto,
+
+		sink,
+
+		entries,
+		conflictPolicy,
+
+		onStart,
+		onDone,
+		onCorrection,
+		onConflict,
+	)
+}
+
+// FetchPassContext restores the entries, which have to be on the volume of reader and sorted by their position, in one sequential read;
+// the files between them are read over instead of seeking to every entry, which is much faster on tapes. Deduplicated content on other volumes is read with changeVolume,
+// after which the volume of reader is opened again. onStart returns the progress callback for an entry.
+func FetchPassContext(
+	ctx context.Context,
+
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	sink config.RestoreSink,
+
+	entries []*config.RestorePlanEntry,
+	conflictPolicy string,
+
+	onStart func(entry *config.RestorePlanEntry) func(event *config.ProgressEvent),
+	onDone func(entry *config.RestorePlanEntry),
+	onCorrection func(event *config.CorrectionEvent),
+	onConflict func(event *config.ConflictEvent),
+) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	parityReader, parityMT, err := openParity(reader, mt, pipes, onCorrection)
+	if err != nil {
+		return err
+	}
+
+	r := &passReader{
+		reader: parityReader,
+		mt:     parityMT,
+		pipes:  pipes,
+	}
+
+	// Deduplicated content can be on other volumes, after which the volume of the pass has to be opened again
+	changedVolume := false
+	followVolume := changeVolume
+	if changeVolume != nil {
+		followVolume = func(volume int) (config.DriveReaderConfig, error) {
+			changedVolume = true
+
+			return changeVolume(volume)
+		}
+	}
+
+	if err := r.seek(r.position(entries[0].Content.Record, entries[0].Content.Block)); err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		target := r.position(entry.Content.Record, entry.Content.Block)
+
+		var hdr *tar.Header
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			next, pos, err := r.next()
+			if err != nil {
+				if err == io.EOF {
+					return config.ErrTarHeaderMissing
+				}
+
+				return err
+			}
+
+			if pos > target {
+				return config.ErrTarHeaderMissing
+			}
+
+			if pos == target {
+				hdr = next
+
+				break
+			}
+
+			if err := r.skip(); err != nil {
+				return err
+			}
+		}
+
+		if err := encryption.DecryptHeader(hdr, pipes.Encryption, crypto.Identity); err != nil {
+			return err
+		}
+
+		if err := signature.VerifyHeader(hdr, reader.DriveIsRegular, pipes.Signature, crypto.Recipient); err != nil {
+			return err
+		}
+
+		afterHdr, err := r.offset()
+		if err != nil {
+			return err
+		}
+
+		var onProgress func(event *config.ProgressEvent)
+		if onStart != nil {
+			onProgress = onStart(entry)
+		}
+
+		tracker := progress.NewTracker(config.ProgressEventTypeRestore, 1, hdr.Size, onProgress)
+		tracker.Start(hdr.Name)
+
+		if err := restoreHeader(ctx, reader, mt, followVolume, pipes, crypto, sink, r.tr, hdr, entry.To, conflictPolicy, onCorrection, onConflict, tracker); err != nil {
+			tracker.Finish()
+
+			return err
+		}
+
+		tracker.Done()
+		tracker.Finish()
+
+		if onDone != nil {
+			onDone(entry)
+		}
+
+		if i == len(entries)-1 {
+			break
+		}
+
+		// Deduplicated content is read from the file it references, so return to the header after it
+		if _, ok := hdr.PAXRecords[records.STFSRecordReferencesRecord]; ok {
+			if changedVolume {
+				reader, err = changeVolume(reader.Volume)
+				if err != nil {
+					return err
+				}
+				changedVolume = false
+
+				r.reader, r.mt, err = openParity(reader, mt, pipes, onCorrection)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := r.seek(alignToBlock(afterHdr)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := r.skip(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// passReader reads the headers of a volume one after another and keeps track of their positions
+type passReader struct {
+	reader config.DriveReaderConfig
+	mt     config.MagneticTapeIO
+	pipes  config.PipeConfig
+
+	tr      *tar.Reader
+	counter *ioext.CounterReader // Tapes can't report their position in bytes, so it is counted instead
+	pos     int64                // Position of the next header in bytes
+}
+
+func (r *passReader) position(record, block int64) int64 {
+	return (record*int64(r.pipes.RecordSize) + block) * config.MagneticTapeBlockSize
+}
+
+func (r *passReader) seek(pos int64) error {
+	if r.reader.DriveIsRegular {
+		if _, err := r.reader.Drive.Seek(pos, io.SeekStart); err != nil {
+			return err
+		}
+
+		r.tr = tar.NewReader(r.reader.Drive)
+		r.pos = pos
+
+		return nil
+	}
+
+	recordSize := int64(r.pipes.RecordSize) * config.MagneticTapeBlockSize
+
+	// Seek to record
+	if err := r.mt.SeekToRecordOnTape(r.reader.Drive.Fd(), int32(pos/recordSize)); err != nil {
+		return err
+	}
+
+	// Seek to block
+	br := bufio.NewReaderSize(r.reader.Drive, int(recordSize))
+	if _, err := br.Read(make([]byte, pos%recordSize)); err != nil {
+		return err
+	}
+
+	r.counter = &ioext.CounterReader{Reader: br, BytesRead: int(pos)}
+	r.tr = tar.NewReader(r.counter)
+	r.pos = pos
+
+	return nil
+}
+
+func (r *passReader) offset() (int64, error) {
+	if r.reader.DriveIsRegular {
+		return r.reader.Drive.Seek(0, io.SeekCurrent)
+	}
+
+	return int64(r.counter.BytesRead), nil
+}
+
+// next returns the next header and its position, continuing with the next session at the trailer of a session
+func (r *passReader) next() (*tar.Header, int64, error) {
+	for {
+		hdr, err := r.tr.Next()
+		if err == nil {
+			return hdr, r.pos, nil
+		}
+
+		if err != io.EOF {
+			return nil, -1, err
+		}
+
+		if r.reader.DriveIsRegular {
+			curr, err := r.offset()
+			if err != nil {
+				return nil, -1, err
+			}
+
+			// Nothing has been read, so this is the end of the tar file
+			if curr <= r.pos {
+				return nil, -1, io.EOF
+			}
+
+			r.tr = tar.NewReader(r.reader.Drive)
+			r.pos = curr
+
+			continue
+		}
+
+		if err := r.mt.GoToNextFileOnTape(r.reader.Drive.Fd()); err != nil {
+			// EOD
+			return nil, -1, io.EOF
+		}
+
+		record, err := r.mt.GetCurrentRecordFromTape(r.reader.Drive.Fd())
+		if err != nil {
+			return nil, -1, err
+		}
+
+		br := bufio.NewReaderSize(r.reader.Drive, config.MagneticTapeBlockSize*r.pipes.RecordSize)
+		r.pos = record * int64(r.pipes.RecordSize) * config.MagneticTapeBlockSize
+		r.counter = &ioext.CounterReader{Reader: br, BytesRead: int(r.pos)}
+		r.tr = tar.NewReader(r.counter)
+	}
+}
+
+// skip reads over the rest of the payload of the current header
+func (r *passReader) skip() error {
+	if _, err := io.Copy(ioutil.Discard, r.tr); err != nil {
+		return err
+	}
+
+	curr, err := r.offset()
+	if err != nil {
+		return err
+	}
+
+	r.pos = alignToBlock(curr)
+
+	return nil
+}
+
+// alignToBlock returns the start of the next block, since headers and payloads are padded to full blocks
+func alignToBlock(pos int64) int64 {
+	return ((pos + config.MagneticTapeBlockSize - 1) / config.MagneticTapeBlockSize) * config.MagneticTapeBlockSize
+}
@@ -3,6 +3,7 @@ package recovery
 import (
 	"archive/tar"
 	"bufio"
+	"context"
 	"io"
 	"io/ioutil"
 	"math"
@@ -24,9 +25,46 @@ func Query(
 	block int,
 
 	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
+) ([]*tar.Header, error) {
+	return QueryContext(
+		context.Background(),
+
+		reader,
+		mt,
+		pipes,
+		crypto,
+
+		record,
+		block,
+
+		onHeader,
+		onCorrection,
+	)
+}
+
+// QueryContext is like Query, but stops before reading the next header once ctx has been cancelled and returns the headers which have been read until then
+func QueryContext(
+	ctx context.Context,
+
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	record int,
+	block int,
+
+	onHeader func(hdr *config.Header),
+	onCorrection func(event *config.CorrectionEvent),
 ) ([]*tar.Header, error) {
 	headers := []*tar.Header{}
 
+	reader, mt, err := openParity(reader, mt, pipes, onCorrection)
+	if err != nil {
+		return []*tar.Header{}, err
+	}
+
 	if reader.DriveIsRegular {
 		// Seek to record and block
 		if _, err := reader.Drive.Seek(int64((pipes.RecordSize*config.MagneticTapeBlockSize*record)+block*config.MagneticTapeBlockSize), 0); err != nil {
@@ -87,6 +125,10 @@ func Query(
 				break
 			}
 
+			if err := ctx.Err(); err != nil {
+				return headers, err
+			}
+
 			if err := encryption.DecryptHeader(hdr, pipes.Encryption, crypto.Identity); err != nil {
 				return []*tar.Header{}, err
 			}
@@ -175,6 +217,10 @@ func Query(
 				}
 			}
 
+			if err := ctx.Err(); err != nil {
+				return headers, err
+			}
+
 			if err := encryption.DecryptHeader(hdr, pipes.Encryption, crypto.Identity); err != nil {
 				return []*tar.Header{}, err
 			}
@@ -0,0 +1,93 @@
+package recovery
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/pojntfx/stfs/internal/progress"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// Verify hashes the uncompressed content of the file at record and block and returns the hash which has been recorded when archiving it and the actual one
+func Verify(
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	record int,
+	block int,
+
+	onCorrection func(event *config.CorrectionEvent),
+	onProgress func(event *config.ProgressEvent),
+) (expected string, actual string, err error) {
+	return VerifyContext(
+		context.Background(),
+
+		reader,
+		mt,
+		changeVolume,
+		pipes,
+		crypto,
+
+		record,
+		block,
+
+		onCorrection,
+		onProgress,
+	)
+}
+
+// VerifyContext is like Verify, but stops hashing once ctx has been cancelled
+func VerifyContext(
+	ctx context.Context,
+
+	reader config.DriveReaderConfig,
+	mt config.MagneticTapeIO,
+	changeVolume func(volume int) (config.DriveReaderConfig, error),
+	pipes config.PipeConfig,
+	crypto config.CryptoConfig,
+
+	record int,
+	block int,
+
+	onCorrection func(event *config.CorrectionEvent),
+	onProgress func(event *config.ProgressEvent),
+) (expected string, actual string, err error) {
+	tr, hdr, err := readHeaderAt(reader, mt, pipes, crypto, record, block, onCorrection)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Hard links have no content; their target has to be verified instead
+	if hdr.Typeflag == tar.TypeLink {
+		return "", "", config.ErrIsHardlink
+	}
+
+	if !hdr.FileInfo().Mode().IsRegular() {
+		return "", "", nil
+	}
+
+	tracker := progress.NewTracker(config.ProgressEventTypeVerify, 1, hdr.Size, onProgress)
+	tracker.Start(hdr.Name)
+	defer tracker.Finish()
+
+	hasher := sha256.New()
+	payloadHdr, err := copyPayload(ctx, hasher, reader, mt, changeVolume, pipes, crypto, tr, hdr, onCorrection, tracker)
+	if err != nil {
+		return "", "", err
+	}
+
+	tracker.Done()
+
+	expected, ok := hdr.PAXRecords[records.STFSRecordHash]
+	if !ok {
+		expected = payloadHdr.PAXRecords[records.STFSRecordHash]
+	}
+
+	return expected, hex.EncodeToString(hasher.Sum(nil)), nil
+}
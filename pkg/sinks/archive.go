@@ -0,0 +1,221 @@
+package sinks
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/ioext"
+	"github.com/pojntfx/stfs/internal/records"
+	"github.com/pojntfx/stfs/internal/xattrext"
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// TarSink writes the restored files to a plain tar stream with their real names and metadata, which tools such as GNU tar can read
+type TarSink struct {
+	tw *tar.Writer
+}
+
+func NewTarSink(w io.Writer) *TarSink {
+	return &TarSink{
+		tw: tar.NewWriter(w),
+	}
+}
+
+func (s *TarSink) Lstat(path string) (fs.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *TarSink) CreateFile(path string, hdr *tar.Header) (io.WriteCloser, error) {
+	size, err := getContentSize(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	exportHdr := getExportHeader(path, hdr)
+	exportHdr.Size = size
+
+	if err := s.tw.WriteHeader(exportHdr); err != nil {
+		return nil, err
+	}
+
+	return ioext.AddCloseNopToWriter(s.tw), nil
+}
+
+func (s *TarSink) CreateDirectory(path string, hdr *tar.Header) error {
+	exportHdr := getExportHeader(path, hdr)
+
+	// The root directory has no name in the archive
+	if exportHdr.Name == "/" {
+		return nil
+	}
+
+	return s.tw.WriteHeader(exportHdr)
+}
+
+func (s *TarSink) CreateSymlink(path string, hdr *tar.Header) error {
+	return s.tw.WriteHeader(getExportHeader(path, hdr))
+}
+
+func (s *TarSink) CreateHardlink(path string, target string, hdr *tar.Header) error {
+	exportHdr := getExportHeader(path, hdr)
+	exportHdr.Typeflag = tar.TypeLink
+	exportHdr.Linkname = getExportName(target)
+	exportHdr.Size = 0
+
+	return s.tw.WriteHeader(exportHdr)
+}
+
+func (s *TarSink) CreateDevice(path string, hdr *tar.Header) error {
+	return s.tw.WriteHeader(getExportHeader(path, hdr))
+}
+
+func (s *TarSink) SetXattrs(path string, xattrs map[string]string) error {
+	return nil // The extended attributes have been written with the header
+}
+
+func (s *TarSink) SetMetadata(path string, hdr *tar.Header) error {
+	return nil // The metadata has been written with the header
+}
+
+func (s *TarSink) Close() error {
+	return s.tw.Close()
+}
+
+// ZipSink writes the restored files to a zip stream; zip can't represent hard links, ownership or devices, so hard links get the content of their target and devices are left out
+type ZipSink struct {
+	zw *zip.Writer
+}
+
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{
+		zw: zip.NewWriter(w),
+	}
+}
+
+func (s *ZipSink) Lstat(path string) (fs.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *ZipSink) CreateFile(path string, hdr *tar.Header) (io.WriteCloser, error) {
+	w, err := s.zw.CreateHeader(getZipHeader(path, hdr, zip.Deflate))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioext.AddCloseNopToWriter(w), nil
+}
+
+func (s *ZipSink) CreateDirectory(path string, hdr *tar.Header) error {
+	zipHdr := getZipHeader(path, hdr, zip.Store)
+
+	// The root directory has no name in the archive
+	if zipHdr.Name == "/" {
+		return nil
+	}
+
+	_, err := s.zw.CreateHeader(zipHdr)
+
+	return err
+}
+
+func (s *ZipSink) CreateSymlink(path string, hdr *tar.Header) error {
+	// Zip stores the target of a symlink as its content
+	w, err := s.zw.CreateHeader(getZipHeader(path, hdr, zip.Store))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, hdr.Linkname)
+
+	return err
+}
+
+func (s *ZipSink) CreateHardlink(path string, target string, hdr *tar.Header) error {
+	return config.ErrHardlinksUnsupported
+}
+
+func (s *ZipSink) CreateDevice(path string, hdr *tar.Header) error {
+	return nil
+}
+
+func (s *ZipSink) SetXattrs(path string, xattrs map[string]string) error {
+	return nil
+}
+
+func (s *ZipSink) SetMetadata(path string, hdr *tar.Header) error {
+	return nil // The metadata has been written with the header
+}
+
+func (s *ZipSink) Close() error {
+	return s.zw.Close()
+}
+
+// getExportName returns the name of a restored path in an archive, which is relative and uses slashes
+func getExportName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+// getExportHeader returns a header without the STFS PAX records, which only keeps the extended attributes
+func getExportHeader(path string, hdr *tar.Header) *tar.Header {
+	exportHdr := &tar.Header{
+		Typeflag:   hdr.Typeflag,
+		Name:       getExportName(path),
+		Linkname:   hdr.Linkname,
+		Size:       hdr.Size,
+		Mode:       hdr.Mode,
+		Uid:        hdr.Uid,
+		Gid:        hdr.Gid,
+		Uname:      hdr.Uname,
+		Gname:      hdr.Gname,
+		ModTime:    hdr.ModTime,
+		AccessTime: hdr.AccessTime,
+		ChangeTime: hdr.ChangeTime,
+		Devmajor:   hdr.Devmajor,
+		Devminor:   hdr.Devminor,
+		Format:     tar.FormatPAX,
+	}
+
+	if hdr.Typeflag == tar.TypeDir {
+		exportHdr.Name += "/"
+		exportHdr.Size = 0
+	}
+
+	if xattrs := xattrext.GetFromPAXRecords(hdr.PAXRecords); len(xattrs) > 0 {
+		exportHdr.PAXRecords = map[string]string{}
+		xattrext.AddToPAXRecords(exportHdr.PAXRecords, xattrs)
+	}
+
+	return exportHdr
+}
+
+func getZipHeader(path string, hdr *tar.Header, method uint16) *zip.FileHeader {
+	zipHdr := &zip.FileHeader{
+		Name:     getExportName(path),
+		Method:   method,
+		Modified: hdr.ModTime,
+	}
+	zipHdr.SetMode(hdr.FileInfo().Mode())
+
+	if hdr.Typeflag == tar.TypeDir {
+		zipHdr.Name += "/"
+	}
+
+	return zipHdr
+}
+
+// getContentSize returns the size of the decrypted and decompressed content of hdr
+func getContentSize(hdr *tar.Header) (int64, error) {
+	uncompressedSize, ok := hdr.PAXRecords[records.STFSRecordUncompressedSize]
+	if !ok {
+		return hdr.Size, nil
+	}
+
+	return strconv.ParseInt(uncompressedSize, 10, 64)
+}
@@ -0,0 +1,220 @@
+package sinks
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pojntfx/stfs/internal/xattrext"
+)
+
+type directory struct {
+	path string
+	hdr  *tar.Header
+}
+
+// FilesystemSink restores files, directories, links and device nodes to the file system with their ownership, permissions and times
+type FilesystemSink struct {
+	numericOwner bool        // Use the archived UIDs and GIDs instead of looking up the archived user and group names
+	uidMap       map[int]int // Archived UIDs to the UIDs to restore them with
+	gidMap       map[int]int // Archived GIDs to the GIDs to restore them with
+	privileged   bool        // Also set extended attributes which require privileges
+
+	users       map[string]int
+	groups      map[string]int
+	xattrs      map[string]map[string]string // Set with the metadata, since changing the owner clears file capabilities
+	directories []directory                  // Their metadata is set once their children have been restored
+}
+
+func NewFilesystemSink(
+	numericOwner bool,
+	uidMap map[int]int,
+	gidMap map[int]int,
+	privileged bool,
+) *FilesystemSink {
+	return &FilesystemSink{
+		numericOwner: numericOwner,
+		uidMap:       uidMap,
+		gidMap:       gidMap,
+		privileged:   privileged,
+
+		users:       map[string]int{},
+		groups:      map[string]int{},
+		xattrs:      map[string]map[string]string{},
+		directories: []directory{},
+	}
+}
+
+func (s *FilesystemSink) Lstat(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
+
+func (s *FilesystemSink) CreateFile(path string, hdr *tar.Header) (io.WriteCloser, error) {
+	if err := prepare(path); err != nil {
+		return nil, err
+	}
+
+	// The permissions are set once the content has been written, since they could prevent writing it
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+func (s *FilesystemSink) CreateDirectory(path string, hdr *tar.Header) error {
+	if err := prepare(path); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(path, hdr.FileInfo().Mode().Perm()|0700)
+}
+
+func (s *FilesystemSink) CreateSymlink(path string, hdr *tar.Header) error {
+	if err := prepare(path); err != nil {
+		return err
+	}
+
+	return os.Symlink(hdr.Linkname, path)
+}
+
+func (s *FilesystemSink) CreateHardlink(path string, target string, hdr *tar.Header) error {
+	if err := prepare(path); err != nil {
+		return err
+	}
+
+	return os.Link(target, path)
+}
+
+func (s *FilesystemSink) CreateDevice(path string, hdr *tar.Header) error {
+	if err := prepare(path); err != nil {
+		return err
+	}
+
+	return createDevice(path, hdr)
+}
+
+func (s *FilesystemSink) SetXattrs(path string, xattrs map[string]string) error {
+	s.xattrs[path] = xattrs
+
+	return nil
+}
+
+func (s *FilesystemSink) SetMetadata(path string, hdr *tar.Header) error {
+	// Restoring the children of a directory changes its times, and its permissions could prevent restoring them
+	if hdr.Typeflag == tar.TypeDir {
+		s.directories = append(s.directories, directory{path, hdr})
+
+		return nil
+	}
+
+	return s.setMetadata(path, hdr)
+}
+
+func (s *FilesystemSink) Close() error {
+	// Set the metadata of the children before the one of their parents
+	sort.SliceStable(s.directories, func(i, j int) bool {
+		return strings.Count(filepath.Clean(s.directories[i].path), string(filepath.Separator)) > strings.Count(filepath.Clean(s.directories[j].path), string(filepath.Separator))
+	})
+
+	for _, dir := range s.directories {
+		if err := s.setMetadata(dir.path, dir.hdr); err != nil {
+			return err
+		}
+	}
+
+	s.directories = []directory{}
+
+	return nil
+}
+
+func (s *FilesystemSink) setMetadata(path string, hdr *tar.Header) error {
+	uid, gid := s.getOwner(hdr)
+
+	// Only root can give files away, so other users keep them as their own like tar does
+	if err := os.Lchown(path, uid, gid); err != nil && !(os.IsPermission(err) && os.Geteuid() != 0) {
+		return err
+	}
+
+	// Changing the owner clears the file capabilities, so the extended attributes are set afterwards
+	if xattrs, ok := s.xattrs[path]; ok {
+		delete(s.xattrs, path)
+
+		if err := xattrext.Set(path, xattrs, s.privileged); err != nil {
+			return err
+		}
+	}
+
+	atime := hdr.AccessTime
+	if atime.IsZero() {
+		atime = hdr.ModTime
+	}
+
+	// Symlinks have no permissions of their own
+	if hdr.Typeflag == tar.TypeSymlink {
+		return setSymlinkTimes(path, atime, hdr.ModTime)
+	}
+
+	// Changing the owner clears the setuid and setgid bits, so the permissions are set afterwards
+	if err := os.Chmod(path, hdr.FileInfo().Mode()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, atime, hdr.ModTime)
+}
+
+func (s *FilesystemSink) getOwner(hdr *tar.Header) (int, int) {
+	uid := hdr.Uid
+	if mapped, ok := s.uidMap[hdr.Uid]; ok {
+		uid = mapped
+	} else if !s.numericOwner && hdr.Uname != "" {
+		if id, ok := s.users[hdr.Uname]; ok {
+			uid = id
+		} else if usr, err := user.Lookup(hdr.Uname); err == nil {
+			if id, err := strconv.Atoi(usr.Uid); err == nil {
+				s.users[hdr.Uname] = id
+				uid = id
+			}
+		}
+	}
+
+	gid := hdr.Gid
+	if mapped, ok := s.gidMap[hdr.Gid]; ok {
+		gid = mapped
+	} else if !s.numericOwner && hdr.Gname != "" {
+		if id, ok := s.groups[hdr.Gname]; ok {
+			gid = id
+		} else if group, err := user.LookupGroup(hdr.Gname); err == nil {
+			if id, err := strconv.Atoi(group.Gid); err == nil {
+				s.groups[hdr.Gname] = id
+				gid = id
+			}
+		}
+	}
+
+	return uid, gid
+}
+
+// prepare creates the parent directories of path and removes what is at path, so that i.e. a symlink is replaced instead of written through
+func prepare(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+
+	return os.Remove(path)
+}
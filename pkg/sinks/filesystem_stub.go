@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sinks
+
+import (
+	"archive/tar"
+	"time"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// createDevice fails, as device nodes are not supported on this system
+func createDevice(path string, hdr *tar.Header) error {
+	return config.ErrDevicesUnsupported
+}
+
+// setSymlinkTimes ignores the times, as they can't be set without following the symlink on this system
+func setSymlinkTimes(path string, atime time.Time, mtime time.Time) error {
+	return nil
+}
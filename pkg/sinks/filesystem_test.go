@@ -0,0 +1,213 @@
+package sinks
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var archivedModTime = time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type filesystemSinkEntry struct {
+	hdr     *tar.Header
+	content string
+}
+
+var filesystemSinkTests = []struct {
+	name    string
+	entries []filesystemSinkEntry // Entries in the order in which they are restored
+	check   func(dst string) error
+}{
+	{
+		"Can restore file with permissions and times",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0640, ModTime: archivedModTime}, "a"},
+		},
+		func(dst string) error {
+			return checkFilesystemSinkFile(filepath.Join(dst, "a.txt"), 0640, "a")
+		},
+	},
+	{
+		"Can restore read-only file",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0400, ModTime: archivedModTime}, "a"},
+		},
+		func(dst string) error {
+			return checkFilesystemSinkFile(filepath.Join(dst, "a.txt"), 0400, "a")
+		},
+	},
+	{
+		"Can restore children of read-only directory",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeDir, Name: "d", Mode: 0500, ModTime: archivedModTime}, ""},
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "d/a.txt", Mode: 0600, ModTime: archivedModTime}, "a"},
+		},
+		func(dst string) error {
+			if err := checkFilesystemSinkFile(filepath.Join(dst, "d", "a.txt"), 0600, "a"); err != nil {
+				return err
+			}
+
+			// The directory's times are set after its children have been restored
+			return checkFilesystemSinkMetadata(filepath.Join(dst, "d"), os.ModeDir|0500)
+		},
+	},
+	{
+		"Can restore symlink",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0600, ModTime: archivedModTime}, "a"},
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: "b.txt", Linkname: "a.txt", Mode: 0777, ModTime: archivedModTime}, ""},
+		},
+		func(dst string) error {
+			target, err := os.Readlink(filepath.Join(dst, "b.txt"))
+			if err != nil {
+				return err
+			}
+
+			if target != "a.txt" {
+				return fmt.Errorf("symlink has wrong target, got %v, want %v", target, "a.txt")
+			}
+
+			return checkFilesystemSinkFile(filepath.Join(dst, "b.txt"), 0600, "a")
+		},
+	},
+	{
+		"Can restore hard link",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0600, ModTime: archivedModTime}, "a"},
+			{&tar.Header{Typeflag: tar.TypeLink, Name: "b.txt", Linkname: "a.txt", Mode: 0600, ModTime: archivedModTime}, ""},
+		},
+		func(dst string) error {
+			a, err := os.Stat(filepath.Join(dst, "a.txt"))
+			if err != nil {
+				return err
+			}
+
+			b, err := os.Stat(filepath.Join(dst, "b.txt"))
+			if err != nil {
+				return err
+			}
+
+			if !os.SameFile(a, b) {
+				return fmt.Errorf("hard link is a copy of %v, want link", a.Name())
+			}
+
+			return nil
+		},
+	},
+	{
+		"Can replace existing symlink instead of writing through it",
+		[]filesystemSinkEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "a.txt", Mode: 0600, ModTime: archivedModTime}, "a"},
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: "b.txt", Linkname: "a.txt", Mode: 0777, ModTime: archivedModTime}, ""},
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "b.txt", Mode: 0600, ModTime: archivedModTime}, "b"},
+		},
+		func(dst string) error {
+			if err := checkFilesystemSinkFile(filepath.Join(dst, "a.txt"), 0600, "a"); err != nil {
+				return err
+			}
+
+			return checkFilesystemSinkFile(filepath.Join(dst, "b.txt"), 0600, "b")
+		},
+	},
+}
+
+func TestFilesystemSink(t *testing.T) {
+	for _, tt := range filesystemSinkTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+
+			// Read-only directories have to be made writable again so that they can be cleaned up
+			defer filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+				if err == nil && info.IsDir() {
+					_ = os.Chmod(path, 0700)
+				}
+
+				return nil
+			})
+
+			sink := NewFilesystemSink(true, nil, nil, false)
+			for _, entry := range tt.entries {
+				hdr := *entry.hdr
+				hdr.Uid = os.Geteuid()
+				hdr.Gid = os.Getegid()
+
+				path := filepath.Join(dst, filepath.FromSlash(hdr.Name))
+
+				var err error
+				switch hdr.Typeflag {
+				case tar.TypeDir:
+					err = sink.CreateDirectory(path, &hdr)
+				case tar.TypeSymlink:
+					err = sink.CreateSymlink(path, &hdr)
+				case tar.TypeLink:
+					err = sink.CreateHardlink(path, filepath.Join(dst, filepath.FromSlash(hdr.Linkname)), &hdr)
+				default:
+					var w io.WriteCloser
+					w, err = sink.CreateFile(path, &hdr)
+					if err == nil {
+						if _, err = w.Write([]byte(entry.content)); err == nil {
+							err = w.Close()
+						}
+					}
+				}
+				if err != nil {
+					t.Errorf("FilesystemSink.Create() error = %v, wantErr %v", err, false)
+
+					return
+				}
+
+				if hdr.Typeflag != tar.TypeLink {
+					if err := sink.SetMetadata(path, &hdr); err != nil {
+						t.Errorf("FilesystemSink.SetMetadata() error = %v, wantErr %v", err, false)
+
+						return
+					}
+				}
+			}
+
+			if err := sink.Close(); err != nil {
+				t.Errorf("FilesystemSink.Close() error = %v, wantErr %v", err, false)
+
+				return
+			}
+
+			if err := tt.check(dst); err != nil {
+				t.Errorf("FilesystemSink check() error = %v", err)
+			}
+		})
+	}
+}
+
+func checkFilesystemSinkFile(path string, mode os.FileMode, content string) error {
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if string(got) != content {
+		return fmt.Errorf("%v has wrong content, got %v, want %v", path, string(got), content)
+	}
+
+	return checkFilesystemSinkMetadata(path, mode)
+}
+
+func checkFilesystemSinkMetadata(path string, mode os.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode() != mode {
+		return fmt.Errorf("%v has wrong mode, got %v, want %v", path, info.Mode(), mode)
+	}
+
+	if !info.ModTime().Equal(archivedModTime) {
+		return fmt.Errorf("%v has wrong modification time, got %v, want %v", path, info.ModTime(), archivedModTime)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package sinks
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/pojntfx/stfs/pkg/config"
+)
+
+// WriterSink writes the content of the restored file to a writer and ignores everything else, i.e. to read a single file
+type WriterSink struct {
+	w io.WriteCloser
+}
+
+func NewWriterSink(w io.WriteCloser) *WriterSink {
+	return &WriterSink{
+		w: w,
+	}
+}
+
+func (s *WriterSink) Lstat(path string) (fs.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *WriterSink) CreateFile(path string, hdr *tar.Header) (io.WriteCloser, error) {
+	return s.w, nil
+}
+
+func (s *WriterSink) CreateDirectory(path string, hdr *tar.Header) error {
+	return nil
+}
+
+func (s *WriterSink) CreateSymlink(path string, hdr *tar.Header) error {
+	return nil
+}
+
+func (s *WriterSink) CreateHardlink(path string, target string, hdr *tar.Header) error {
+	return config.ErrHardlinksUnsupported
+}
+
+func (s *WriterSink) CreateDevice(path string, hdr *tar.Header) error {
+	return nil
+}
+
+func (s *WriterSink) SetXattrs(path string, xattrs map[string]string) error {
+	return nil
+}
+
+func (s *WriterSink) SetMetadata(path string, hdr *tar.Header) error {
+	return nil
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
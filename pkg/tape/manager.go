@@ -1,6 +1,7 @@
 package tape
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -13,6 +14,7 @@ type TapeManager struct {
 	mt         config.MagneticTapeIO
 	recordSize int
 	overwrite  bool
+	volume     int
 
 	physicalLock sync.Mutex
 
@@ -22,7 +24,7 @@ type TapeManager struct {
 
 	closer func() error
 
-	overwrote bool
+	overwrote map[int]bool
 }
 
 func NewTapeManager(
@@ -36,20 +38,22 @@ func NewTapeManager(
 		mt:         mt,
 		recordSize: recordSize,
 		overwrite:  overwrite,
+		overwrote:  map[int]bool{},
 	}
 }
 
 func (m *TapeManager) GetWriter() (config.DriveWriterConfig, error) {
 	m.physicalLock.Lock()
 
+	// Only overwrite each volume once
 	overwrite := m.overwrite
-	if m.overwrote {
+	if m.overwrote[m.volume] {
 		overwrite = false
 	}
-	m.overwrote = true
+	m.overwrote[m.volume] = true
 
 	writer, writerIsRegular, err := OpenTapeWriteOnly(
-		m.drive,
+		m.getVolumePath(),
 		m.mt,
 		m.recordSize,
 		overwrite,
@@ -63,6 +67,7 @@ func (m *TapeManager) GetWriter() (config.DriveWriterConfig, error) {
 	return config.DriveWriterConfig{
 		Drive:          writer,
 		DriveIsRegular: writerIsRegular,
+		Volume:         m.volume,
 	}, nil
 }
 
@@ -74,9 +79,49 @@ func (m *TapeManager) GetReader() (config.DriveReaderConfig, error) {
 	return config.DriveReaderConfig{
 		Drive:          m.reader,
 		DriveIsRegular: m.readerIsRegular,
+		Volume:         m.volume,
 	}, nil
 }
 
+// ChangeVolume switches to another volume. Tape drives eject the current tape, so the
+// caller has to wait for the next one to be inserted; tar files use one file per volume.
+func (m *TapeManager) ChangeVolume(volume int) error {
+	if volume == m.volume {
+		return nil
+	}
+
+	m.physicalLock.Lock()
+	defer m.physicalLock.Unlock()
+
+	m.readerLock.Lock()
+	m.reader = nil
+	m.readerLock.Unlock()
+
+	stat, err := os.Stat(m.drive)
+	if err == nil && !stat.Mode().IsRegular() {
+		f, err := os.OpenFile(m.drive, os.O_RDONLY, os.ModeCharDevice)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := m.mt.EjectTape(f.Fd()); err != nil {
+			return err
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	m.volume = volume
+
+	return nil
+}
+
+// TruncateVolume cuts the current volume off after size bytes; only tar files can be truncated
+func (m *TapeManager) TruncateVolume(size int64) error {
+	return os.Truncate(m.getVolumePath(), size)
+}
+
 func (m *TapeManager) Close() error {
 	if m.closer != nil {
 		if err := m.closer(); err != nil {
@@ -104,7 +149,7 @@ func (m *TapeManager) openOrReuseReader() error {
 	if reopen {
 		m.physicalLock.Lock()
 
-		r, rr, err := OpenTapeReadOnly(m.drive)
+		r, rr, err := OpenTapeReadOnly(m.getVolumePath())
 		if err != nil {
 			return err
 		}
@@ -117,3 +162,17 @@ func (m *TapeManager) openOrReuseReader() error {
 
 	return nil
 }
+
+func (m *TapeManager) getVolumePath() string {
+	return GetVolumePath(m.drive, m.volume)
+}
+
+// GetVolumePath returns the path of a volume; tape drives use the same path for all volumes
+func GetVolumePath(drive string, volume int) string {
+	stat, err := os.Stat(drive)
+	if volume == 0 || (err == nil && !stat.Mode().IsRegular()) {
+		return drive
+	}
+
+	return fmt.Sprintf("%v.%v", drive, volume)
+}